@@ -0,0 +1,8 @@
+// traffic-checker は linux-traffic-checker コマンドのエントリーポイント。本体は internal/app にある。
+package main
+
+import "github.com/rakku1234/linux-traffic-checker/internal/app"
+
+func main() {
+	app.Main()
+}
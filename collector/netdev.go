@@ -0,0 +1,75 @@
+// Package collector はネットワークインターフェースのバイト数とパケット数のカウンタを
+// /proc/net/dev・sysfs・rtnetlink から読み取る。
+package collector
+
+import (
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+)
+
+// NetDevPath はカーネルのインターフェースごとのカウンタの表。
+const NetDevPath = "/proc/net/dev"
+
+// netDevHeaderLines は /proc/net/dev の先頭にある見出しの行数。
+const netDevHeaderLines = 2
+
+// InterfaceStats はインターフェースの受信・送信バイト数、またはある期間の増分。
+type InterfaceStats struct {
+	RX big.Int `json:"rx"`
+	TX big.Int `json:"tx"`
+}
+
+// ParseNetDev は /proc/net/dev の内容を解析する。インターフェース名は空白で右寄せされ、
+// 最初のコロンで終わるので、部分一致ではなくコロンの前を切り出して空白を除いた名前で扱う。
+func ParseNetDev(data string) (map[string]*InterfaceStats, error) {
+	counters := make(map[string]*InterfaceStats)
+	lines := strings.Split(data, "\n")
+	if len(lines) < netDevHeaderLines {
+		return counters, nil
+	}
+	for _, line := range lines[netDevHeaderLines:] {
+		name, rest, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) < 9 {
+			continue
+		}
+
+		counter := &InterfaceStats{}
+		if _, ok := counter.RX.SetString(fields[0], 10); !ok {
+			return nil, fmt.Errorf("受信バイト数を解析できません: %q", fields[0])
+		}
+		if _, ok := counter.TX.SetString(fields[8], 10); !ok {
+			return nil, fmt.Errorf("送信バイト数を解析できません: %q", fields[8])
+		}
+		counters[strings.TrimSpace(name)] = counter
+	}
+	return counters, nil
+}
+
+// ReadNetDev は /proc/net/dev を読み取って解析する。
+func ReadNetDev() (map[string]*InterfaceStats, error) {
+	data, err := os.ReadFile(NetDevPath)
+	if err != nil {
+		return nil, err
+	}
+	return ParseNetDev(string(data))
+}
+
+// FindInterface は /proc/net/dev から1つのインターフェースのカウンタを返す。
+func FindInterface(data, interfaceName string) (big.Int, big.Int, error) {
+	counters, err := ParseNetDev(data)
+	if err != nil {
+		return big.Int{}, big.Int{}, err
+	}
+
+	counter, ok := counters[interfaceName]
+	if !ok {
+		return big.Int{}, big.Int{}, fmt.Errorf("インターフェース %s が見つかりません", interfaceName)
+	}
+	return counter.RX, counter.TX, nil
+}
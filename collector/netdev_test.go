@@ -0,0 +1,121 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+)
+
+// netDev は eth0 と、その名前を前方一致で含む VLAN の eth0.100 を持つ /proc/net/dev。
+const netDev = `Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo:   12345      67    0    0    0     0          0         0    12345      67    0    0    0     0       0          0
+eth0.100: 9000000   7000    1    2    0     0          0         0  8000000   6000    3    4    0     0       0          0
+  eth0: 18446744073709551615  900000    0    5    0     0          0        10 4294967296  800000    0    6    0     0       0          0
+`
+
+func TestParseNetDev(t *testing.T) {
+	counters, err := ParseNetDev(netDev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		rx, tx string
+	}{
+		{"lo", "12345", "12345"},
+		{"eth0.100", "9000000", "8000000"},
+		{"eth0", "18446744073709551615", "4294967296"},
+	}
+	if len(counters) != len(tests) {
+		t.Errorf("ParseNetDev found %d interfaces, want %d", len(counters), len(tests))
+	}
+	for _, tt := range tests {
+		counter, ok := counters[tt.name]
+		if !ok {
+			t.Errorf("%s not found", tt.name)
+			continue
+		}
+		if counter.RX.String() != tt.rx || counter.TX.String() != tt.tx {
+			t.Errorf("%s = %s/%s, want %s/%s", tt.name, &counter.RX, &counter.TX, tt.rx, tt.tx)
+		}
+	}
+	// 見出しの行はインターフェースとして扱わない。
+	for _, header := range []string{"Inter-|   Receive", " face |bytes", "face"} {
+		if _, ok := counters[header]; ok {
+			t.Errorf("header %q was parsed as an interface", header)
+		}
+	}
+}
+
+func TestFindInterface(t *testing.T) {
+	tests := []struct {
+		name    string
+		rx, tx  string
+		wantErr bool
+	}{
+		// eth0.100 の行が先にあっても eth0 はその行を選ばない。
+		{"eth0", "18446744073709551615", "4294967296", false},
+		{"eth0.100", "9000000", "8000000", false},
+		{"eth", "", "", true},
+		{"eth0.10", "", "", true},
+		{"face", "", "", true},
+	}
+	for _, tt := range tests {
+		rx, tx, err := FindInterface(netDev, tt.name)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("FindInterface(%q) = %s/%s, want an error", tt.name, &rx, &tx)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("FindInterface(%q): %v", tt.name, err)
+			continue
+		}
+		if rx.String() != tt.rx || tx.String() != tt.tx {
+			t.Errorf("FindInterface(%q) = %s/%s, want %s/%s", tt.name, &rx, &tx, tt.rx, tt.tx)
+		}
+	}
+}
+
+func TestParseNetDevMalformed(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    int
+		wantErr bool
+	}{
+		{"空", "", 0, false},
+		{"見出しだけ", strings.Join(strings.Split(netDev, "\n")[:2], "\n"), 0, false},
+		{"列が足りない行は読み飛ばす", "h1\nh2\n  eth0: 1 2 3\n", 0, false},
+		{"数値でない", "h1\nh2\n  eth0: x 2 3 4 5 6 7 8 9 10 11 12 13 14 15 16\n", 0, true},
+	}
+	for _, tt := range tests {
+		counters, err := ParseNetDev(tt.data)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err == nil && len(counters) != tt.want {
+			t.Errorf("%s: found %d interfaces, want %d", tt.name, len(counters), tt.want)
+		}
+	}
+}
+
+func TestParseNetDevPackets(t *testing.T) {
+	counts, err := ParseNetDevPackets(netDev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	eth0, ok := counts["eth0"]
+	if !ok {
+		t.Fatal("eth0 not found")
+	}
+	want := PacketCounts{RXPackets: 900000, TXPackets: 800000, RXErrors: 0, TXErrors: 0, RXDropped: 5, TXDropped: 6}
+	if *eth0 != want {
+		t.Errorf("eth0 = %+v, want %+v", *eth0, want)
+	}
+	if counts["eth0.100"].RXDropped != 2 {
+		t.Errorf("eth0.100 = %+v", *counts["eth0.100"])
+	}
+}
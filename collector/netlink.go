@@ -0,0 +1,71 @@
+//go:build linux
+
+package collector
+
+import (
+	"encoding/binary"
+	"fmt"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// LinkStats は struct rtnl_link_stats64 の先頭部分で、IFLA_STATS64 を返すカーネルなら必ず埋まっている。
+type LinkStats struct {
+	RXPackets uint64
+	TXPackets uint64
+	RXBytes   uint64
+	TXBytes   uint64
+	RXErrors  uint64
+	TXErrors  uint64
+	RXDropped uint64
+	TXDropped uint64
+}
+
+// ReadNetlink は名前が完全に一致するインターフェースの64ビットの統計を rtnetlink から取得する。
+func ReadNetlink(interfaceName string) (*LinkStats, error) {
+	data, err := syscall.NetlinkRIB(unix.RTM_GETLINK, unix.AF_UNSPEC)
+	if err != nil {
+		return nil, fmt.Errorf("netlinkでリンク情報を取得できません: %w", err)
+	}
+	messages, err := syscall.ParseNetlinkMessage(data)
+	if err != nil {
+		return nil, fmt.Errorf("netlinkの応答を解析できません: %w", err)
+	}
+
+	for i := range messages {
+		if messages[i].Header.Type != unix.RTM_NEWLINK {
+			continue
+		}
+		attrs, err := syscall.ParseNetlinkRouteAttr(&messages[i])
+		if err != nil {
+			return nil, fmt.Errorf("netlinkの応答を解析できません: %w", err)
+		}
+
+		var name string
+		var stats []byte
+		for _, attr := range attrs {
+			switch attr.Attr.Type {
+			case unix.IFLA_IFNAME:
+				name = unix.ByteSliceToString(attr.Value)
+			case unix.IFLA_STATS64:
+				stats = attr.Value
+			}
+		}
+		if name != interfaceName {
+			continue
+		}
+		var link LinkStats
+		if len(stats) < 8*8 {
+			return nil, fmt.Errorf("%s の IFLA_STATS64 がありません", interfaceName)
+		}
+		for j, field := range []*uint64{
+			&link.RXPackets, &link.TXPackets, &link.RXBytes, &link.TXBytes,
+			&link.RXErrors, &link.TXErrors, &link.RXDropped, &link.TXDropped,
+		} {
+			*field = binary.NativeEndian.Uint64(stats[j*8:])
+		}
+		return &link, nil
+	}
+	return nil, fmt.Errorf("インターフェース %s が見つかりません", interfaceName)
+}
@@ -0,0 +1,25 @@
+//go:build !linux
+
+package collector
+
+import (
+	"errors"
+	"fmt"
+)
+
+// LinkStats は Linux の struct rtnl_link_stats64 の先頭部分。
+type LinkStats struct {
+	RXPackets uint64
+	TXPackets uint64
+	RXBytes   uint64
+	TXBytes   uint64
+	RXErrors  uint64
+	TXErrors  uint64
+	RXDropped uint64
+	TXDropped uint64
+}
+
+// ReadNetlink は Linux でのみ使える。
+func ReadNetlink(interfaceName string) (*LinkStats, error) {
+	return nil, fmt.Errorf("%s: netlinkはLinuxでのみ使えます: %w", interfaceName, errors.ErrUnsupported)
+}
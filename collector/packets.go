@@ -0,0 +1,86 @@
+package collector
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PacketCounts は /proc/net/dev のパケット数・エラー数・破棄数（2〜4列目と10〜12列目）、
+// またはある期間の増分。
+type PacketCounts struct {
+	RXPackets uint64 `json:"rx_packets"`
+	TXPackets uint64 `json:"tx_packets"`
+	RXErrors  uint64 `json:"rx_errors"`
+	TXErrors  uint64 `json:"tx_errors"`
+	RXDropped uint64 `json:"rx_dropped"`
+	TXDropped uint64 `json:"tx_dropped"`
+}
+
+func (c *PacketCounts) fields() []*uint64 {
+	return []*uint64{&c.RXPackets, &c.TXPackets, &c.RXErrors, &c.TXErrors, &c.RXDropped, &c.TXDropped}
+}
+
+// Add は other のカウンタを c に加える。
+func (c *PacketCounts) Add(other *PacketCounts) {
+	values := other.fields()
+	for i, field := range c.fields() {
+		*field += *values[i]
+	}
+}
+
+// PacketDelta は baseline から current までの増分。減ったカウンタはリセットされたものとして、
+// リセット後に数えた値をすべて増分とする。
+func PacketDelta(current, baseline *PacketCounts) *PacketCounts {
+	delta := &PacketCounts{}
+	now, before := current.fields(), baseline.fields()
+	for i, field := range delta.fields() {
+		if *now[i] >= *before[i] {
+			*field = *now[i] - *before[i]
+		} else {
+			*field = *now[i]
+		}
+	}
+	return delta
+}
+
+// DroppedPercent は破棄されたパケットの割合（パーセント）。
+func (c *PacketCounts) DroppedPercent() float64 {
+	packets := c.RXPackets + c.TXPackets + c.RXDropped + c.TXDropped
+	if packets == 0 {
+		return 0
+	}
+	return float64(c.RXDropped+c.TXDropped) / float64(packets) * 100
+}
+
+// ParseNetDevPackets は /proc/net/dev のパケット数・エラー数・破棄数の列を、
+// ParseNetDev がバイト数を解析するのと同じ方法で解析する。
+func ParseNetDevPackets(data string) (map[string]*PacketCounts, error) {
+	counts := make(map[string]*PacketCounts)
+	lines := strings.Split(data, "\n")
+	if len(lines) < netDevHeaderLines {
+		return counts, nil
+	}
+	for _, line := range lines[netDevHeaderLines:] {
+		name, rest, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) < 12 {
+			continue
+		}
+
+		count := &PacketCounts{}
+		columns := []int{1, 9, 2, 10, 3, 11}
+		for i, field := range count.fields() {
+			value, err := strconv.ParseUint(fields[columns[i]], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("パケット数を解析できません: %q", fields[columns[i]])
+			}
+			*field = value
+		}
+		counts[strings.TrimSpace(name)] = count
+	}
+	return counts, nil
+}
@@ -0,0 +1,16 @@
+package collector
+
+import "math/big"
+
+// Source はホストのインターフェースのカウンタを読み取る。Default はプラットフォームに合ったもので、
+// Linux では /proc/net/dev・sysfs・rtnetlink、それ以外と gopsutil タグを付けたビルドでは gopsutil を使う。
+type Source interface {
+	// Counters は全インターフェースのバイト数のカウンタを名前ごとに返す。
+	Counters() (map[string]*InterfaceStats, error)
+	// Interface は1つのインターフェースの受信・送信バイト数を返す。
+	Interface(name string) (big.Int, big.Int, error)
+	// Packets は全インターフェースのパケット数のカウンタを名前ごとに返す。
+	Packets() (map[string]*PacketCounts, error)
+	// InterfacePackets は1つのインターフェースのパケット数のカウンタを返す。
+	InterfacePackets(name string) (*PacketCounts, error)
+}
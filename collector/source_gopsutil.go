@@ -0,0 +1,83 @@
+//go:build !linux || gopsutil
+
+package collector
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/shirou/gopsutil/v4/net"
+)
+
+// Default は macOS・BSD・Windows に対応した gopsutil でカウンタを読み取る。
+// macOS と BSD の送信側の破棄数など、プラットフォームが数えていないカウンタは0になる。
+var Default Source = gopsutilSource{}
+
+type gopsutilSource struct{}
+
+func (gopsutilSource) read() (map[string]net.IOCountersStat, error) {
+	stats, err := net.IOCounters(true)
+	if err != nil {
+		return nil, fmt.Errorf("インターフェースのカウンタを取得できません: %w", err)
+	}
+	byName := make(map[string]net.IOCountersStat, len(stats))
+	for _, stat := range stats {
+		byName[stat.Name] = stat
+	}
+	return byName, nil
+}
+
+func (s gopsutilSource) Counters() (map[string]*InterfaceStats, error) {
+	stats, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	counters := make(map[string]*InterfaceStats, len(stats))
+	for name, stat := range stats {
+		counter := &InterfaceStats{}
+		counter.RX.SetUint64(stat.BytesRecv)
+		counter.TX.SetUint64(stat.BytesSent)
+		counters[name] = counter
+	}
+	return counters, nil
+}
+
+func (s gopsutilSource) Interface(name string) (big.Int, big.Int, error) {
+	counters, err := s.Counters()
+	if err != nil {
+		return big.Int{}, big.Int{}, err
+	}
+	counter, ok := counters[name]
+	if !ok {
+		return big.Int{}, big.Int{}, fmt.Errorf("インターフェース %s が見つかりません", name)
+	}
+	return counter.RX, counter.TX, nil
+}
+
+func (s gopsutilSource) Packets() (map[string]*PacketCounts, error) {
+	stats, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]*PacketCounts, len(stats))
+	for name, stat := range stats {
+		counts[name] = &PacketCounts{
+			RXPackets: stat.PacketsRecv, TXPackets: stat.PacketsSent,
+			RXErrors: stat.Errin, TXErrors: stat.Errout,
+			RXDropped: stat.Dropin, TXDropped: stat.Dropout,
+		}
+	}
+	return counts, nil
+}
+
+func (s gopsutilSource) InterfacePackets(name string) (*PacketCounts, error) {
+	counts, err := s.Packets()
+	if err != nil {
+		return nil, err
+	}
+	count, ok := counts[name]
+	if !ok {
+		return nil, fmt.Errorf("インターフェース %s が見つかりません", name)
+	}
+	return count, nil
+}
@@ -0,0 +1,75 @@
+//go:build linux && !gopsutil
+
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"math/big"
+	"os"
+)
+
+// Default は /proc/net/dev・sysfs・rtnetlink を直接読み取る。
+var Default Source = procSource{}
+
+type procSource struct{}
+
+func (procSource) Counters() (map[string]*InterfaceStats, error) {
+	return ReadNetDev()
+}
+
+// Interface は rtnetlink、次に sysfs を使い、sysfs のファイルがなければ /proc/net/dev を読む。
+func (procSource) Interface(name string) (big.Int, big.Int, error) {
+	link, err := ReadNetlink(name)
+	if err == nil {
+		var rx, tx big.Int
+		rx.SetUint64(link.RXBytes)
+		tx.SetUint64(link.TXBytes)
+		return rx, tx, nil
+	}
+	slog.Debug("netlinkでカウンタを読み取れないため sysfs から読み取ります", "interface", name, "error", err)
+
+	rx, tx, err := ReadSysfs(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		slog.Debug("sysfsにカウンタがないため /proc/net/dev から読み取ります", "interface", name)
+		data, err := os.ReadFile(NetDevPath)
+		if err != nil {
+			return big.Int{}, big.Int{}, err
+		}
+		return FindInterface(string(data), name)
+	}
+	return rx, tx, err
+}
+
+func (procSource) Packets() (map[string]*PacketCounts, error) {
+	data, err := os.ReadFile(NetDevPath)
+	if err != nil {
+		return nil, err
+	}
+	return ParseNetDevPackets(string(data))
+}
+
+// InterfacePackets も Interface と同じく rtnetlink を優先する。
+func (s procSource) InterfacePackets(name string) (*PacketCounts, error) {
+	link, err := ReadNetlink(name)
+	if err == nil {
+		return &PacketCounts{
+			RXPackets: link.RXPackets, TXPackets: link.TXPackets,
+			RXErrors: link.RXErrors, TXErrors: link.TXErrors,
+			RXDropped: link.RXDropped, TXDropped: link.TXDropped,
+		}, nil
+	}
+	slog.Debug("netlinkでパケット数を読み取れないため /proc/net/dev から読み取ります", "interface", name, "error", err)
+
+	counts, err := s.Packets()
+	if err != nil {
+		return nil, err
+	}
+	count, ok := counts[name]
+	if !ok {
+		return nil, fmt.Errorf("インターフェース %s が見つかりません", name)
+	}
+	return count, nil
+}
@@ -0,0 +1,26 @@
+package collector
+
+import (
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ReadSysfs は /sys/class/net からバイト数のカウンタを読み取る。整形された /proc/net/dev の表と違い、
+// カーネルはカウンタの全桁をそのまま返す。
+func ReadSysfs(interfaceName string) (big.Int, big.Int, error) {
+	var counters [2]big.Int
+	for i, name := range []string{"rx_bytes", "tx_bytes"} {
+		data, err := os.ReadFile(filepath.Join("/sys/class/net", interfaceName, "statistics", name))
+		if err != nil {
+			return big.Int{}, big.Int{}, err
+		}
+		value := strings.TrimSpace(string(data))
+		if _, ok := counters[i].SetString(value, 10); !ok {
+			return big.Int{}, big.Int{}, fmt.Errorf("%s の %s を解析できません: %q", interfaceName, name, value)
+		}
+	}
+	return counters[0], counters[1], nil
+}
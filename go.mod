@@ -3,8 +3,27 @@ module github.com/rakku1234/linux-traffic-checker
 go 1.24.4
 
 require (
-	github.com/go-co-op/gocron/v2 v2.16.2 // indirect
+	github.com/eclipse/paho.mqtt.golang v1.5.1
+	github.com/go-co-op/gocron/v2 v2.16.2
+	github.com/gosnmp/gosnmp v1.45.0
+	github.com/jonboulle/clockwork v0.5.0
+	github.com/redis/go-redis/v9 v9.7.3
+	github.com/robfig/cron/v3 v3.0.1
+	github.com/shirou/gopsutil/v4 v4.26.8
+	go.etcd.io/bbolt v1.4.3
+	golang.org/x/image v0.31.0
+	golang.org/x/sys v0.41.0
+)
+
+require (
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	github.com/ebitengine/purego v0.10.2 // indirect
+	github.com/go-ole/go-ole v1.2.6 // indirect
 	github.com/google/uuid v1.6.0 // indirect
-	github.com/jonboulle/clockwork v0.5.0 // indirect
-	github.com/robfig/cron/v3 v3.0.1 // indirect
+	github.com/gorilla/websocket v1.5.3 // indirect
+	github.com/power-devops/perfstat v0.0.0-20240221224432-82ca36839d55 // indirect
+	github.com/yusufpapurcu/wmi v1.2.4 // indirect
+	golang.org/x/net v0.44.0 // indirect
+	golang.org/x/sync v0.17.0 // indirect
 )
@@ -0,0 +1,69 @@
+package app
+
+import (
+	"log/slog"
+	"math/big"
+
+	"github.com/rakku1234/linux-traffic-checker/collector"
+)
+
+// accumulates は通信量をカウンタとベースラインの差ではなく、読み取りごとの増分の合計で数えるかどうかを返す。
+// それが役に立つほど頻繁に読み取るのは poll_mode が continuous の場合だけ。
+func (config *Config) accumulates() bool {
+	return config.PollMode == "continuous"
+}
+
+// readDelta は last から current までのカウンタの増分を返す。桁あふれは adjustWrap が last から
+// 取り除いているので、減少はすべてリセットで、1つのインターフェースのカウンタなら0から数え直している。
+// インターフェースの合計はインターフェースが消えただけでも減るので、その場合は何も数えない。
+func readDelta(last, current *big.Int, aggregate bool) (*big.Int, bool) {
+	delta := new(big.Int).Sub(current, last)
+	if delta.Sign() >= 0 {
+		return delta, false
+	}
+	if aggregate {
+		return new(big.Int), true
+	}
+	return new(big.Int).Set(current), true
+}
+
+// accumulateRead は前回から今回の読み取りまでの通信量を Accumulated に加える。
+// stats.LastRX・LastTX には前回の読み取りが残っていること。
+func accumulateRead(config *Config, stats *Stats, currentRX, currentTX *big.Int, aggregate bool) {
+	if stats.Accumulated == nil {
+		// scheduled から切り替えた場合は、それまでの通信量から始める。
+		accumulated := &collector.InterfaceStats{}
+		if usedRX, usedTX := periodUsage(stats, &stats.LastRX, &stats.LastTX); usedRX.Sign() >= 0 && usedTX.Sign() >= 0 {
+			accumulated.RX.Set(usedRX)
+			accumulated.TX.Set(usedTX)
+		}
+		stats.Accumulated = accumulated
+		stats.Carried = nil
+	}
+	if stats.LastReadAt.IsZero() {
+		return
+	}
+
+	deltaRX, resetRX := readDelta(&stats.LastRX, currentRX, aggregate)
+	deltaTX, resetTX := readDelta(&stats.LastTX, currentTX, aggregate)
+	if resetRX || resetTX {
+		slog.Warn("前回の読み取りからカウンタが減少したため、リセットとして集計を続けます", "interface", config.Interface,
+			"last_rx", stats.LastRX.String(), "rx", currentRX.String(), "last_tx", stats.LastTX.String(), "tx", currentTX.String())
+	}
+	stats.Accumulated.RX.Add(&stats.Accumulated.RX, deltaRX)
+	stats.Accumulated.TX.Add(&stats.Accumulated.TX, deltaTX)
+	slog.Debug("前回の読み取りからの通信量を加算しました", "interface", config.Interface, "delta_rx", deltaRX.String(), "delta_tx", deltaTX.String(),
+		"accumulated_rx", stats.Accumulated.RX.String(), "accumulated_tx", stats.Accumulated.TX.String())
+}
+
+// stopAccumulating は scheduled に戻した場合に、それまでの通信量を残すため Accumulated を
+// ベースラインと Carried に戻す。
+func stopAccumulating(stats *Stats) {
+	if stats.Accumulated == nil {
+		return
+	}
+	stats.Carried = stats.Accumulated
+	stats.Accumulated = nil
+	stats.RX.Set(&stats.LastRX)
+	stats.TX.Set(&stats.LastTX)
+}
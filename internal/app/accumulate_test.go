@@ -0,0 +1,168 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/rakku1234/linux-traffic-checker/collector"
+)
+
+func TestReadDelta(t *testing.T) {
+	tests := []struct {
+		name          string
+		last, current int64
+		aggregate     bool
+		want          int64
+		reset         bool
+	}{
+		{"増加", 1000, 1500, false, 500, false},
+		{"変化なし", 1000, 1000, false, 0, false},
+		// リセット後のカウンタは0から数え直しているので、今の値がそのまま増分になる。
+		{"リセット", 1000, 200, false, 200, true},
+		// 合計の減少はインターフェースが消えただけかもしれないので数えない。
+		{"合計の減少", 1000, 200, true, 0, true},
+	}
+	for _, tt := range tests {
+		got, reset := readDelta(big.NewInt(tt.last), big.NewInt(tt.current), tt.aggregate)
+		if got.Int64() != tt.want || reset != tt.reset {
+			t.Errorf("%s: readDelta = %s, %v; want %d, %v", tt.name, got, reset, tt.want, tt.reset)
+		}
+	}
+}
+
+// pollConfig は counter_command で counters ファイルの内容を読む poll_mode continuous の設定を読み込む。
+func pollConfig(t *testing.T, stats string) (*Config, string) {
+	t.Helper()
+	saved := clock
+	clock = clockwork.NewFakeClockAt(time.Date(2026, time.October, 14, 12, 0, 0, 0, time.UTC))
+	t.Cleanup(func() { clock = saved })
+
+	dir := t.TempDir()
+	statsFile := filepath.Join(dir, "stats.json")
+	if err := os.WriteFile(statsFile, []byte(stats), 0644); err != nil {
+		t.Fatal(err)
+	}
+	counters := filepath.Join(dir, "counters")
+	data, _ := json.Marshal(map[string]any{
+		"discord_webhook_url": "https://discord.com/api/webhooks/1/a",
+		"interface":           "eth0",
+		"stats_file":          statsFile,
+		"poll_mode":           "continuous",
+		"counter_command":     "cat " + counters,
+		"counter_regex":       `rx=(?P<rx>\d+) tx=(?P<tx>\d+)`,
+	})
+	path := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := readConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return config, counters
+}
+
+func TestPollAccumulatesAcrossReset(t *testing.T) {
+	config, counters := pollConfig(t, `{"month": "2026-10", "rx": 1000, "tx": 500, "last_rx": 1000, "last_tx": 500, "last_read_at": "2026-10-14T11:55:00Z"}`)
+
+	polls := []struct {
+		rx, tx         int64
+		wantRX, wantTX int64
+	}{
+		{3000, 1500, 2000, 1000},
+		// 再起動でカウンタが0から数え直しても、それまでの通信量は残る。
+		{200, 100, 2200, 1100},
+		{700, 400, 2700, 1400},
+		// 2回続けてリセットされても同じ。
+		{50, 20, 2750, 1420},
+	}
+	for i, poll := range polls {
+		if err := os.WriteFile(counters, fmt.Appendf(nil, "rx=%d tx=%d\n", poll.rx, poll.tx), 0644); err != nil {
+			t.Fatal(err)
+		}
+		clock.(*clockwork.FakeClock).Advance(5 * time.Minute)
+		PollNetStats(config)
+
+		stats, _, err := config.store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.Accumulated == nil {
+			t.Fatalf("poll %d: nothing accumulated", i+1)
+		}
+		if stats.Accumulated.RX.Int64() != poll.wantRX || stats.Accumulated.TX.Int64() != poll.wantTX {
+			t.Errorf("poll %d: accumulated %s/%s, want %d/%d", i+1, &stats.Accumulated.RX, &stats.Accumulated.TX, poll.wantRX, poll.wantTX)
+		}
+		// レポートの通信量は、最後の読み取りの後に増えた分がなければ積算した値そのもの。
+		usedRX, usedTX := periodUsage(stats, big.NewInt(poll.rx), big.NewInt(poll.tx))
+		if usedRX.Int64() != poll.wantRX || usedTX.Int64() != poll.wantTX {
+			t.Errorf("poll %d: period usage %s/%s, want %d/%d", i+1, usedRX, usedTX, poll.wantRX, poll.wantTX)
+		}
+		if stats.LastRX.Int64() != poll.rx || stats.LastTX.Int64() != poll.tx {
+			t.Errorf("poll %d: last read %s/%s, want %d/%d", i+1, &stats.LastRX, &stats.LastTX, poll.rx, poll.tx)
+		}
+	}
+}
+
+func TestStopAccumulatingKeepsUsage(t *testing.T) {
+	stats := &Stats{Accumulated: &collector.InterfaceStats{}}
+	stats.Accumulated.RX.SetInt64(2700)
+	stats.Accumulated.TX.SetInt64(1400)
+	stats.LastRX.SetInt64(700)
+	stats.LastTX.SetInt64(400)
+
+	stopAccumulating(stats)
+
+	if stats.Accumulated != nil {
+		t.Error("still accumulating")
+	}
+	usedRX, usedTX := periodUsage(stats, big.NewInt(900), big.NewInt(400))
+	if usedRX.Int64() != 2900 || usedTX.Int64() != 1400 {
+		t.Errorf("period usage %s/%s, want 2900/1400", usedRX, usedTX)
+	}
+}
+
+func TestPollAfterReset(t *testing.T) {
+	config, counters := pollConfig(t, `{"month": "2026-10", "rx": 1000, "tx": 500, "last_rx": 1000, "last_tx": 500, "last_read_at": "2026-10-14T11:55:00Z", "accumulated": {"rx": 0, "tx": 0}}`)
+	write := func(rx, tx int64) {
+		t.Helper()
+		if err := os.WriteFile(counters, fmt.Appendf(nil, "rx=%d tx=%d\n", rx, tx), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 前回の読み取りの後に通信した分は、リセットしたので数えない。
+	write(5000, 2500)
+	stats, _, err := config.store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := resetBaseline(config, stats); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.store.Save(stats); err != nil {
+		t.Fatal(err)
+	}
+
+	write(5300, 2600)
+	clock.(*clockwork.FakeClock).Advance(5 * time.Minute)
+	PollNetStats(config)
+
+	stats, _, err = config.store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Accumulated == nil || stats.Accumulated.RX.Int64() != 300 || stats.Accumulated.TX.Int64() != 100 {
+		t.Errorf("accumulated %+v after the reset, want 300/100", stats.Accumulated)
+	}
+	usedRX, usedTX := periodUsage(stats, big.NewInt(5300), big.NewInt(2600))
+	if usedRX.Int64() != 300 || usedTX.Int64() != 100 {
+		t.Errorf("period usage %s/%s, want 300/100", usedRX, usedTX)
+	}
+}
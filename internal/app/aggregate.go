@@ -0,0 +1,110 @@
+package app
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/rakku1234/linux-traffic-checker/collector"
+	"github.com/rakku1234/linux-traffic-checker/report"
+)
+
+const allInterfaces = "all"
+
+func (config *Config) aggregate() bool {
+	return config.Interface == allInterfaces || config.InterfacePattern != "" || config.ContainerRuntime != ""
+}
+
+func selectedInterface(config *Config, name string) bool {
+	if report.Excluded(name, config.ExcludeInterfaces) {
+		return false
+	}
+	if config.InterfacePattern == "" {
+		return true
+	}
+	return report.MatchInterface(config.InterfacePattern, name)
+}
+
+func readAllNetworkBytes(config *Config) (map[string]*collector.InterfaceStats, error) {
+	counters, err := collector.Default.Counters()
+	if err != nil {
+		return nil, err
+	}
+	return filterCounters(counters, config), nil
+}
+
+func filterNetDev(data string, config *Config) (map[string]*collector.InterfaceStats, error) {
+	counters, err := collector.ParseNetDev(data)
+	if err != nil {
+		return nil, err
+	}
+	return filterCounters(counters, config), nil
+}
+
+// filterCounters は監視しないインターフェースを取り除く。
+func filterCounters(counters map[string]*collector.InterfaceStats, config *Config) map[string]*collector.InterfaceStats {
+	for name := range counters {
+		if !selectedInterface(config, name) {
+			delete(counters, name)
+		}
+	}
+	return counters
+}
+
+func topTalker(config *Config, usage map[string]*collector.InterfaceStats) (string, *big.Int) {
+	var topName string
+	var topUsed *big.Int
+	for _, name := range report.SortedInterfaceNames(usage) {
+		used := countedTotal(config, &usage[name].RX, &usage[name].TX)
+		if topUsed == nil || used.Cmp(topUsed) > 0 {
+			topName, topUsed = name, used
+		}
+	}
+	return topName, topUsed
+}
+
+func usageTable(config *Config, usage map[string]*collector.InterfaceStats) string {
+	rows := [][]string{{"interface", fieldLabel(config, "rx"), fieldLabel(config, "tx"), fieldLabel(config, "total")}}
+	for _, name := range report.SortedInterfaceNames(usage) {
+		used := usage[name]
+		rows = append(rows, []string{
+			name,
+			formatBytes(&used.RX),
+			formatBytes(&used.TX),
+			formatBytes(countedTotal(config, &used.RX, &used.TX)),
+		})
+	}
+	return report.Table(rows)
+}
+
+func reconcileInterfaces(stats *Stats, current map[string]*collector.InterfaceStats, monthKey string) {
+	baseline := report.Baseline{RX: &stats.RX, TX: &stats.TX, Interfaces: stats.Interfaces, Last: stats.LastInterfaces}
+	stats.Archived = append(stats.Archived, baseline.Reconcile(current, monthKey, clock.Now())...)
+}
+
+func addArchivedUsage(usage map[string]*collector.InterfaceStats, archived []report.ArchivedInterface, monthKey string) {
+	for _, entry := range archived {
+		if entry.Month == monthKey && usage != nil {
+			usage[entry.Name+tr("（消失）")] = entry.Used
+		}
+	}
+}
+
+func interfaceDisplayName(config *Config) string {
+	if config.groupByInterface() && config.InterfacePattern != "" {
+		return config.InterfacePattern
+	}
+	if config.multiInterface() {
+		return strings.Join(config.Interfaces, ", ")
+	}
+	if config.InterfacePattern != "" {
+		return config.InterfacePattern
+	}
+	if config.ContainerRuntime != "" {
+		return fmt.Sprintf(tr("コンテナ（%s）"), config.ContainerRuntime)
+	}
+	if config.Interface == allInterfaces {
+		return tr("全インターフェース")
+	}
+	return config.Interface
+}
@@ -0,0 +1,59 @@
+package app
+
+import (
+	"fmt"
+	"log/slog"
+	"math/big"
+	"time"
+
+	"github.com/rakku1234/linux-traffic-checker/notify"
+)
+
+// checkAnomaly は、締めた期間の合計が直近の期間の平均から anomaly_percent 以上
+// 離れていれば、他のアラートと同じく notifiers のすべてにアラートとして送る。
+func checkAnomaly(config *Config, history []PeriodRecord, record *PeriodRecord, budget *RetryBudget) {
+	if config.AnomalyPercent <= 0 || len(history) == 0 {
+		return
+	}
+	if len(history) > config.AnomalyPeriods {
+		history = history[len(history)-config.AnomalyPeriods:]
+	}
+
+	sum := new(big.Int)
+	for i := range history {
+		sum.Add(sum, countedTotal(config, &history[i].RX, &history[i].TX))
+	}
+	if sum.Sign() == 0 {
+		return
+	}
+	mean := new(big.Float).Quo(new(big.Float).SetInt(sum), big.NewFloat(float64(len(history))))
+
+	total := countedTotal(config, &record.RX, &record.TX)
+	ratio, _ := new(big.Float).Quo(new(big.Float).SetInt(total), mean).Float64()
+	deviation := (ratio - 1) * 100
+	if deviation < config.AnomalyPercent && -deviation < config.AnomalyPercent {
+		return
+	}
+
+	meanBytes, _ := mean.Int(nil)
+	embed := notify.Embed{
+		Title:     fmt.Sprintf(tr("%s の通信量（%s）が普段と大きく異なります"), interfaceDisplayName(config), periodLabel(record.Month)),
+		Color:     0xffa500,
+		Timestamp: clock.Now().UTC().Format(time.RFC3339),
+		Fields: []notify.EmbedField{
+			{Name: fieldLabel(config, "total"), Value: formatBytes(total), Inline: true},
+			{Name: fmt.Sprintf(tr("直近%dか月の平均"), len(history)), Value: formatBytes(meanBytes), Inline: true},
+			{Name: tr("差"), Value: fmt.Sprintf("%+.1f%%", deviation), Inline: false},
+		},
+		Interface: embedInterface(config),
+	}
+
+	err := notifyAll(config, budget, func(notifier Notifier) error {
+		return notifier.SendEmbeds(notify.KindAlert, []notify.Embed{embed})
+	})
+	if err != nil {
+		slog.Error("通知の送信エラー", "error", err)
+		return
+	}
+	slog.Warn("通信量の異常を通知しました", "month", record.Month, "deviation", deviation)
+}
@@ -0,0 +1,118 @@
+package app
+
+import (
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// historyHandler は統計に記録された締めた期間を古い順に返す。?limit=N なら最新の N 件だけ返す。
+func historyHandler(config *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allowGet(w, r) {
+			return
+		}
+
+		records, err := loadHistory(config)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("統計ファイルを読み込めません: %v", err), http.StatusServiceUnavailable)
+			return
+		}
+		if text := r.URL.Query().Get("limit"); text != "" {
+			limit, err := strconv.Atoi(text)
+			if err != nil || limit < 0 {
+				http.Error(w, fmt.Sprintf("limit が正しくありません: %q", text), http.StatusBadRequest)
+				return
+			}
+			records = records[max(0, len(records)-limit):]
+		}
+		if records == nil {
+			records = []PeriodRecord{}
+		}
+		writeJSON(w, records)
+	}
+}
+
+var statusPage = template.Must(template.New("status").Parse(`<!DOCTYPE html>
+<html lang="{{.Lang}}">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>linux-traffic-checker</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.8em; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<table>
+<tr><th>{{.InterfaceLabel}}</th><th>{{.RXLabel}}</th><th>{{.TXLabel}}</th><th>{{.TotalLabel}}</th><th>{{.LastReadLabel}}</th></tr>
+{{range .Rows}}<tr><td>{{.Interface}}</td><td>{{.RX}}</td><td>{{.TX}}</td><td>{{.Total}}</td><td>{{.Note}}</td></tr>
+{{end}}</table>
+<p>{{.GeneratedAt}}</p>
+</body>
+</html>
+`))
+
+type statusPageRow struct {
+	Interface, RX, TX, Total, Note string
+}
+
+// statusPageHandler はインターフェースごとの今期の通信量を簡単なHTMLのページとして表示する。
+func statusPageHandler(config *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allowGet(w, r) {
+			return
+		}
+
+		status, err := buildStatus(config)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("統計ファイルを読み込めません: %v", err), http.StatusServiceUnavailable)
+			return
+		}
+
+		data := struct {
+			Lang, Title                                  string
+			InterfaceLabel, RXLabel, TXLabel, TotalLabel string
+			LastReadLabel                                string
+			Rows                                         []statusPageRow
+			GeneratedAt                                  string
+		}{
+			Lang:           language,
+			Title:          fmt.Sprintf(tr("通信量（%s）"), periodLabel(status.Period)),
+			InterfaceLabel: tr("インターフェース"),
+			RXLabel:        fieldLabel(config, "rx"),
+			TXLabel:        fieldLabel(config, "tx"),
+			TotalLabel:     fieldLabel(config, "total"),
+			LastReadLabel:  tr("最終読み取り"),
+			GeneratedAt:    fmt.Sprintf(tr("%s 時点"), status.GeneratedAt.In(config.now().Location()).Format("2006-01-02 15:04:05")),
+		}
+		for _, item := range status.Interfaces {
+			row := statusPageRow{
+				Interface: item.Interface,
+				RX:        item.UsedRXText,
+				TX:        item.UsedTXText,
+				Total:     item.UsedTotalText,
+				Note:      tr("現在"),
+			}
+			if !item.Live {
+				row.Note = item.ReadError
+				if item.LastRead != nil {
+					row.Note = item.LastRead.In(config.now().Location()).Format("2006-01-02 15:04:05") + "（" + item.ReadError + "）"
+				}
+			}
+			data.Rows = append(data.Rows, row)
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		err = statusPage.Execute(w, data)
+		if err != nil {
+			slog.Error("ステータスページの出力に失敗", "error", err)
+		}
+	}
+}
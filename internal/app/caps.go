@@ -0,0 +1,156 @@
+package app
+
+import (
+	"fmt"
+	"log/slog"
+	"math/big"
+	"time"
+
+	"github.com/rakku1234/linux-traffic-checker/notify"
+)
+
+type capCheck struct {
+	key   string
+	label string
+	used  *big.Int
+	limit int64
+}
+
+func capUsage(used *big.Int, limit int64) string {
+	percent := new(big.Float).Quo(new(big.Float).SetInt(used), big.NewFloat(float64(limit)))
+	value, _ := percent.Float64()
+	return fmt.Sprintf(tr("%s / %s（%.1f%%）"), formatBytes(used), formatBytes(big.NewInt(limit)), value*100)
+}
+
+func capAlertEmbed(config *Config, check *capCheck) notify.Embed {
+	return notify.Embed{
+		Title:     fmt.Sprintf(tr("%s の%sが上限を超えました"), interfaceDisplayName(config), check.label),
+		Color:     0xff0000,
+		Timestamp: clock.Now().UTC().Format(time.RFC3339),
+		Fields: []notify.EmbedField{
+			{Name: check.label, Value: capUsage(check.used, check.limit), Inline: false},
+		},
+	}
+}
+
+// capChecks は config.CapBasis に従って適用する上限を返す。count_direction はレポートの表示だけを
+// 決めるもので、上限の対象を変えないよう、合計の上限は常に受信＋送信で数える。
+func capChecks(config *Config, usedRX, usedTX *big.Int) []capCheck {
+	total := capCheck{key: "total", label: fmt.Sprintf(tr("%s通信量"), fieldLabel(config, "total")), used: new(big.Int).Add(usedRX, usedTX), limit: config.CapBytes}
+	rx := capCheck{key: "rx", label: fmt.Sprintf(tr("%s通信量"), fieldLabel(config, "rx")), used: usedRX, limit: config.RXCapBytes}
+	tx := capCheck{key: "tx", label: fmt.Sprintf(tr("%s通信量"), fieldLabel(config, "tx")), used: usedTX, limit: config.TXCapBytes}
+
+	switch config.CapBasis {
+	case "combined":
+		return []capCheck{total}
+	case "rx":
+		return []capCheck{rx}
+	case "tx":
+		return []capCheck{tx}
+	case "both":
+		return []capCheck{rx, tx}
+	default:
+		return []capCheck{total, rx, tx}
+	}
+}
+
+// checkCaps は上限を超えた月に1回、通知先とPagerDutyにそれぞれアラートを送る。
+// 片方が失敗しても、もう片方には送り、送れた送信先だけを CapAlerts に記録する
+// ので、失敗した送信先には次の読み取り時に送り直す。
+func checkCaps(config *Config, stats *Stats, monthKey string, usedRX, usedTX *big.Int, budget *RetryBudget) {
+	checks := capChecks(config, usedRX, usedTX)
+
+	mark := func(key string) {
+		if stats.CapAlerts == nil {
+			stats.CapAlerts = map[string]string{}
+		}
+		stats.CapAlerts[key] = monthKey
+	}
+	for i := range checks {
+		check := &checks[i]
+		pageKey := "pagerduty:" + check.key
+		notified := stats.CapAlerts[check.key] == monthKey
+		paged := config.PagerDutyRoutingKey == "" || stats.CapAlerts[pageKey] == monthKey
+		if check.limit <= 0 || notified && paged {
+			continue
+		}
+		if check.used.Cmp(big.NewInt(check.limit)) < 0 {
+			continue
+		}
+
+		if !notified {
+			err := notifyAll(config, budget, func(notifier Notifier) error {
+				return notifier.SendEmbeds(notify.KindAlert, []notify.Embed{capAlertEmbed(config, check)})
+			})
+			if err != nil {
+				slog.Error("通知の送信エラー", "error", err)
+			} else {
+				mark(check.key)
+				slog.Warn("通信量の上限超過を通知しました", "cap", check.key, "used", check.used.String())
+			}
+		}
+
+		if !paged {
+			style := messageStyle(config, "pagerduty", notify.KindAlert)
+			summary := limitText(config, "pagerduty", style.title(fmt.Sprintf(tr("%s の%sが上限を超えました（%s）"), interfaceDisplayName(config), check.label, capUsage(check.used, check.limit))))
+			dedupKey := fmt.Sprintf("linux-traffic-checker-%s-%s-%s", config.Interface, check.key, monthKey)
+			pagerDuty := notify.PagerDuty{RoutingKey: config.PagerDutyRoutingKey, Client: httpClient}
+			err := withRetry(budget, "pagerduty", func() error {
+				return pagerDuty.Trigger(dedupKey, summary)
+			})
+			if err != nil {
+				slog.Error("PagerDutyへの送信エラー", "error", err)
+			} else {
+				mark(pageKey)
+			}
+		}
+	}
+}
+
+func thresholdEmbed(config *Config, used, threshold *big.Int) notify.Embed {
+	limit := threshold
+	if config.CapBytes > 0 {
+		limit = big.NewInt(config.CapBytes)
+	}
+	percent := new(big.Float).Quo(new(big.Float).SetInt(used), new(big.Float).SetInt(limit))
+	value, _ := percent.Float64()
+	return notify.Embed{
+		Title:     fmt.Sprintf(tr("%s の通信量が警告値 %s を超えました"), interfaceDisplayName(config), formatBytes(threshold)),
+		Color:     0xff0000,
+		Timestamp: clock.Now().UTC().Format(time.RFC3339),
+		Fields: []notify.EmbedField{
+			{Name: tr("今月の使用量"), Value: formatBytes(used), Inline: true},
+			{Name: tr("上限に対する割合"), Value: fmt.Sprintf(tr("%.1f%%（上限 %s）"), value*100, formatBytes(limit)), Inline: true},
+		},
+		Interface: embedInterface(config),
+	}
+}
+
+// checkThreshold は今月の合計が警告値を超えたときに、月に1回だけ警告する（alert_webhook_url があればそこに送る）。
+// AlertedThreshold は新しい月が始まると元に戻る。
+func checkThreshold(config *Config, stats *Stats, usedRX, usedTX *big.Int, budget *RetryBudget) {
+	if config.threshold == nil || stats.AlertedThreshold {
+		return
+	}
+	used := countedTotal(config, usedRX, usedTX)
+	if used.Cmp(config.threshold) < 0 {
+		return
+	}
+
+	alertConfig := *config
+	if config.AlertWebhookURL != "" {
+		alertConfig.WebhookURL = config.AlertWebhookURL
+		alertConfig.WebhookURLs = nil
+		alertConfig.DiscordWebhooks = nil
+	}
+	err := notifyAll(&alertConfig, budget, func(notifier Notifier) error {
+		return notifier.SendEmbeds(notify.KindAlert, []notify.Embed{thresholdEmbed(config, used, config.threshold)})
+	})
+	if err != nil {
+		slog.Error("通知の送信エラー", "error", err)
+		return
+	}
+
+	stats.AlertedThreshold = true
+	slog.Warn("通信量の警告値超過を通知しました", "interface", config.Interface, "used", used.String())
+}
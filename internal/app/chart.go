@@ -0,0 +1,157 @@
+package app
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"log/slog"
+	"math/big"
+	"strconv"
+	"time"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	chartWidth  = 720
+	chartHeight = 300
+	// ラベルを書く余白を除いたグラフの領域。
+	chartLeft   = 70
+	chartRight  = chartWidth - 20
+	chartTop    = 20
+	chartBottom = chartHeight - 30
+)
+
+var (
+	chartBackground = color.RGBA{0x2f, 0x31, 0x36, 0xff}
+	chartGrid       = color.RGBA{0x4f, 0x54, 0x5c, 0xff}
+	chartText       = color.RGBA{0xdc, 0xdd, 0xde, 0xff}
+)
+
+// dailySeries は history_db から、今期のこれまでの日ごとの通信量を監視するインターフェースの合計で返す。
+func dailySeries(config *Config, record *PeriodRecord) ([]time.Time, []*big.Int, error) {
+	start, end, err := periodBounds(record.Month, config.location)
+	if err != nil {
+		return nil, nil, err
+	}
+	if now := config.now(); now.Before(end) {
+		end = now
+	}
+
+	var days []time.Time
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		days = append(days, day)
+	}
+	if len(days) == 0 {
+		return nil, nil, nil
+	}
+	first, last := periodKey("daily", days[0]), periodKey("daily", days[len(days)-1])
+
+	used := map[string]*big.Int{}
+	for _, name := range monitoredNames(config) {
+		entries, err := loadUsage(config.HistoryDB, name, dailyBucket, last, len(days))
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, entry := range entries {
+			if entry.Key < first {
+				continue
+			}
+			if used[entry.Key] == nil {
+				used[entry.Key] = new(big.Int)
+			}
+			used[entry.Key].Add(used[entry.Key], countedTotal(config, &entry.RX, &entry.TX))
+		}
+	}
+
+	values := make([]*big.Int, len(days))
+	for i, day := range days {
+		values[i] = used[periodKey("daily", day)]
+		if values[i] == nil {
+			values[i] = new(big.Int)
+		}
+	}
+	return days, values, nil
+}
+
+func fillRect(img *image.RGBA, x0, y0, x1, y1 int, c color.Color) {
+	draw.Draw(img, image.Rect(x0, y0, x1, y1), image.NewUniform(c), image.Point{}, draw.Src)
+}
+
+// drawLabel は ASCII の文字列をベースラインを y に合わせて書く。alignRight なら x で終わるように、
+// そうでなければ x から書く。
+func drawLabel(img *image.RGBA, x, y int, text string, alignRight bool) {
+	drawer := font.Drawer{Dst: img, Src: image.NewUniform(chartText), Face: basicfont.Face7x13}
+	if alignRight {
+		x -= drawer.MeasureString(text).Round()
+	}
+	drawer.Dot = fixed.P(x, y)
+	drawer.DrawString(text)
+}
+
+// renderChart は最も通信量の多い日に合わせた縮尺で1日1本の棒を描き、その半分と全体の位置に目盛り線を引く。
+// 組み込みのフォントに日本語がないため、ラベルは ASCII のまま。
+func renderChart(config *Config, days []time.Time, values []*big.Int) ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, chartWidth, chartHeight))
+	fillRect(img, 0, 0, chartWidth, chartHeight, chartBackground)
+
+	peak := new(big.Int)
+	for _, value := range values {
+		if value.Cmp(peak) > 0 {
+			peak.Set(value)
+		}
+	}
+	height := chartBottom - chartTop
+	for _, step := range []int64{0, 1, 2} {
+		y := chartBottom - height*int(step)/2
+		fillRect(img, chartLeft, y, chartRight, y+1, chartGrid)
+		level := new(big.Int).Quo(new(big.Int).Mul(peak, big.NewInt(step)), big.NewInt(2))
+		drawLabel(img, chartLeft-6, y+4, formatBytes(level), true)
+	}
+
+	bar := color.RGBA{0x00, 0xbf, 0xff, 0xff}
+	if rgb := embedColor(config); rgb != 0 {
+		bar = color.RGBA{uint8(rgb >> 16), uint8(rgb >> 8), uint8(rgb), 0xff}
+	}
+	slot := float64(chartRight-chartLeft) / float64(len(days))
+	// 日付が重ならないよう、数日おきにラベルを付ける。
+	every := max(1, int(24/slot)+1)
+	for i, value := range values {
+		x0 := chartLeft + int(float64(i)*slot+slot*0.15)
+		x1 := chartLeft + int(float64(i+1)*slot-slot*0.15)
+		if peak.Sign() > 0 && value.Sign() > 0 {
+			ratio, _ := new(big.Float).Quo(new(big.Float).SetInt(value), new(big.Float).SetInt(peak)).Float64()
+			top := chartBottom - max(1, int(ratio*float64(height)))
+			fillRect(img, x0, top, max(x1, x0+1), chartBottom, bar)
+		}
+		if i%every == 0 {
+			drawLabel(img, (x0+x1)/2-7, chartBottom+16, strconv.Itoa(days[i].Day()), false)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// usageChart は record の期間の日ごとの通信量をPNGで描く。history_db にその期間の記録がなければ false を返す。
+func usageChart(config *Config, record *PeriodRecord) ([]byte, bool) {
+	days, values, err := dailySeries(config, record)
+	if err == nil && len(days) > 0 {
+		var data []byte
+		data, err = renderChart(config, days, values)
+		if err == nil {
+			return data, true
+		}
+	}
+	if err != nil {
+		slog.Warn("グラフの作成エラー", "error", err)
+	}
+	return nil, false
+}
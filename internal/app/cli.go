@@ -0,0 +1,179 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/rakku1234/linux-traffic-checker/collector"
+	"github.com/rakku1234/linux-traffic-checker/notify"
+	"github.com/rakku1234/linux-traffic-checker/report"
+)
+
+// subcommands は設定と統計に対して1回だけ実行する。常駐中のスケジューラーがあってもそのまま並べて実行し、
+// スケジュールは変えない。
+var subcommands = map[string]func(config *Config) int{
+	"report-now":  runReportNow,
+	"status":      runStatus,
+	"reset":       runReset,
+	"test-notify": runTestNotify,
+	"import":      runImport,
+	"export":      runExport,
+	"doctor":      runDoctor,
+}
+
+// splitSubcommand は "linux-traffic-checker status -config config.json" のようにフラグより前に書いた
+// サブコマンドを取り出す。
+func splitSubcommand(args []string) (string, []string) {
+	if len(args) > 0 {
+		if _, ok := subcommands[args[0]]; ok {
+			return args[0], args[1:]
+		}
+	}
+	return "", args
+}
+
+// runReportNow は今期のこれまでの通信量を送信する。期間は締めず、統計もそのまま残す。
+func runReportNow(config *Config) int {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+
+	unlock, err := lockStats(config)
+	if err != nil {
+		slog.Error("統計ファイルのロック取得エラー", "error", err)
+		return 1
+	}
+	defer unlock()
+
+	stats, isFirstRun, err := config.store.Load()
+	if err != nil {
+		slog.Error("統計ファイルの読み込みエラー", "error", err)
+		return 1
+	}
+	if isFirstRun {
+		slog.Error("まだベースラインが記録されていません。先に通常の起動でベースラインを記録してください")
+		return 1
+	}
+
+	var records []scopedRecord
+	for _, scope := range interfaceScopes(config, stats) {
+		if scope.created {
+			continue
+		}
+		device, err := resolveInterface(scope.config)
+		var currentRX, currentTX big.Int
+		var perInterface map[string]*collector.InterfaceStats
+		if err == nil {
+			currentRX, currentTX, perInterface, err = readCounters(device)
+		}
+		if err != nil {
+			slog.Error("ネットワーク統計の読み込みエラー", "interface", scope.config.Interface, "error", err)
+			return 1
+		}
+		followDefaultRoute(scope.config, device.Interface, scope.stats, &currentRX, &currentTX)
+		usedRX, usedTX := periodUsage(scope.stats, &currentRX, &currentTX)
+		if usedRX.Sign() < 0 || usedTX.Sign() < 0 {
+			slog.Error("カウンタがベースラインより小さいため今期の通信量を計算できません", "interface", scope.config.Interface)
+			return 1
+		}
+		record := &PeriodRecord{Month: scope.stats.Month, RX: *usedRX, TX: *usedTX}
+		record.Interfaces = report.InterfaceUsage(perInterface, scope.stats.Interfaces)
+		record.TopInterface, record.TopBytes = topTalker(scope.config, record.Interfaces)
+		records = append(records, scopedRecord{config: scope.config, stats: scope.stats, record: record})
+	}
+	if len(records) == 0 {
+		slog.Error("レポートできるインターフェースがありません")
+		return 1
+	}
+
+	retryBudget, _ := time.ParseDuration(config.RetryBudget)
+	err = sendRecords(config, stats, records, newRetryBudget(retryBudget))
+	if err != nil {
+		reportNotifyFailure(err)
+		return oneShotExitCode()
+	}
+	slog.Info("今期の途中経過を送信しました", "period", stats.Month)
+	return exitOK
+}
+
+func writeStatus(w io.Writer, config *Config, status *Status) {
+	fmt.Fprintf(w, "%s（%s 時点）\n", periodLabel(status.Period), status.GeneratedAt.In(config.now().Location()).Format("2006-01-02 15:04:05"))
+	for _, item := range status.Interfaces {
+		line := fmt.Sprintf("%s: %s %s / %s %s / %s %s", item.Interface,
+			fieldLabel(config, "rx"), formatBytes(item.UsedRX),
+			fieldLabel(config, "tx"), formatBytes(item.UsedTX),
+			fieldLabel(config, "total"), formatBytes(item.UsedTotal))
+		if !item.Live {
+			line += "（" + item.ReadError + "）"
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+// runStatus は今期のこれまでの通信量を表示する。
+func runStatus(config *Config) int {
+	status, err := buildStatus(config)
+	if err != nil {
+		slog.Error("統計ファイルの読み込みエラー", "error", err)
+		return 1
+	}
+	writeStatus(os.Stdout, config, status)
+	return exitOK
+}
+
+// runReset は今読み取ったカウンタから今期を数え直す。
+func runReset(config *Config) int {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+
+	unlock, err := lockStats(config)
+	if err != nil {
+		slog.Error("統計ファイルのロック取得エラー", "error", err)
+		return 1
+	}
+	defer unlock()
+
+	stats, _, err := config.store.Load()
+	if err != nil {
+		slog.Error("統計ファイルの読み込みエラー", "error", err)
+		return 1
+	}
+	err = resetBaseline(config, stats)
+	if err != nil {
+		slog.Error("ネットワーク統計の読み込みエラー", "error", err)
+		return 1
+	}
+	err = config.store.Save(stats)
+	if err != nil {
+		slog.Error("統計ファイルの保存エラー", "error", err)
+		return 1
+	}
+	slog.Info("今期の集計をリセットしました", "period", stats.Month)
+	return exitOK
+}
+
+// runTestNotify は Webhook やトークンが使えるか確かめるため、すべての通知先にダミーのメッセージを送る。
+func runTestNotify(config *Config) int {
+	embed := notify.Embed{
+		Title:     tr("linux-traffic-checker のテスト通知"),
+		Color:     0x00bfff,
+		Timestamp: clock.Now().UTC().Format(time.RFC3339),
+		Fields: []notify.EmbedField{
+			{Name: tr("インターフェース"), Value: interfaceDisplayName(config), Inline: true},
+			{Name: tr("期間"), Value: config.Period, Inline: true},
+		},
+	}
+	retryBudget, _ := time.ParseDuration(config.RetryBudget)
+	err := notifyAll(config, newRetryBudget(retryBudget), func(notifier Notifier) error {
+		return notifier.SendEmbeds(notify.KindReport, []notify.Embed{embed})
+	})
+	if err != nil {
+		reportNotifyFailure(err)
+		return oneShotExitCode()
+	}
+	slog.Info("テスト通知を送信しました", "notifiers", config.Notifiers)
+	return exitOK
+}
@@ -0,0 +1,88 @@
+package app
+
+import (
+	"time"
+
+	"github.com/jonboulle/clockwork"
+)
+
+var clock clockwork.Clock = clockwork.NewRealClock()
+
+const averageMonth = time.Duration(30.436875 * 24 * float64(time.Hour))
+
+type scaledClock struct {
+	start  time.Time
+	origin time.Time
+	factor float64
+}
+
+func newScaledClock(month time.Duration) *scaledClock {
+	now := time.Now()
+	return &scaledClock{
+		start:  now,
+		origin: now,
+		factor: float64(averageMonth) / float64(month),
+	}
+}
+
+func (c *scaledClock) real(d time.Duration) time.Duration {
+	return time.Duration(float64(d) / c.factor)
+}
+
+func (c *scaledClock) After(d time.Duration) <-chan time.Time {
+	return time.After(c.real(d))
+}
+
+func (c *scaledClock) Sleep(d time.Duration) {
+	time.Sleep(c.real(d))
+}
+
+func (c *scaledClock) Now() time.Time {
+	return c.start.Add(time.Duration(float64(time.Since(c.origin)) * c.factor))
+}
+
+func (c *scaledClock) Since(t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+func (c *scaledClock) Until(t time.Time) time.Duration {
+	return t.Sub(c.Now())
+}
+
+func (c *scaledClock) NewTicker(d time.Duration) clockwork.Ticker {
+	return &scaledTicker{Ticker: time.NewTicker(c.real(d)), clock: c}
+}
+
+func (c *scaledClock) NewTimer(d time.Duration) clockwork.Timer {
+	return &scaledTimer{Timer: time.NewTimer(c.real(d)), clock: c}
+}
+
+func (c *scaledClock) AfterFunc(d time.Duration, f func()) clockwork.Timer {
+	return &scaledTimer{Timer: time.AfterFunc(c.real(d), f), clock: c}
+}
+
+type scaledTicker struct {
+	*time.Ticker
+	clock *scaledClock
+}
+
+func (t *scaledTicker) Chan() <-chan time.Time {
+	return t.C
+}
+
+func (t *scaledTicker) Reset(d time.Duration) {
+	t.Ticker.Reset(t.clock.real(d))
+}
+
+type scaledTimer struct {
+	*time.Timer
+	clock *scaledClock
+}
+
+func (t *scaledTimer) Chan() <-chan time.Time {
+	return t.C
+}
+
+func (t *scaledTimer) Reset(d time.Duration) bool {
+	return t.Timer.Reset(t.clock.real(d))
+}
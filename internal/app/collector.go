@@ -0,0 +1,333 @@
+package app
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rakku1234/linux-traffic-checker/notify"
+)
+
+// agentMessage はエージェントが集約サーバーに送る内容で、レポートか、そのまま転送するアラートの埋め込み。
+type agentMessage struct {
+	Host   string         `json:"host"`
+	Kind   notify.Kind    `json:"kind"`
+	Report *Report        `json:"report,omitempty"`
+	Embeds []notify.Embed `json:"embeds,omitempty"`
+}
+
+// FleetReport はエージェントから受け取ったレポートで、同じ期間の他のホストのレポートとまとめて送るまで待つ。
+type FleetReport struct {
+	Host   string `json:"host"`
+	Report Report `json:"report"`
+}
+
+// CollectorNotifier は通知先 "collector" で、チャットサービスではなく collector_url の集約サーバーに送る。
+type CollectorNotifier struct {
+	config *Config
+}
+
+func (n *CollectorNotifier) Send(report Report) error {
+	return n.post(agentMessage{Host: n.config.AgentName, Kind: notify.KindReport, Report: &report})
+}
+
+func (n *CollectorNotifier) SendEmbeds(kind notify.Kind, embeds []notify.Embed) error {
+	return n.post(agentMessage{Host: n.config.AgentName, Kind: kind, Embeds: embeds})
+}
+
+func (n *CollectorNotifier) post(message agentMessage) error {
+	if dryRun {
+		slog.Info("[dry-run] 集約サーバーへの送信をスキップします", "url", n.config.CollectorURL, "kind", message.Kind)
+		return nil
+	}
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(n.config.CollectorURL, "/")+"/api/v1/agent", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+n.config.CollectorToken)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(resp.Body)
+		return notify.NewHTTPStatusError("collector", resp, body)
+	}
+	return nil
+}
+
+// forwardingConfig は通知先 "collector" を除いた config。自分自身にも報告する集約サーバーが
+// 送信を繰り返さないようにする。
+func forwardingConfig(config *Config) *Config {
+	forward := *config
+	forward.Notifiers = slices.DeleteFunc(slices.Clone(config.Notifiers), func(name string) bool {
+		return name == "collector"
+	})
+	return &forward
+}
+
+func fleetUsage(config *Config, report *Report) string {
+	return fmt.Sprintf("%s %s / %s %s / %s %s",
+		fieldLabel(config, "rx"), formatBytes(report.RX),
+		fieldLabel(config, "tx"), formatBytes(report.TX),
+		fieldLabel(config, "total"), formatBytes(report.Total))
+}
+
+// fleetEmbed は期間のホストごとの通信量を並べ、その後に全ホストの合計と、報告のなかったホストを載せる。
+func fleetEmbed(config *Config, period string, reports []FleetReport) notify.Embed {
+	slices.SortFunc(reports, func(a, b FleetReport) int { return strings.Compare(a.Host, b.Host) })
+
+	total := Report{RX: new(big.Int), TX: new(big.Int), Total: new(big.Int)}
+	embed := notify.Embed{
+		Title:     fmt.Sprintf(tr("%d台の通信量（%s）"), len(reports), periodLabel(period)),
+		Color:     embedColor(config),
+		Timestamp: clock.Now().UTC().Format(time.RFC3339),
+	}
+	for _, fleet := range reports {
+		embed.Fields = append(embed.Fields, notify.EmbedField{Name: fleet.Host, Value: fleetUsage(config, &fleet.Report), Inline: false})
+		total.RX.Add(total.RX, fleet.Report.RX)
+		total.TX.Add(total.TX, fleet.Report.TX)
+		total.Total.Add(total.Total, fleet.Report.Total)
+	}
+	embed.Fields = append(embed.Fields, notify.EmbedField{Name: tr("全体の合計"), Value: fleetUsage(config, &total), Inline: false})
+
+	var missing []string
+	for _, host := range config.CollectorHosts {
+		if !slices.ContainsFunc(reports, func(fleet FleetReport) bool { return fleet.Host == host }) {
+			missing = append(missing, host)
+		}
+	}
+	if len(missing) > 0 {
+		embed.Fields = append(embed.Fields, notify.EmbedField{Name: tr("未報告"), Value: strings.Join(missing, ", "), Inline: false})
+	}
+	return embed
+}
+
+// fleetPeriods は送信待ちのレポートの期間を古い順に返す。
+func fleetPeriods(reports []FleetReport) []string {
+	var periods []string
+	for _, fleet := range reports {
+		if !slices.Contains(periods, fleet.Report.Period) {
+			periods = append(periods, fleet.Report.Period)
+		}
+	}
+	slices.Sort(periods)
+	return periods
+}
+
+// fleetComplete は collector_hosts のすべてのホストが period を報告したかどうかを返す。
+func fleetComplete(config *Config, reports []FleetReport, period string) bool {
+	if len(config.CollectorHosts) == 0 {
+		return false
+	}
+	for _, host := range config.CollectorHosts {
+		if !slices.ContainsFunc(reports, func(fleet FleetReport) bool {
+			return fleet.Host == host && fleet.Report.Period == period
+		}) {
+			return false
+		}
+	}
+	return true
+}
+
+// fleetSendMu は同じ期間のまとめを二重に送らないよう、集約したレポートの送信を直列にする。
+var fleetSendMu sync.Mutex
+
+// sendFleet は periods（nil ならすべての期間）のそれぞれについて送信待ちのレポートをまとめたメッセージを1つ送り、
+// 送ったレポートを統計から取り除く。送信の間は統計のロックを持たない。送れなかったレポートは送信待ちのまま残る。
+func sendFleet(config *Config, periods []string) error {
+	fleetSendMu.Lock()
+	defer fleetSendMu.Unlock()
+
+	pending, err := loadFleetReports(config)
+	if err != nil {
+		return err
+	}
+	if periods == nil {
+		periods = fleetPeriods(pending)
+	}
+	if len(pending) == 0 {
+		slog.Info("送信するエージェントのレポートがありません")
+		return nil
+	}
+
+	forward := forwardingConfig(config)
+	retryBudget, _ := time.ParseDuration(config.RetryBudget)
+	budget := newRetryBudget(retryBudget)
+	var sent []FleetReport
+	for _, period := range periods {
+		var reports []FleetReport
+		for _, fleet := range pending {
+			if fleet.Report.Period == period {
+				reports = append(reports, fleet)
+			}
+		}
+		if len(reports) == 0 {
+			continue
+		}
+		embed := fleetEmbed(config, period, reports)
+		err := notifyAll(forward, budget, func(notifier Notifier) error {
+			return notifier.SendEmbeds(notify.KindReport, []notify.Embed{embed})
+		})
+		if err != nil {
+			reportNotifyFailure(err)
+			continue
+		}
+		sent = append(sent, reports...)
+		slog.Info("全ホストの通信量を送信しました", "period", period, "hosts", len(reports))
+	}
+	if len(sent) == 0 {
+		return nil
+	}
+
+	// 送信中に同じホストが同じ期間を再び報告していれば、その新しいレポートは残す。
+	return withFleetStats(config, func(stats *Stats) {
+		stats.FleetReports = slices.DeleteFunc(stats.FleetReports, func(fleet FleetReport) bool {
+			return slices.ContainsFunc(sent, func(s FleetReport) bool {
+				return fleet.Host == s.Host && fleet.Report.Period == s.Report.Period && fleet.Report.GeneratedAt.Equal(s.Report.GeneratedAt)
+			})
+		})
+	})
+}
+
+// loadFleetReports は統計のロックを取って、送信待ちのエージェントのレポートを読み込む。
+func loadFleetReports(config *Config) ([]FleetReport, error) {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+
+	unlock, err := lockStats(config)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	stats, _, err := config.store.Load()
+	if err != nil {
+		return nil, err
+	}
+	return stats.FleetReports, nil
+}
+
+// withFleetStats は統計のロックを取って集約サーバーの統計に update を実行し、その後で保存する。
+func withFleetStats(config *Config, update func(stats *Stats)) error {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+
+	unlock, err := lockStats(config)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	stats, _, err := config.store.Load()
+	if err != nil {
+		return err
+	}
+	update(stats)
+	return config.store.Save(stats)
+}
+
+// SendFleetReport は collector_hosts のすべてのホストが報告したかどうかに関わらず、
+// collector_schedule の時刻に送信待ちのレポートを送る。
+func SendFleetReport(config *Config) {
+	if err := sendFleet(config, nil); err != nil {
+		slog.Error("統計ファイルの更新エラー", "error", err)
+	}
+}
+
+func collectorHandler(config *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(config.CollectorToken)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		var message agentMessage
+		err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&message)
+		if err != nil || message.Host == "" || (message.Report == nil && len(message.Embeds) == 0) ||
+			(message.Report != nil && (message.Report.Period == "" || message.Report.RX == nil || message.Report.TX == nil || message.Report.Total == nil)) {
+			http.Error(w, "invalid message", http.StatusBadRequest)
+			return
+		}
+
+		if message.Report == nil {
+			// アラートは他のホストを待たない。
+			for i := range message.Embeds {
+				message.Embeds[i].Title = fmt.Sprintf("[%s] %s", message.Host, message.Embeds[i].Title)
+			}
+			retryBudget, _ := time.ParseDuration(config.RetryBudget)
+			err := notifyAll(forwardingConfig(config), newRetryBudget(retryBudget), func(notifier Notifier) error {
+				return notifier.SendEmbeds(message.Kind, message.Embeds)
+			})
+			if err != nil {
+				slog.Error("エージェントのアラートの転送エラー", "host", message.Host, "error", err)
+				http.Error(w, "forwarding failed", http.StatusBadGateway)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		var complete bool
+		err = withFleetStats(config, func(stats *Stats) {
+			// 同じ期間を再び報告したホストは、前のレポートを置き換える。
+			stats.FleetReports = slices.DeleteFunc(stats.FleetReports, func(fleet FleetReport) bool {
+				return fleet.Host == message.Host && fleet.Report.Period == message.Report.Period
+			})
+			stats.FleetReports = append(stats.FleetReports, FleetReport{Host: message.Host, Report: *message.Report})
+			slog.Info("エージェントのレポートを受信しました", "host", message.Host, "period", message.Report.Period)
+			complete = fleetComplete(config, stats.FleetReports, message.Report.Period)
+		})
+		if err != nil {
+			slog.Error("統計ファイルの更新エラー", "error", err)
+			http.Error(w, "could not store the report", http.StatusServiceUnavailable)
+			return
+		}
+		// レポートは保存できたので、まとめの送信に失敗しても送信待ちとして残り、collector_schedule で送り直す。
+		if complete {
+			if err := sendFleet(config, []string{message.Report.Period}); err != nil {
+				slog.Error("統計ファイルの更新エラー", "error", err)
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// startCollectorServer はバックグラウンドでエージェントのメッセージを受け取る。返したサーバーは終了時に Main が止める。
+func startCollectorServer(config *Config) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/agent", collectorHandler(config))
+	server := &http.Server{Addr: config.CollectorAddr, Handler: mux}
+
+	go func() {
+		slog.Info("集約サーバーを起動しました", "addr", config.CollectorAddr)
+		err := server.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("集約サーバーのエラー", "error", err)
+		}
+	}()
+	return server
+}
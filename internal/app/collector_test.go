@@ -0,0 +1,59 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCollectorSendsWithoutStatsLock(t *testing.T) {
+	config, _ := pollConfig(t, `{"month": "2026-10"}`)
+	config.CollectorHosts = []string{"web1"}
+	config.CollectorToken = "secret"
+
+	// 送信の間に他のリクエストが統計を読み書きできること。
+	var sent, locked atomic.Int32
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent.Add(1)
+		check := *config
+		check.LockTimeout = "0s"
+		if !statsMu.TryLock() {
+			locked.Add(1)
+		} else {
+			statsMu.Unlock()
+		}
+		if unlock, err := lockStats(&check); err != nil {
+			locked.Add(1)
+		} else {
+			unlock()
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(webhook.Close)
+	config.WebhookURL = webhook.URL
+
+	body := `{"host": "web1", "report": {"period": "2026-09", "rx_bytes": 1000, "tx_bytes": 500, "total_bytes": 1500}}`
+	request := httptest.NewRequest(http.MethodPost, "/api/v1/agent", strings.NewReader(body))
+	request.Header.Set("Authorization", "Bearer secret")
+	recorder := httptest.NewRecorder()
+	collectorHandler(config)(recorder, request)
+	if recorder.Code != http.StatusNoContent {
+		t.Fatalf("status %d: %s", recorder.Code, recorder.Body)
+	}
+	if sent.Load() != 1 {
+		t.Fatalf("sent %d messages, want 1", sent.Load())
+	}
+	if locked.Load() != 0 {
+		t.Error("the stats lock was held while sending")
+	}
+
+	stats, _, err := config.store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats.FleetReports) != 0 {
+		t.Errorf("%d fleet reports still pending after sending", len(stats.FleetReports))
+	}
+}
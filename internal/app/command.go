@@ -0,0 +1,46 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"os/exec"
+	"regexp"
+	"time"
+)
+
+const counterCommandTimeout = 30 * time.Second
+
+func readCommandCounters(command, pattern string) (big.Int, big.Int, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return big.Int{}, big.Int{}, fmt.Errorf("counter_regex が不正です: %w", err)
+	}
+	rxIndex, txIndex := re.SubexpIndex("rx"), re.SubexpIndex("tx")
+	if rxIndex < 0 || txIndex < 0 {
+		return big.Int{}, big.Int{}, fmt.Errorf("counter_regex には名前付きグループ rx と tx が必要です")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), counterCommandTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, "sh", "-c", command).Output()
+	if err != nil {
+		return big.Int{}, big.Int{}, fmt.Errorf("counter_command の実行に失敗: %w", err)
+	}
+
+	match := re.FindSubmatch(output)
+	if match == nil {
+		return big.Int{}, big.Int{}, fmt.Errorf("counter_command の出力が counter_regex に一致しません")
+	}
+
+	var rx, tx big.Int
+	if _, ok := rx.SetString(string(match[rxIndex]), 10); !ok {
+		return big.Int{}, big.Int{}, fmt.Errorf("受信バイト数を解析できません: %q", match[rxIndex])
+	}
+	if _, ok := tx.SetString(string(match[txIndex]), 10); !ok {
+		return big.Int{}, big.Int{}, fmt.Errorf("送信バイト数を解析できません: %q", match[txIndex])
+	}
+
+	return rx, tx, nil
+}
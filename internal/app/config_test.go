@@ -0,0 +1,209 @@
+package app
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// withHomeDir は userHomeDir をテストの間だけ差し替える。
+func withHomeDir(t *testing.T, resolve func() (string, error)) {
+	t.Helper()
+	saved := userHomeDir
+	userHomeDir = resolve
+	t.Cleanup(func() { userHomeDir = saved })
+}
+
+func TestExpandHome(t *testing.T) {
+	withHomeDir(t, func() (string, error) { return "/home/alice", nil })
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"~", "/home/alice"},
+		{"~/", "/home/alice"},
+		{"~/stats.json", "/home/alice/stats.json"},
+		{"~/.config/ltc/stats.json", "/home/alice/.config/ltc/stats.json"},
+		// 先頭の ~/ と単独の ~ 以外はそのまま残す。
+		{"~bob/stats.json", "~bob/stats.json"},
+		{"/var/lib/~/stats.json", "/var/lib/~/stats.json"},
+		{"stats~.json", "stats~.json"},
+		{"./~/stats.json", "./~/stats.json"},
+		{"/var/lib/stats.json", "/var/lib/stats.json"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		got, err := expandHome(tt.path)
+		if err != nil {
+			t.Errorf("expandHome(%q): %v", tt.path, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("expandHome(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestConfigPaths(t *testing.T) {
+	// ~ を展開するパスの設定。パスの設定を追加したらここにも加える。
+	keys := []string{"stats_file", "reset_trigger_file", "report_output_file", "history_db", "container_socket", "ca_bundle"}
+
+	var config Config
+	paths := config.paths()
+	if len(paths) != len(keys) {
+		t.Errorf("paths() has %d fields, want %d", len(paths), len(keys))
+	}
+	value := reflect.ValueOf(&config).Elem()
+	for _, key := range keys {
+		var field *string
+		for i := range value.NumField() {
+			if strings.Split(value.Type().Field(i).Tag.Get("json"), ",")[0] == key {
+				field = value.Field(i).Addr().Interface().(*string)
+			}
+		}
+		if field == nil {
+			t.Errorf("Config has no %s", key)
+			continue
+		}
+		found := false
+		for _, path := range paths {
+			found = found || path == field
+		}
+		if !found {
+			t.Errorf("paths() does not include %s", key)
+		}
+	}
+}
+
+func TestReadConfigExpandsHome(t *testing.T) {
+	home := t.TempDir()
+	withHomeDir(t, func() (string, error) { return home, nil })
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	data := `{"discord_webhook_url": "https://discord.com/api/webhooks/1/a", "stats_file": "~/stats.json", "history_db": "~/history.db"}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := readConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(home, "stats.json"); config.StatsFile != want {
+		t.Errorf("stats_file = %q, want %q", config.StatsFile, want)
+	}
+	if want := filepath.Join(home, "history.db"); config.HistoryDB != want {
+		t.Errorf("history_db = %q, want %q", config.HistoryDB, want)
+	}
+}
+
+func TestExpandHomeWithoutHome(t *testing.T) {
+	errNoHome := errors.New("$HOME is not defined")
+	tests := []struct {
+		name    string
+		resolve func() (string, error)
+	}{
+		{"error", func() (string, error) { return "", errNoHome }},
+		{"empty", func() (string, error) { return "", nil }},
+	}
+	for _, tt := range tests {
+		withHomeDir(t, tt.resolve)
+		got, err := expandHome("~/stats.json")
+		if err == nil {
+			t.Errorf("%s: expandHome = %q, want an error", tt.name, got)
+			continue
+		}
+		if !strings.Contains(err.Error(), "~/stats.json") {
+			t.Errorf("%s: error %q does not name the path", tt.name, err)
+		}
+		if tt.name == "error" && !errors.Is(err, errNoHome) {
+			t.Errorf("%s: error %q does not wrap the resolver's error", tt.name, err)
+		}
+	}
+
+	// ~ を含まないパスはホームディレクトリを解決しない。
+	withHomeDir(t, func() (string, error) { return "", errNoHome })
+	if got, err := expandHome("/var/lib/stats.json"); err != nil || got != "/var/lib/stats.json" {
+		t.Errorf("expandHome(absolute) = %q, %v", got, err)
+	}
+}
+
+func TestReadConfigWithoutHome(t *testing.T) {
+	withHomeDir(t, func() (string, error) { return "", errors.New("$HOME is not defined") })
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	data := `{"discord_webhook_url": "https://discord.com/api/webhooks/1/a", "stats_file": "~/stats.json"}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readConfig(path); err == nil || !strings.Contains(err.Error(), "~/stats.json") {
+		t.Errorf("readConfig error = %v, want one naming ~/stats.json", err)
+	}
+}
+
+func TestRequireNotifiers(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr string
+	}{
+		{"Discord", Config{Notifiers: []string{"discord"}, WebhookURL: "https://discord.com/api/webhooks/1/a"}, ""},
+		{"Discord の URL がない", Config{Notifiers: []string{"discord"}}, "discord_webhook_url"},
+		// discord を使わなければ Discord の URL は要らない。
+		{"Slack だけ", Config{Notifiers: []string{"slack"}, SlackWebhookURL: "https://hooks.slack.com/services/a"}, ""},
+		{"Telegram だけ", Config{Notifiers: []string{"telegram"}, TelegramBotToken: "token", TelegramChatID: "1"}, ""},
+		{"メールだけ", Config{Notifiers: []string{"email"}, SMTPHost: "smtp.example.com", SMTPFrom: "a@example.com", SMTPTo: []string{"b@example.com"}}, ""},
+		{"1つ設定されていればよい", Config{Notifiers: []string{"slack", "webhook"}, GenericWebhookURL: "https://example.com/hook"}, ""},
+		{"どれも設定されていない", Config{Notifiers: []string{"slack", "telegram"}, TelegramChatID: "1"}, "telegram_bot_token"},
+	}
+	for _, tt := range tests {
+		err := requireNotifiers(&tt.config)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: err = %v, want one naming %s", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestListenAddrAlias(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		want    string
+		wantErr bool
+	}{
+		{"listen_addr だけ", `"listen_addr": ":8080"`, ":8080", false},
+		{"status_addr だけ", `"status_addr": ":8080"`, ":8080", false},
+		{"同じアドレス", `"listen_addr": ":8080", "status_addr": ":8080"`, ":8080", false},
+		{"異なるアドレス", `"listen_addr": ":8080", "status_addr": ":8081"`, "", true},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "config.json")
+		data := `{"discord_webhook_url": "https://discord.com/api/webhooks/1/a", "stats_file": "` + filepath.Join(t.TempDir(), "stats.json") + `", ` + tt.config + `}`
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		config, err := readConfig(path)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: no error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if config.StatusAddr != tt.want {
+			t.Errorf("%s: status_addr = %q, want %q", tt.name, config.StatusAddr, tt.want)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rakku1234/linux-traffic-checker/collector"
+	"github.com/rakku1234/linux-traffic-checker/notify"
+	"github.com/rakku1234/linux-traffic-checker/report"
+)
+
+// 既定のAPIのソケット。Podman は Docker 互換のAPIを提供するので、どちらのランタイムにも同じ方法で問い合わせる。
+var containerSockets = map[string]string{
+	"docker": "/var/run/docker.sock",
+	"podman": "/run/podman/podman.sock",
+}
+
+const containerAPITimeout = 10 * time.Second
+
+type containerSummary struct {
+	ID    string   `json:"Id"`
+	Names []string `json:"Names"`
+}
+
+type containerInspect struct {
+	State struct {
+		Pid int `json:"Pid"`
+	} `json:"State"`
+	HostConfig struct {
+		NetworkMode string `json:"NetworkMode"`
+	} `json:"HostConfig"`
+}
+
+func containerAPI(ctx context.Context, socket, path string, value any) error {
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socket)
+		},
+	}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://container"+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%s: %s - %s", path, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return json.NewDecoder(resp.Body).Decode(value)
+}
+
+// readContainerCounters は実行中のコンテナごとの通信量をコンテナ名をキーにして返す。カウンタはコンテナの
+// ネットワーク名前空間の中の /proc/<pid>/net/dev から読むので、RX はコンテナが受信した量になる。
+// ホストのネットワークや他のコンテナのネットワークを使うコンテナは、その通信量が別に数えられるので飛ばす。
+func readContainerCounters(config *Config) (map[string]*collector.InterfaceStats, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), containerAPITimeout)
+	defer cancel()
+
+	var containers []containerSummary
+	err := containerAPI(ctx, config.ContainerSocket, "/containers/json", &containers)
+	if err != nil {
+		return nil, fmt.Errorf("%s のコンテナ一覧を取得できません: %w", config.ContainerRuntime, err)
+	}
+
+	counters := map[string]*collector.InterfaceStats{}
+	for _, container := range containers {
+		name := container.ID[:min(12, len(container.ID))]
+		if len(container.Names) > 0 {
+			name = strings.TrimPrefix(container.Names[0], "/")
+		}
+		if report.Excluded(name, config.ExcludeContainers) {
+			continue
+		}
+
+		var inspect containerInspect
+		err := containerAPI(ctx, config.ContainerSocket, "/containers/"+container.ID+"/json", &inspect)
+		if err != nil {
+			return nil, fmt.Errorf("コンテナ %s の情報を取得できません: %w", name, err)
+		}
+		mode := inspect.HostConfig.NetworkMode
+		if mode == "host" || strings.HasPrefix(mode, "container:") || inspect.State.Pid == 0 {
+			continue
+		}
+
+		data, err := os.ReadFile(fmt.Sprintf("/proc/%d/net/dev", inspect.State.Pid))
+		if err != nil {
+			// 一覧を取得した後にコンテナが止まっているかもしれない。
+			continue
+		}
+		devices, err := collector.ParseNetDev(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("コンテナ %s: %w", name, err)
+		}
+		delete(devices, "lo")
+		counter := &collector.InterfaceStats{}
+		counter.RX, counter.TX = report.SumCounters(devices)
+		counters[name] = counter
+	}
+	return counters, nil
+}
+
+// topUsageField は usage のうち集計した通信量が多い順に n 件を並べる。
+func topUsageField(config *Config, name string, usage map[string]*collector.InterfaceStats, n int) notify.EmbedField {
+	names := report.SortedInterfaceNames(usage)
+	totals := make(map[string]*big.Int, len(names))
+	for _, key := range names {
+		totals[key] = countedTotal(config, &usage[key].RX, &usage[key].TX)
+	}
+	slices.SortStableFunc(names, func(a, b string) int {
+		return totals[b].Cmp(totals[a])
+	})
+
+	lines := make([]string, 0, n)
+	for i, key := range names[:min(n, len(names))] {
+		lines = append(lines, fmt.Sprintf("%d. %s: %s", i+1, key, formatBytes(totals[key])))
+	}
+	return notify.EmbedField{Name: fmt.Sprintf(tr("%s（上位%d）"), name, n), Value: strings.Join(lines, "\n"), Inline: false}
+}
@@ -0,0 +1,244 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+)
+
+// daemon は1つの設定で起動したスケジューラーとHTTPサーバー。再読み込みではそれらを止めて新しく起動し、
+// その間のベースラインは統計に残っている。
+type daemon struct {
+	config    *Config
+	scheduler gocron.Scheduler
+	servers   []*http.Server
+	// ctx は stop で取り消され、再試行を待っているジョブの待ちを終わらせる。
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func startDaemon(config *Config) (*daemon, error) {
+	options := []gocron.SchedulerOption{gocron.WithLocation(config.location)}
+	if testClock != 0 {
+		options = append(options, gocron.WithClock(clock))
+	}
+	s, err := gocron.NewScheduler(options...)
+	if err != nil {
+		return nil, fmt.Errorf("スケジューラの作成に失敗: %w", err)
+	}
+	d := &daemon{config: config, scheduler: s}
+	d.ctx, d.cancel = context.WithCancel(context.Background())
+	err = installNftablesRules(config)
+	if err == nil {
+		err = d.registerJobs()
+	}
+	if err != nil {
+		d.cancel()
+		_ = s.Shutdown()
+		return nil, err
+	}
+
+	if config.MetricsAddr != "" {
+		d.servers = append(d.servers, startMetricsServer(config))
+	}
+	if config.StatusAddr != "" {
+		d.servers = append(d.servers, startStatusServer(config))
+	}
+	if config.CollectorAddr != "" {
+		d.servers = append(d.servers, startCollectorServer(config))
+	}
+	s.Start()
+	setHealthScheduler(s)
+	return d, nil
+}
+
+func (d *daemon) registerJobs() error {
+	config := d.config
+	var err error
+	for _, report := range config.reports {
+		// 統計の読み込みに失敗した場合は初回として扱わない。ベースラインはスケジュールされたジョブが読み直す。
+		_, isFirstRun, loadErr := report.store.Load()
+		if loadErr != nil {
+			slog.Warn("起動時に統計を読み込めませんでした", "period", report.Period, "error", loadErr)
+		}
+		if loadErr == nil && isFirstRun {
+			// 起動を遅らせないようここでは再試行しない。失敗してもスケジュールされたジョブがベースラインを記録する。
+			if err := sendReport(report); err != nil {
+				slog.Error("初回のレポートに失敗しました", "error", err)
+			}
+		} else if report.CatchUpMissed && missedReport(report, config.location) {
+			// 再試行で起動を遅らせないよう、スケジューラーの中で実行する。
+			_, err = d.scheduler.NewJob(
+				gocron.OneTimeJob(gocron.OneTimeJobStartImmediately()),
+				gocron.NewTask(recoverTask("SendReport", retryReport(d.ctx), report)),
+			)
+			if err != nil {
+				return fmt.Errorf("%s の取りこぼしたレポートのジョブの登録に失敗: %w", report.Period, err)
+			}
+		}
+
+		job := retryReport(d.ctx)
+		if report.cycleStartOnly {
+			job = onCycleStart(job)
+		}
+		_, err = d.scheduler.NewJob(
+			gocron.CronJob(report.Schedule, false),
+			gocron.NewTask(recoverTask("SendReport", job, report)),
+		)
+		if err != nil {
+			return fmt.Errorf("%s のジョブの登録に失敗: %w", report.Period, err)
+		}
+	}
+
+	if config.DigestSchedule != "" {
+		_, err = d.scheduler.NewJob(
+			gocron.CronJob(config.DigestSchedule, false),
+			gocron.NewTask(recoverTask("SendDigest", retryJob(d.ctx, "SendDigest", sendDigest), config)),
+		)
+		if err != nil {
+			return fmt.Errorf("ダイジェストジョブの登録に失敗: %w", err)
+		}
+	}
+
+	if config.NotifyOnInterfaceDown {
+		interval, err := time.ParseDuration(config.LinkCheckInterval)
+		if err != nil {
+			return fmt.Errorf("link_check_interval が不正です（%q）: %w", config.LinkCheckInterval, err)
+		}
+		_, err = d.scheduler.NewJob(
+			gocron.DurationJob(interval),
+			gocron.NewTask(recoverTask("CheckInterfaceState", CheckInterfaceState, config)),
+		)
+		if err != nil {
+			return fmt.Errorf("リンク監視ジョブの登録に失敗: %w", err)
+		}
+	}
+
+	if config.RateAlertMbps > 0 {
+		interval, _ := time.ParseDuration(config.RateSampleInterval)
+		_, err = d.scheduler.NewJob(
+			gocron.DurationJob(interval),
+			gocron.NewTask(recoverTask("CheckRate", CheckRate, config)),
+		)
+		if err != nil {
+			return fmt.Errorf("通信速度監視ジョブの登録に失敗: %w", err)
+		}
+	}
+
+	if config.CollectorAddr != "" {
+		_, err = d.scheduler.NewJob(
+			gocron.CronJob(config.CollectorSchedule, false),
+			gocron.NewTask(recoverTask("SendFleetReport", SendFleetReport, config)),
+		)
+		if err != nil {
+			return fmt.Errorf("集約レポートのジョブの登録に失敗: %w", err)
+		}
+	}
+
+	if config.MQTTBrokerURL != "" {
+		interval, _ := time.ParseDuration(config.MQTTInterval)
+		_, err = d.scheduler.NewJob(
+			gocron.DurationJob(interval),
+			gocron.NewTask(recoverTask("PublishMQTT", PublishMQTT, config)),
+			gocron.WithStartAt(gocron.WithStartImmediately()),
+		)
+		if err != nil {
+			return fmt.Errorf("MQTT送信ジョブの登録に失敗: %w", err)
+		}
+	}
+
+	if config.ResetTriggerFile != "" {
+		interval, err := time.ParseDuration(config.ResetTriggerInterval)
+		if err != nil {
+			return fmt.Errorf("reset_trigger_interval が不正です（%q）: %w", config.ResetTriggerInterval, err)
+		}
+		_, err = d.scheduler.NewJob(
+			gocron.DurationJob(interval),
+			gocron.NewTask(recoverTask("CheckResetTrigger", CheckResetTrigger, config)),
+		)
+		if err != nil {
+			return fmt.Errorf("トリガーファイル監視ジョブの登録に失敗: %w", err)
+		}
+	}
+
+	if config.NextResetAt != nil {
+		if config.NextResetAt.After(clock.Now()) {
+			_, err = d.scheduler.NewJob(
+				gocron.OneTimeJob(gocron.OneTimeJobStartDateTime(*config.NextResetAt)),
+				gocron.NewTask(recoverTask("ApplyScheduledReset", ApplyScheduledReset, config)),
+			)
+			if err != nil {
+				return fmt.Errorf("リセットジョブの登録に失敗: %w", err)
+			}
+		} else {
+			ApplyScheduledReset(config)
+		}
+	}
+
+	if config.PollMode == "continuous" {
+		interval, err := time.ParseDuration(config.PollInterval)
+		if err != nil {
+			return fmt.Errorf("poll_interval が不正です（%q）: %w", config.PollInterval, err)
+		}
+		_, err = d.scheduler.NewJob(
+			gocron.DurationJob(interval),
+			gocron.NewTask(recoverTask("PollNetStats", PollNetStats, config)),
+		)
+		if err != nil {
+			return fmt.Errorf("ポーリングジョブの登録に失敗: %w", err)
+		}
+	}
+	return d.registerWatchdog()
+}
+
+// stop はHTTPサーバーとスケジューラーを止め、実行中のジョブが終わるのを待つ。
+func (d *daemon) stop() {
+	d.cancel()
+	setHealthScheduler(nil)
+	for _, server := range d.servers {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err := server.Shutdown(ctx)
+		cancel()
+		if err != nil {
+			slog.Error("HTTPサーバーの停止に失敗", "addr", server.Addr, "error", err)
+		}
+	}
+	err := d.scheduler.Shutdown()
+	if err != nil {
+		slog.Error("スケジューラの停止に失敗", "error", err)
+	}
+	if d.config.MQTTBrokerURL != "" {
+		disconnectMQTT(d.config)
+	}
+}
+
+// reload は設定ファイルを読み直し、その設定でスケジューラーとサーバーを起動し直す。
+// 新しい設定が不正か起動できない場合は、前の設定のまま動き続ける。
+func (d *daemon) reload(path string) *daemon {
+	config, err := readConfig(path)
+	if err != nil {
+		slog.Error("設定ファイルを再読み込みできないため、現在の設定で動作を続けます", "path", path, "error", err)
+		return d
+	}
+	d.stop()
+	// 前の設定のジョブが止まってから切り替える。
+	config.apply()
+	next, err := startDaemon(config)
+	if err != nil {
+		slog.Error("新しい設定で起動できないため、以前の設定に戻します", "path", path, "error", err)
+		d.config.apply()
+		next, err = startDaemon(d.config)
+		if err != nil {
+			slog.Error("以前の設定でも起動できません", "error", err)
+			os.Exit(1)
+		}
+		return next
+	}
+	slog.Info("設定ファイルを再読み込みしました", "path", path)
+	return next
+}
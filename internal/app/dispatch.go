@@ -0,0 +1,33 @@
+package app
+
+import (
+	"errors"
+	"sync"
+)
+
+func dispatch(concurrency int, sends []func() error) error {
+	return errors.Join(fanOut(concurrency, sends)...)
+}
+
+// fanOut は sends を同時に最大 concurrency 個ずつ実行し、それぞれのエラーを同じ順に返す。
+func fanOut(concurrency int, sends []func() error) []error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	errs := make([]error, len(sends))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, send := range sends {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			errs[i] = send()
+		}()
+	}
+	wg.Wait()
+
+	return errs
+}
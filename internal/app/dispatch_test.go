@@ -0,0 +1,76 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rakku1234/linux-traffic-checker/notify"
+)
+
+func fanOutConfig(urls ...string) *Config {
+	return &Config{WebhookURLs: urls, NotifyConcurrency: 2, BotName: "traffic"}
+}
+
+var fanOutEmbeds = []notify.Embed{{Title: "eth0 の通信量"}}
+
+func TestSendToDiscordPartialFailure(t *testing.T) {
+	ok, okCalls := statusServer(t, "", http.StatusNoContent)
+	failing, failingCalls := statusServer(t, "", http.StatusInternalServerError)
+
+	// 一方が成功すれば、失敗した側はログに残すだけで送信は成功とする。
+	if err := sendToDiscord(fanOutConfig(ok.URL, failing.URL), notify.KindReport, fanOutEmbeds); err != nil {
+		t.Errorf("sendToDiscord = %v, want success when one webhook succeeds", err)
+	}
+	if okCalls.Load() != 1 || failingCalls.Load() != 1 {
+		t.Errorf("sent %d and %d times, want 1 each", okCalls.Load(), failingCalls.Load())
+	}
+}
+
+func TestSendToDiscordAllFail(t *testing.T) {
+	first, _ := statusServer(t, "", http.StatusInternalServerError)
+	second, _ := statusServer(t, "", http.StatusBadGateway)
+
+	err := sendToDiscord(fanOutConfig(first.URL, second.URL), notify.KindReport, fanOutEmbeds)
+	if err == nil {
+		t.Fatal("sendToDiscord succeeded although every webhook failed")
+	}
+	for _, status := range []int{http.StatusInternalServerError, http.StatusBadGateway} {
+		found := false
+		for _, err := range err.(interface{ Unwrap() []error }).Unwrap() {
+			var statusErr *notify.HTTPStatusError
+			found = found || errors.As(err, &statusErr) && statusErr.StatusCode == status
+		}
+		if !found {
+			t.Errorf("error %v does not include the %d", err, status)
+		}
+	}
+}
+
+func TestSendToDiscordHungWebhook(t *testing.T) {
+	saved := httpClient
+	httpClient = &http.Client{Timeout: 100 * time.Millisecond}
+	t.Cleanup(func() { httpClient = saved })
+
+	release := make(chan struct{})
+	hung := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	t.Cleanup(hung.Close)
+	t.Cleanup(func() { close(release) })
+	ok, okCalls := statusServer(t, "", http.StatusNoContent)
+
+	// 応答しない Webhook は http_timeout で打ち切られ、他の送信を止めない。
+	start := time.Now()
+	if err := sendToDiscord(fanOutConfig(hung.URL, ok.URL), notify.KindReport, fanOutEmbeds); err != nil {
+		t.Errorf("sendToDiscord = %v", err)
+	}
+	if okCalls.Load() != 1 {
+		t.Errorf("the other webhook was sent %d times, want 1", okCalls.Load())
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("took %s", elapsed)
+	}
+}
@@ -0,0 +1,140 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// runDoctor は -validate の確認に加えて統計と常駐中のプロセスを確認し、診断を表示する。
+// 何も送信せず、統計も変えない。
+func runDoctor(config *Config) int {
+	checks := validationChecks(config)
+	if check, ok := interfaceResolution(config); ok {
+		checks = append(checks, check)
+	}
+	checks = append(checks, statsChecks(config)...)
+	for _, addr := range []string{config.StatusAddr, config.MetricsAddr} {
+		if addr != "" {
+			checks = append(checks, daemonCheck(addr))
+			break
+		}
+	}
+
+	printChecks(os.Stdout, checks)
+	var failed []string
+	for _, check := range checks {
+		if check.err != nil {
+			failed = append(failed, check.name)
+		}
+	}
+	fmt.Println()
+	if len(failed) == 0 {
+		fmt.Println("診断: 問題は見つかりませんでした")
+		return 0
+	}
+	fmt.Printf("診断: %d 件の問題が見つかりました（%s）\n", len(failed), strings.Join(failed, "、"))
+	return 1
+}
+
+// interfaceResolution は "auto" やパターンが今どのインターフェースを指すかを示す。
+// カウンタをコマンドや SNMP で読む場合は省く。
+func interfaceResolution(config *Config) (validationCheck, bool) {
+	if config.CounterCommand != "" || config.SNMPHost != "" {
+		return validationCheck{}, false
+	}
+	check := validationCheck{name: "インターフェースの解決", hint: "ip route でデフォルトルートを、ip link でインターフェース名を確認してください"}
+	device, err := resolveInterface(config)
+	if err != nil {
+		check.err = err
+		return check, true
+	}
+
+	names := []string{device.Interface}
+	if config.multiInterface() {
+		names = monitoredInterfaces(config)
+	} else if _, _, counters, err := readCountersOnce(device); err == nil && len(counters) > 0 {
+		names = slices.Sorted(maps.Keys(counters))
+	}
+	if len(names) == 0 {
+		check.err = fmt.Errorf("該当するインターフェースがありません")
+		return check, true
+	}
+	check.note = interfaceDisplayName(config) + " → " + strings.Join(names, ", ")
+	return check, true
+}
+
+// statsChecks は統計を保存できるかを確かめ、記録された最後の読み取りと通知を示す。
+func statsChecks(config *Config) []validationCheck {
+	writable := validationCheck{name: "統計ファイルへの書き込み", note: "書き込めます", hint: "stats_file のディレクトリの権限と空き容量を確認してください"}
+	if config.StorageBackend == "redis" {
+		writable.name = "Redisへの書き込み"
+		writable.hint = "redis_url とRedisサーバーの状態を確認してください"
+	}
+	writable.err = config.store.Writable()
+
+	stored := validationCheck{name: "統計", hint: "統計ファイルが壊れている場合は、バックアップ（.bak）から戻すか reset で記録し直してください"}
+	stats, isFirstRun, err := config.store.Load()
+	switch {
+	case err != nil:
+		stored.err = err
+	case isFirstRun:
+		stored.note = "まだベースラインが記録されていません"
+	case len(stats.UnsentReports)+len(stats.UnsentStarts) > 0:
+		stored.err = fmt.Errorf("送信できなかったレポートが %d 件あります", len(stats.UnsentReports)+len(stats.UnsentStarts))
+		stored.hint = "通知先への接続と、ログの送信エラーを確認してください"
+	default:
+		stored.note = fmt.Sprintf("最終読み取り %s / 最終通知 %s", doctorTime(config, latestReadAt(config, stats)), doctorTime(config, stats.LastNotified))
+	}
+	return []validationCheck{writable, stored}
+}
+
+func doctorTime(config *Config, t time.Time) string {
+	if t.IsZero() {
+		return "なし"
+	}
+	return t.In(config.location).Format("2006-01-02 15:04:05")
+}
+
+// daemonCheck は addr で待ち受ける常駐中のプロセスに /healthz を問い合わせる。
+func daemonCheck(addr string) validationCheck {
+	check := validationCheck{name: "常駐中のプロセス", hint: "サービスが起動しているか systemctl status やログで確認してください"}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		check.err = err
+		return check
+	}
+	if host == "" || net.ParseIP(host) != nil && net.ParseIP(host).IsUnspecified() {
+		host = "localhost"
+	}
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get("http://" + net.JoinHostPort(host, port) + "/healthz")
+	if err != nil {
+		check.err = err
+		return check
+	}
+	defer resp.Body.Close()
+	var health Health
+	if err := json.NewDecoder(resp.Body).Decode(&health); err != nil {
+		check.err = fmt.Errorf("/healthz の応答を解析できません: %w", err)
+		return check
+	}
+	if health.Status == "unhealthy" {
+		check.err = errors.New(strings.Join(health.Problems, "、"))
+		check.hint = "ログを確認してください"
+		return check
+	}
+	check.note = health.Status
+	if len(health.Problems) > 0 {
+		check.note += "（" + strings.Join(health.Problems, "、") + "）"
+	}
+	return check
+}
@@ -0,0 +1,117 @@
+package app
+
+import (
+	"fmt"
+	"log/slog"
+	"net/mail"
+	"strings"
+
+	"github.com/rakku1234/linux-traffic-checker/notify"
+)
+
+// EmailNotifier は通知先 "email" で、チャットサービスが使えないネットワーク向けに、
+// レポートとアラートをHTMLの表にして smtp_host から送る。
+type EmailNotifier struct {
+	config *Config
+}
+
+// reportTable はレポートをインターフェースごとに1行、最後に合計の行を並べた表にする。
+// 監視するインターフェースが1つなら1行だけ。
+func reportTable(config *Config, report *Report) [][]string {
+	rows := [][]string{{tr("インターフェース"), fieldLabel(config, "rx"), fieldLabel(config, "tx"), fieldLabel(config, "total")}}
+	for _, item := range report.Breakdown {
+		rows = append(rows, []string{item.Interface, item.RXText, item.TXText, item.TotalText})
+	}
+	name := report.Interface
+	if len(report.Breakdown) > 0 {
+		name = tr("合計")
+	}
+	return append(rows, []string{name, report.RXText, report.TXText, report.TotalText})
+}
+
+// emailColor は message_styles の色を見出しの色にする。指定がなければ空。
+func emailColor(style MessageStyle) string {
+	if style.Color == nil {
+		return ""
+	}
+	return fmt.Sprintf("#%06x", int(*style.Color))
+}
+
+func (n *EmailNotifier) Send(report Report) error {
+	style := messageStyle(n.config, "email", notify.KindReport)
+	title := style.title(reportTitle(n.config, &report))
+	table := notify.EmailTable{Title: title, Color: emailColor(style), Rows: reportTable(n.config, &report)}
+	return n.send(n.subject(title, report.Interface, report.PeriodLabel), []notify.EmailTable{table})
+}
+
+// SendEmbeds はすべての埋め込みを、それぞれのフィールドの表にして、最初の埋め込みの題名で1通のメールにする。
+func (n *EmailNotifier) SendEmbeds(kind notify.Kind, embeds []notify.Embed) error {
+	if len(embeds) == 0 {
+		return nil
+	}
+	style := messageStyle(n.config, "email", kind)
+	var tables []notify.EmailTable
+	for _, embed := range embeds {
+		table := notify.EmailTable{Title: style.title(embedTitle(embed)), Color: emailColor(style), Rows: [][]string{{tr("項目"), tr("値")}}}
+		for _, field := range embed.Fields {
+			table.Rows = append(table.Rows, []string{field.Name, field.Value})
+		}
+		tables = append(tables, table)
+	}
+	return n.send(n.subject(tables[0].Title, embeds[0].Interface, ""), tables)
+}
+
+// subject は smtp_subject の {title} をメッセージの題名に置き換える。
+func (n *EmailNotifier) subject(title, interfaceName, period string) string {
+	subject := expandMessage("smtp_subject", n.config.SMTPSubject, messageData{Interface: interfaceName, Period: period})
+	return strings.ReplaceAll(subject, "{title}", title)
+}
+
+func (n *EmailNotifier) send(subject string, tables []notify.EmailTable) error {
+	if n.config.SMTPHost == "" || n.config.SMTPFrom == "" || len(n.config.SMTPTo) == 0 {
+		slog.Warn("smtp_host・smtp_from・smtp_to のいずれかが未設定のためメール通知をスキップします")
+		return nil
+	}
+	if dryRun {
+		slog.Info("[dry-run] メールの送信をスキップします", "subject", subject, "to", n.config.SMTPTo)
+		return nil
+	}
+	email := notify.Email{
+		Host:     n.config.SMTPHost,
+		Port:     n.config.SMTPPort,
+		TLS:      n.config.SMTPTLS,
+		Username: n.config.SMTPUsername,
+		Password: n.config.SMTPPassword,
+		From:     n.config.SMTPFrom,
+		To:       n.config.SMTPTo,
+	}
+	return email.Send(subject, clock.Now(), tables)
+}
+
+func validateEmail(config *Config) error {
+	if config.usesNotifier("email") && (config.SMTPHost == "" || config.SMTPFrom == "" || len(config.SMTPTo) == 0) {
+		return fmt.Errorf("notifier email を使うには smtp_host・smtp_from・smtp_to を指定してください")
+	}
+	switch config.SMTPTLS {
+	case "starttls", "tls", "none":
+	default:
+		return fmt.Errorf("smtp_tls は starttls, tls, none のいずれかを指定してください: %q", config.SMTPTLS)
+	}
+	if config.SMTPPort < 1 || config.SMTPPort > 65535 {
+		return fmt.Errorf("smtp_port は1から65535の間で指定してください: %d", config.SMTPPort)
+	}
+	if config.SMTPFrom != "" {
+		if _, err := mail.ParseAddress(config.SMTPFrom); err != nil {
+			return fmt.Errorf("smtp_from のアドレスが不正です（%q）: %w", config.SMTPFrom, err)
+		}
+	}
+	for _, to := range config.SMTPTo {
+		if _, err := mail.ParseAddress(to); err != nil {
+			return fmt.Errorf("smtp_to のアドレスが不正です（%q）: %w", to, err)
+		}
+	}
+	if _, err := parseMessageTemplate("smtp_subject", config.SMTPSubject); err != nil {
+		return fmt.Errorf("smtp_subject のテンプレートが不正です: %w", err)
+	}
+	return nil
+}
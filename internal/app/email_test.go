@@ -0,0 +1,26 @@
+package app
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rakku1234/linux-traffic-checker/notify"
+)
+
+func TestEmailStyle(t *testing.T) {
+	red := EmbedColor(0xff0000)
+	config := &Config{MessageStyles: map[string]map[string]MessageStyle{
+		"email": {"report": {Title: "月次レポート: {title}", Color: &red}},
+	}}
+	report := Report{Interface: "eth0", PeriodLabel: "2026年9月", RXText: "1.00 GiB", TXText: "2.00 GiB", TotalText: "3.00 GiB"}
+
+	style := messageStyle(config, "email", notify.KindReport)
+	title := style.title(reportTitle(config, &report))
+	if !strings.HasPrefix(title, "月次レポート: ") {
+		t.Errorf("title %q does not use message_styles", title)
+	}
+
+	if color := emailColor(style); color != "#ff0000" {
+		t.Errorf("heading color = %q, want #ff0000", color)
+	}
+}
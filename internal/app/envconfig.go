@@ -0,0 +1,128 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+const envPrefix = "LTC_"
+
+// envAliases はよく使う設定の短い環境変数名。すべての設定に使える LTC_<大文字のJSONのキー> の形と併せて使える。
+var envAliases = map[string]string{
+	"LTC_WEBHOOK_URL": "discord_webhook_url",
+}
+
+// overrideFlag は -set key=value のフラグを集める。
+type overrideFlag []string
+
+func (f *overrideFlag) String() string { return strings.Join(*f, ",") }
+
+func (f *overrideFlag) Set(value string) error {
+	if !strings.Contains(value, "=") {
+		return fmt.Errorf("key=value の形式で指定してください: %q", value)
+	}
+	*f = append(*f, value)
+	return nil
+}
+
+// configOverrides は環境変数より優先して適用する -set のフラグ。
+var configOverrides overrideFlag
+
+// configFields は Config のJSONのキーからフィールドの型を引く。
+func configFields() map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	t := reflect.TypeFor[Config]()
+	for i := range t.NumField() {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if !field.IsExported() || name == "" || name == "-" {
+			continue
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+// overrideValue は上書きする値の文字列をフィールドの型に合ったJSONにする。文字列はそのまま、
+// リストはカンマ区切りでも書け、それ以外はJSON（数値・true/false・オブジェクト）として扱う。
+// JSONとして読めない時刻や期間などの値はJSONの文字列にする。
+func overrideValue(key string, t reflect.Type, value string) json.RawMessage {
+	switch {
+	case t.Kind() == reflect.String && !(key == "interface" && strings.HasPrefix(value, "[")):
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.String && !strings.HasPrefix(value, "["):
+		items := strings.Split(value, ",")
+		for i := range items {
+			items[i] = strings.TrimSpace(items[i])
+		}
+		data, _ := json.Marshal(items)
+		return data
+	case t.Kind() == reflect.Slice && !strings.HasPrefix(value, "[") && json.Valid([]byte("["+value+"]")):
+		return json.RawMessage("[" + value + "]")
+	case json.Valid([]byte(value)):
+		return json.RawMessage(value)
+	}
+	data, _ := json.Marshal(value)
+	return data
+}
+
+// hasOverrides は設定ファイルの外で指定された設定があるかどうかを返す。
+func hasOverrides() bool {
+	if len(configOverrides) > 0 {
+		return true
+	}
+	for _, env := range os.Environ() {
+		name, _, _ := strings.Cut(env, "=")
+		if _, ok := envAliases[name]; ok {
+			return true
+		}
+		if key, ok := strings.CutPrefix(name, envPrefix); ok && key != "CONFIG" {
+			return true
+		}
+	}
+	return false
+}
+
+// applyOverrides は設定ファイルに LTC_* の環境変数、次に -set のフラグを重ねる。
+// フラグは環境変数より、環境変数はファイルより優先される。
+func applyOverrides(data []byte) ([]byte, error) {
+	values := map[string]json.RawMessage{}
+	if len(strings.TrimSpace(string(data))) > 0 {
+		if err := json.Unmarshal(data, &values); err != nil {
+			return nil, err
+		}
+	}
+	fields := configFields()
+
+	set := func(source, key, value string) error {
+		t, ok := fields[key]
+		if !ok {
+			return fmt.Errorf("%s: 不明な設定です: %q", source, key)
+		}
+		values[key] = overrideValue(key, t, value)
+		return nil
+	}
+	for _, env := range os.Environ() {
+		name, value, _ := strings.Cut(env, "=")
+		key, ok := envAliases[name]
+		if !ok {
+			suffix, found := strings.CutPrefix(name, envPrefix)
+			if !found || suffix == "CONFIG" {
+				continue
+			}
+			key = strings.ToLower(suffix)
+		}
+		if err := set("環境変数 "+name, key, value); err != nil {
+			return nil, err
+		}
+	}
+	for _, override := range configOverrides {
+		key, value, _ := strings.Cut(override, "=")
+		if err := set("-set", strings.TrimSpace(key), value); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(values)
+}
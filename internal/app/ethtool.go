@@ -0,0 +1,103 @@
+//go:build linux
+
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	siocEthtool     = 0x8946
+	ethtoolGDrvInfo = 0x03
+	ethtoolGStrings = 0x1b
+	ethtoolGStats   = 0x1d
+	ethSSStats      = 1
+	ethGStringLen   = 32
+	ifNameSize      = 16
+)
+
+type ifreq struct {
+	name [ifNameSize]byte
+	data uintptr
+	_    [16]byte
+}
+
+type ethtoolDrvInfo struct {
+	cmd         uint32
+	driver      [32]byte
+	version     [32]byte
+	fwVersion   [32]byte
+	busInfo     [32]byte
+	eromVersion [32]byte
+	reserved2   [12]byte
+	nPrivFlags  uint32
+	nStats      uint32
+	testInfoLen uint32
+	eedumpLen   uint32
+	regdumpLen  uint32
+}
+
+func ethtoolIoctl(fd int, interfaceName string, data unsafe.Pointer) error {
+	var req ifreq
+	copy(req.name[:ifNameSize-1], interfaceName)
+	req.data = uintptr(data)
+
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), siocEthtool, uintptr(unsafe.Pointer(&req)))
+	runtime.KeepAlive(data)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func readEthtoolStats(interfaceName string, names []string) (map[string]uint64, error) {
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer syscall.Close(fd)
+
+	drvInfo := ethtoolDrvInfo{cmd: ethtoolGDrvInfo}
+	if err := ethtoolIoctl(fd, interfaceName, unsafe.Pointer(&drvInfo)); err != nil {
+		return nil, fmt.Errorf("ethtool ドライバ情報の取得に失敗 (%s): %w", interfaceName, err)
+	}
+	n := int(drvInfo.nStats)
+	if n == 0 {
+		return nil, fmt.Errorf("インターフェース %s は ethtool 統計に対応していません", interfaceName)
+	}
+
+	stringsBuf := make([]byte, 12+n*ethGStringLen)
+	*(*uint32)(unsafe.Pointer(&stringsBuf[0])) = ethtoolGStrings
+	*(*uint32)(unsafe.Pointer(&stringsBuf[4])) = ethSSStats
+	*(*uint32)(unsafe.Pointer(&stringsBuf[8])) = uint32(n)
+	if err := ethtoolIoctl(fd, interfaceName, unsafe.Pointer(&stringsBuf[0])); err != nil {
+		return nil, fmt.Errorf("ethtool 統計名の取得に失敗 (%s): %w", interfaceName, err)
+	}
+
+	statsBuf := make([]uint64, 1+n)
+	*(*uint32)(unsafe.Pointer(&statsBuf[0])) = ethtoolGStats
+	*(*uint32)(unsafe.Pointer(uintptr(unsafe.Pointer(&statsBuf[0])) + 4)) = uint32(n)
+	if err := ethtoolIoctl(fd, interfaceName, unsafe.Pointer(&statsBuf[0])); err != nil {
+		return nil, fmt.Errorf("ethtool 統計の取得に失敗 (%s): %w", interfaceName, err)
+	}
+
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		wanted[name] = true
+	}
+
+	values := make(map[string]uint64)
+	for i := 0; i < n; i++ {
+		raw := stringsBuf[12+i*ethGStringLen : 12+(i+1)*ethGStringLen]
+		name := string(bytes.TrimRight(raw, "\x00"))
+		if wanted[name] {
+			values[name] = statsBuf[1+i]
+		}
+	}
+
+	return values, nil
+}
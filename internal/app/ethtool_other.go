@@ -0,0 +1,13 @@
+//go:build !linux
+
+package app
+
+import (
+	"errors"
+	"fmt"
+)
+
+// readEthtoolStats には Linux にしかない ethtool の ioctl が必要。
+func readEthtoolStats(interfaceName string, names []string) (map[string]uint64, error) {
+	return nil, fmt.Errorf("%s: ethtool_stats はLinuxでのみ使えます: %w", interfaceName, errors.ErrUnsupported)
+}
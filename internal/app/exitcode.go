@@ -0,0 +1,48 @@
+package app
+
+import (
+	"errors"
+	"log/slog"
+	"sync/atomic"
+)
+
+// 1回だけ実行する場合（-once・-dry-run・stats_file が "-"）の終了コード。
+// 設定を読めないなどのその他のエラーは 1 で終了する。
+const (
+	exitOK           = 0
+	exitJobFailed    = 1
+	exitNotifyFailed = 2
+)
+
+// notifyFailed は再試行しても通知を届けられなかったことを記録する。常駐中はログに残すだけで、
+// 1回だけ実行する場合は exitNotifyFailed で終了する。
+var notifyFailed atomic.Bool
+
+// jobFailed は再試行してもレポートやダイジェストのジョブが失敗したことを記録する。
+// 1回だけ実行する場合は、通知の失敗がなければ exitJobFailed で終了する。
+var jobFailed atomic.Bool
+
+func reportNotifyFailure(err error) {
+	notifyFailed.Store(true)
+
+	var permanent *PermanentError
+	var exhausted *RetriesExhaustedError
+	switch {
+	case errors.As(err, &permanent):
+		slog.Error("通知の送信に失敗しました。設定を確認してください", "error", err)
+	case errors.As(err, &exhausted):
+		slog.Error("通知の送信を再試行しましたが失敗しました", "attempts", exhausted.Attempts, "error", err)
+	default:
+		slog.Error("通知の送信エラー", "error", err)
+	}
+}
+
+func oneShotExitCode() int {
+	if notifyFailed.Load() {
+		return exitNotifyFailed
+	}
+	if jobFailed.Load() {
+		return exitJobFailed
+	}
+	return exitOK
+}
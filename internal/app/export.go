@@ -0,0 +1,192 @@
+package app
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+)
+
+// export サブコマンドの -from・-to・-granularity・-output のフラグ。
+var (
+	exportFrom        string
+	exportTo          string
+	exportGranularity string
+	exportOutput      string
+)
+
+type exportRow struct {
+	Interface string   `json:"interface,omitempty"`
+	Month     string   `json:"month,omitempty"`
+	Date      string   `json:"date,omitempty"`
+	RX        *big.Int `json:"rx_bytes"`
+	TX        *big.Int `json:"tx_bytes"`
+	Total     *big.Int `json:"total_bytes"`
+}
+
+// validateExportRange は月（2024-01）か日（2024-01-15）で指定する -from と -to を確かめる。
+func validateExportRange() error {
+	for _, value := range []struct{ flag, text string }{{"-from", exportFrom}, {"-to", exportTo}} {
+		if value.text == "" {
+			continue
+		}
+		_, monthErr := time.Parse("2006-01", value.text)
+		_, dayErr := time.Parse("2006-01-02", value.text)
+		if monthErr != nil && dayErr != nil {
+			return fmt.Errorf("%s は 2024-01 か 2024-01-15 の形式で指定してください: %q", value.flag, value.text)
+		}
+	}
+	if exportFrom != "" && exportTo != "" && exportFrom > exportTo {
+		return fmt.Errorf("-from（%s）が -to（%s）より後になっています", exportFrom, exportTo)
+	}
+	return nil
+}
+
+// inExportRange は期間のキーが -from から -to の間にあるかどうかを返す。キーと範囲は短い方の長さで比べるので、
+// 月の 2024-01 はその月のすべての日を含み、日の 2024-01-15 はその月に含まれる。
+func inExportRange(key string) bool {
+	if exportFrom != "" {
+		n := min(len(key), len(exportFrom))
+		if key[:n] < exportFrom[:n] {
+			return false
+		}
+	}
+	if exportTo != "" {
+		n := min(len(key), len(exportTo))
+		if key[:n] > exportTo[:n] {
+			return false
+		}
+	}
+	return true
+}
+
+func recordRows(records []PeriodRecord, daily bool) []exportRow {
+	rows := make([]exportRow, 0, len(records))
+	for i := range records {
+		record := &records[i]
+		row := exportRow{
+			Interface: record.Interface,
+			RX:        &record.RX,
+			TX:        &record.TX,
+			Total:     new(big.Int).Add(&record.RX, &record.TX),
+		}
+		if daily {
+			row.Date = record.Month
+		} else {
+			row.Month = record.Month
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// usageRows は監視するすべてのインターフェースの日または月の通信量を history_db から読む。
+// history_db には集計中の日と月も入っている。
+func usageRows(config *Config, daily bool) ([]exportRow, error) {
+	bucket := monthlyBucket
+	if daily {
+		bucket = dailyBucket
+	}
+	var rows []exportRow
+	for _, name := range monitoredNames(config) {
+		entries, err := loadUsage(config.HistoryDB, name, bucket, "9999", math.MaxInt)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			row := exportRow{
+				RX:    new(big.Int).Set(&entry.RX),
+				TX:    new(big.Int).Set(&entry.TX),
+				Total: new(big.Int).Add(&entry.RX, &entry.TX),
+			}
+			if config.multiInterface() {
+				row.Interface = name
+			}
+			if daily {
+				row.Date = entry.Key
+			} else {
+				row.Month = entry.Key
+			}
+			rows = append(rows, row)
+		}
+	}
+	slices.SortStableFunc(rows, func(a, b exportRow) int {
+		return strings.Compare(a.Month+a.Date, b.Month+b.Date)
+	})
+	return rows, nil
+}
+
+// historyRows は統計ファイルの締めた期間。
+func historyRows(config *Config) ([]exportRow, error) {
+	records, err := loadHistory(config)
+	if err != nil {
+		return nil, err
+	}
+	return recordRows(records, exportGranularity == "daily"), nil
+}
+
+// exportRows は -from から -to までの -granularity ごとの行を集める。-granularity がなければ統計ファイルの
+// 締めた期間で、"daily" と "monthly" は history_db から読む。統計ファイルの期間と同じ単位ならそちらを使う。
+func exportRows(config *Config) ([]exportRow, error) {
+	var rows []exportRow
+	var err error
+	switch exportGranularity {
+	case "":
+		rows, err = historyRows(config)
+	case "daily", "monthly":
+		switch {
+		case config.HistoryDB != "":
+			rows, err = usageRows(config, exportGranularity == "daily")
+		case config.Period == exportGranularity:
+			rows, err = historyRows(config)
+		default:
+			return nil, fmt.Errorf("-granularity %s の出力には history_db が必要です（period が %s の場合は統計ファイルから出力できます）", exportGranularity, exportGranularity)
+		}
+	default:
+		return nil, fmt.Errorf("-granularity には daily, monthly のいずれかを指定してください: %q", exportGranularity)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(rows, func(row exportRow) bool { return !inExportRange(row.Month + row.Date) }), nil
+}
+
+func exportRecords(w io.Writer, format string, rows []exportRow) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(rows)
+	case "csv":
+		withInterface := slices.ContainsFunc(rows, func(row exportRow) bool { return row.Interface != "" })
+		header := []string{"month", "rx_bytes", "tx_bytes", "total_bytes"}
+		if exportGranularity == "daily" {
+			header[0] = "date"
+		}
+		if withInterface {
+			header = append([]string{"interface"}, header...)
+		}
+		writer := csv.NewWriter(w)
+		if err := writer.Write(header); err != nil {
+			return err
+		}
+		for _, row := range rows {
+			line := []string{row.Month + row.Date, row.RX.String(), row.TX.String(), row.Total.String()}
+			if withInterface {
+				line = append([]string{row.Interface}, line...)
+			}
+			if err := writer.Write(line); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	default:
+		return fmt.Errorf("不明なエクスポート形式です: %s", format)
+	}
+}
@@ -0,0 +1,102 @@
+package app
+
+import (
+	"fmt"
+	"log/slog"
+	"math/big"
+	"time"
+
+	"github.com/rakku1234/linux-traffic-checker/notify"
+)
+
+// forecastDays は予測で平均する history_db の直近の終わった日の数。
+const forecastDays = 7
+
+func forecastLabel(key string) string {
+	switch keyPeriod(key) {
+	case "weekly":
+		return tr("今週末の予測")
+	case "daily":
+		return tr("今日の終わりの予測")
+	default:
+		return tr("今月末の予測")
+	}
+}
+
+// recentDailyRate は history_db から、今期の直近の終わった日の1日あたりの平均の通信量を返す。
+// データが足りなければ nil を返す。
+func recentDailyRate(config *Config, start, now time.Time) *big.Float {
+	today, _, _ := periodBounds(periodKey("daily", now), now.Location())
+	if !today.After(start) {
+		return nil
+	}
+	yesterday := periodKey("daily", today.Add(-time.Nanosecond))
+	entries, err := loadUsage(config.HistoryDB, interfaceDisplayName(config), dailyBucket, yesterday, forecastDays)
+	if err != nil {
+		slog.Warn("history_db の読み込みエラー", "path", config.HistoryDB, "error", err)
+		return nil
+	}
+
+	sum := new(big.Int)
+	days := 0
+	for _, entry := range entries {
+		day, _, err := periodBounds(entry.Key, now.Location())
+		if err != nil || day.Before(start) {
+			continue
+		}
+		sum.Add(sum, countedTotal(config, &entry.RX, &entry.TX))
+		days++
+	}
+	if days == 0 {
+		return nil
+	}
+	return new(big.Float).Quo(new(big.Float).SetInt(sum), big.NewFloat(float64(days)))
+}
+
+// forecastUsage は集計中の期間の終わりまでの通信量を予測する。history_db があれば残りの期間も直近の日の平均で、
+// なければこれまでの平均で続くものとする。
+func forecastUsage(config *Config, record *PeriodRecord, now time.Time) (*big.Int, bool) {
+	start, end, err := periodBounds(record.Month, now.Location())
+	if err != nil || !now.Before(end) {
+		return nil, false
+	}
+	elapsed := now.Sub(start)
+	if elapsed < time.Hour {
+		return nil, false
+	}
+	total := countedTotal(config, &record.RX, &record.TX)
+	remaining := end.Sub(now)
+
+	var rate *big.Float // バイト毎秒
+	if config.HistoryDB != "" {
+		if daily := recentDailyRate(config, start, now); daily != nil {
+			rate = daily.Quo(daily, big.NewFloat((24 * time.Hour).Seconds()))
+		}
+	}
+	if rate == nil {
+		rate = new(big.Float).Quo(new(big.Float).SetInt(total), big.NewFloat(elapsed.Seconds()))
+	}
+	projectedFloat := new(big.Float).Mul(rate, big.NewFloat(remaining.Seconds()))
+	projectedFloat.Add(projectedFloat, new(big.Float).SetInt(total))
+	projected, _ := projectedFloat.Int(nil)
+	return projected, true
+}
+
+// forecastField は予測した通信量と、quota_bytes（なければ cap_bytes）に対する割合を表示する。
+func forecastField(config *Config, record *PeriodRecord, now time.Time) (notify.EmbedField, bool) {
+	projected, ok := forecastUsage(config, record, now)
+	if !ok {
+		return notify.EmbedField{}, false
+	}
+
+	value := formatBytes(projected)
+	limit := config.QuotaBytes
+	if limit <= 0 {
+		limit = config.CapBytes
+	}
+	if limit > 0 {
+		percent, _ := new(big.Float).Quo(new(big.Float).SetInt(projected), big.NewFloat(float64(limit))).Float64()
+		value += fmt.Sprintf(tr("（上限の%.0f%%）"), percent*100)
+	}
+	return notify.EmbedField{Name: forecastLabel(record.Month), Value: value, Inline: false}, true
+}
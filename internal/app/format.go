@@ -0,0 +1,47 @@
+package app
+
+import (
+	"math/big"
+	"strings"
+
+	"github.com/rakku1234/linux-traffic-checker/report"
+)
+
+// byteFormat はすべてのメッセージのバイト数の表示方法で、Config.apply が設定から切り替える。
+var byteFormat = report.DefaultByteFormat
+
+func formatBytes(Bytes *big.Int) string {
+	return byteFormat.Bytes(Bytes)
+}
+
+func formatRate(bytesPerSecond *big.Float) string {
+	return byteFormat.Rate(bytesPerSecond)
+}
+
+// fixedUnit は units ですべての値を表示する単位を返す。値が届く最大の単位で表示する場合は "" を返す。
+func fixedUnit(units string) string {
+	switch strings.ToLower(units) {
+	case "", "iec", "si":
+		return ""
+	}
+	unit, _, _ := report.ParseUnit(units)
+	return unit
+}
+
+// unitsMode は units から決まる unit_mode を返す。決まらなければ "" を返す。
+func unitsMode(units string) string {
+	switch strings.ToLower(units) {
+	case "iec":
+		return "binary"
+	case "si":
+		return "decimal"
+	}
+	unit, iec, err := report.ParseUnit(units)
+	if err != nil || unit == "B" {
+		return ""
+	}
+	if iec {
+		return "binary"
+	}
+	return "decimal"
+}
@@ -0,0 +1,159 @@
+package app
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+)
+
+// healthState は /healthz のために常駐中のプロセスが自分について持っている情報。
+var healthState struct {
+	mu        sync.Mutex
+	scheduler gocron.Scheduler
+	startedAt time.Time
+	// lastSample は最後にカウンタを読み取れた時刻。
+	lastSample       time.Time
+	lastNotification *NotificationHealth
+}
+
+type SchedulerHealth struct {
+	Running bool       `json:"running"`
+	Jobs    int        `json:"jobs"`
+	NextRun *time.Time `json:"next_run,omitempty"`
+}
+
+type NotificationHealth struct {
+	Notifier string    `json:"notifier"`
+	At       time.Time `json:"at"`
+	OK       bool      `json:"ok"`
+	Error    string    `json:"error,omitempty"`
+}
+
+type StatsHealth struct {
+	Writable bool   `json:"writable"`
+	Error    string `json:"error,omitempty"`
+}
+
+type Health struct {
+	// Status は "ok"、最後の通知だけが失敗した場合の "degraded"、または 503 で返す "unhealthy"。
+	Status           string              `json:"status"`
+	Problems         []string            `json:"problems,omitempty"`
+	Scheduler        SchedulerHealth     `json:"scheduler"`
+	LastSampleAt     *time.Time          `json:"last_sample_at"`
+	LastNotification *NotificationHealth `json:"last_notification"`
+	StatsFile        StatsHealth         `json:"stats_file"`
+}
+
+// setHealthScheduler は常駐中のスケジューラーを記録する。止めた後は nil にする。
+func setHealthScheduler(s gocron.Scheduler) {
+	healthState.mu.Lock()
+	defer healthState.mu.Unlock()
+	healthState.scheduler = s
+	if s != nil {
+		healthState.startedAt = clock.Now()
+	}
+}
+
+func recordSample() {
+	healthState.mu.Lock()
+	defer healthState.mu.Unlock()
+	healthState.lastSample = clock.Now()
+}
+
+func recordNotification(notifier string, err error) {
+	result := &NotificationHealth{Notifier: notifier, At: clock.Now().UTC(), OK: err == nil}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	healthState.mu.Lock()
+	defer healthState.mu.Unlock()
+	healthState.lastNotification = result
+}
+
+// sampleStaleAfter はカウンタを読み取れない状態を /healthz が許す時間。
+// レポートのスケジュールでしかカウンタを読まない場合は 0。
+func sampleStaleAfter(config *Config) time.Duration {
+	if config.PollMode != "continuous" {
+		return 0
+	}
+	interval, err := time.ParseDuration(config.PollInterval)
+	if err != nil {
+		return 0
+	}
+	return 3 * interval
+}
+
+func buildHealth(config *Config) *Health {
+	healthState.mu.Lock()
+	s := healthState.scheduler
+	startedAt := healthState.startedAt
+	lastSample := healthState.lastSample
+	lastNotification := healthState.lastNotification
+	healthState.mu.Unlock()
+
+	health := &Health{
+		Status:           "ok",
+		LastSampleAt:     optionalTime(lastSample.UTC()),
+		LastNotification: lastNotification,
+	}
+	unhealthy := func(problem string) {
+		health.Status = "unhealthy"
+		health.Problems = append(health.Problems, problem)
+	}
+
+	if s == nil {
+		unhealthy("スケジューラが動作していません")
+	} else {
+		health.Scheduler.Running = true
+		for _, job := range s.Jobs() {
+			health.Scheduler.Jobs++
+			next, err := job.NextRun()
+			if err == nil && !next.IsZero() && (health.Scheduler.NextRun == nil || next.Before(*health.Scheduler.NextRun)) {
+				next = next.UTC()
+				health.Scheduler.NextRun = &next
+			}
+		}
+	}
+
+	if staleAfter := sampleStaleAfter(config); staleAfter > 0 && s != nil {
+		since := lastSample
+		if since.IsZero() {
+			since = startedAt
+		}
+		if clock.Since(since) > staleAfter {
+			unhealthy("カウンタを " + staleAfter.String() + " 以上読み取れていません")
+		}
+	}
+
+	if err := config.store.Writable(); err != nil {
+		health.StatsFile.Error = err.Error()
+		unhealthy("統計ファイルに書き込めません")
+	} else {
+		health.StatsFile.Writable = true
+	}
+
+	if lastNotification != nil && !lastNotification.OK && health.Status == "ok" {
+		health.Status = "degraded"
+		health.Problems = append(health.Problems, "最後の通知に失敗しました")
+	}
+	return health
+}
+
+// healthHandler は /healthz で、正常に動いていれば 200 を、スケジューラーが止まっている、しばらくカウンタを
+// 読み取れていない、統計を保存できない場合は 503 を返す。不安定な Webhook でプロセスが再起動されないよう、
+// 通知の失敗だけなら "degraded" にとどめる。
+func healthHandler(config *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allowGet(w, r) {
+			return
+		}
+		health := buildHealth(config)
+		if health.Status == "unhealthy" {
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		writeJSON(w, health)
+	}
+}
@@ -0,0 +1,136 @@
+package app
+
+import (
+	"fmt"
+	"log/slog"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rakku1234/linux-traffic-checker/notify"
+)
+
+// keyPeriod は保存されたキーがどの期間の単位で書かれたかを返す。
+func keyPeriod(key string) string {
+	switch {
+	case strings.Contains(key, "-W"):
+		return "weekly"
+	case len(key) == len("2006-01-02"):
+		return "daily"
+	default:
+		return "monthly"
+	}
+}
+
+// previousPeriodKey は key の直前の期間のキーを返す。
+func previousPeriodKey(key string) string {
+	start, _, err := periodBounds(key, time.UTC)
+	if err != nil {
+		return ""
+	}
+	return periodKey(keyPeriod(key), start.Add(-time.Nanosecond))
+}
+
+// previousRecord は key の直前の期間の履歴を返す。その期間が記録されていなければ nil を返す。
+func previousRecord(history []PeriodRecord, key string) *PeriodRecord {
+	previous := previousPeriodKey(key)
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Month == previous {
+			return &history[i]
+		}
+	}
+	return nil
+}
+
+// historyFor はインターフェースの履歴を返す。"" はインターフェースが1つの場合に使う最上位の履歴。
+func historyFor(stats *Stats, interfaceName string) []PeriodRecord {
+	if interfaceName == "" {
+		return stats.History
+	}
+	if sub, ok := stats.PerInterface[interfaceName]; ok {
+		return sub.History
+	}
+	return nil
+}
+
+// loadHistory は統計に記録された締めた期間を古い順に返す。複数のインターフェースなら、
+// それぞれの記録にインターフェース名が入る。
+func loadHistory(config *Config) ([]PeriodRecord, error) {
+	stats, _, err := config.store.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	var records []PeriodRecord
+	for _, scope := range interfaceScopes(config, stats) {
+		for _, record := range scope.stats.History {
+			if config.multiInterface() {
+				record.Interface = scope.config.Interface
+			}
+			records = append(records, record)
+		}
+	}
+	slices.SortStableFunc(records, func(a, b PeriodRecord) int {
+		return strings.Compare(a.Month, b.Month)
+	})
+	return records, nil
+}
+
+func appendHistory(config *Config, history []PeriodRecord, record PeriodRecord) []PeriodRecord {
+	history = append(history, record)
+	if len(history) > config.HistoryLimit {
+		history = history[len(history)-config.HistoryLimit:]
+	}
+	return history
+}
+
+func comparisonLabel(key string) string {
+	switch keyPeriod(key) {
+	case "weekly":
+		return tr("前週比")
+	case "daily":
+		return tr("前日比")
+	default:
+		return tr("前月比")
+	}
+}
+
+// comparisonField は record の集計した合計を前の期間と比べる。
+func comparisonField(config *Config, record, previous *PeriodRecord) notify.EmbedField {
+	total := countedTotal(config, &record.RX, &record.TX)
+	before := countedTotal(config, &previous.RX, &previous.TX)
+	diff := new(big.Int).Sub(total, before)
+
+	arrow := "↑"
+	switch diff.Sign() {
+	case -1:
+		arrow = "↓"
+	case 0:
+		arrow = "±"
+	}
+	value := arrow + formatBytes(new(big.Int).Abs(diff))
+	if before.Sign() > 0 {
+		ratio, _ := new(big.Float).Quo(new(big.Float).SetInt(diff), new(big.Float).SetInt(before)).Float64()
+		value = fmt.Sprintf(tr("%+.1f%%（%s）"), ratio*100, value)
+	}
+	return notify.EmbedField{Name: comparisonLabel(record.Month), Value: value, Inline: false}
+}
+
+// previousUsageRecord は統計ファイルを置き換えた後など、統計ファイルに履歴がない場合に
+// key の前の月を history_db から探す。
+func previousUsageRecord(config *Config, key string) *PeriodRecord {
+	if config.HistoryDB == "" || keyPeriod(key) != "monthly" {
+		return nil
+	}
+	previous := previousPeriodKey(key)
+	entries, err := loadUsage(config.HistoryDB, interfaceDisplayName(config), monthlyBucket, previous, 1)
+	if err != nil {
+		slog.Warn("history_db の読み込みエラー", "path", config.HistoryDB, "error", err)
+		return nil
+	}
+	if len(entries) == 0 || entries[0].Key != previous {
+		return nil
+	}
+	return &PeriodRecord{Month: previous, RX: entries[0].RX, TX: entries[0].TX}
+}
@@ -0,0 +1,64 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"math/big"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// checkQuotaHook は集計した合計が警告値を超えたときに、期間ごとに1回 on_quota_exceeded を実行する。
+// RanQuotaHook は新しい期間が始まると元に戻る。読み取りのたびに繰り返さないよう、コマンドが失敗しても設定する。
+func checkQuotaHook(config *Config, stats *Stats, usedRX, usedTX *big.Int) {
+	if len(config.OnQuotaExceeded) == 0 || config.threshold == nil || stats.RanQuotaHook {
+		return
+	}
+	used := countedTotal(config, usedRX, usedTX)
+	if used.Cmp(config.threshold) < 0 {
+		return
+	}
+
+	if dryRun {
+		slog.Info("[dry-run] on_quota_exceeded の実行をスキップします", "command", strings.Join(config.OnQuotaExceeded, " "))
+		return
+	}
+	stats.RanQuotaHook = true
+	runQuotaHook(config, stats.Month, used)
+}
+
+func runQuotaHook(config *Config, period string, used *big.Int) {
+	timeout, _ := time.ParseDuration(config.OnQuotaExceededTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, config.OnQuotaExceeded[0], config.OnQuotaExceeded[1:]...)
+	cmd.Env = append(os.Environ(),
+		"LTC_INTERFACE="+interfaceDisplayName(config),
+		"LTC_PERIOD="+period,
+		"LTC_USED_BYTES="+used.String(),
+		"LTC_THRESHOLD_BYTES="+config.threshold.String(),
+	)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	slog.Warn("警告値を超えたため on_quota_exceeded を実行します", "interface", config.Interface, "command", strings.Join(config.OnQuotaExceeded, " "))
+	err := cmd.Run()
+	attrs := []any{"interface", config.Interface, "stdout", strings.TrimSpace(stdout.String()), "stderr", strings.TrimSpace(stderr.String())}
+	var exitErr *exec.ExitError
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		slog.Error("on_quota_exceeded がタイムアウトしました", append(attrs, "timeout", timeout)...)
+	case errors.As(err, &exitErr):
+		slog.Error("on_quota_exceeded が失敗しました", append(attrs, "exit_code", exitErr.ExitCode())...)
+	case err != nil:
+		slog.Error("on_quota_exceeded を実行できません", append(attrs, "error", err)...)
+	default:
+		slog.Info("on_quota_exceeded を実行しました", append(attrs, "exit_code", 0)...)
+	}
+}
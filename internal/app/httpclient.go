@@ -0,0 +1,71 @@
+package app
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// httpClient は通知・集約サーバー・時系列データベースへの書き込みなど、外に出るすべてのHTTPリクエストを送る。
+// Config.apply が http_proxy・ca_bundle・tls_min_version・http_timeout から作ったものに置き換える。
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// newHTTPClient は config のクライアントを作る。http_proxy がなければ既定のクライアントと同じく
+// HTTPS_PROXY・HTTP_PROXY・NO_PROXY からプロキシを決める。ca_bundle はシステムの CA に加えて信頼する。
+func newHTTPClient(config *Config) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if config.HTTPProxy != "" {
+		proxy, err := url.Parse(config.HTTPProxy)
+		if err != nil {
+			return nil, err
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	transport.TLSClientConfig = &tls.Config{MinVersion: tlsVersions[config.TLSMinVersion]}
+	if config.CABundle != "" {
+		pem, err := os.ReadFile(config.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("ca_bundle を読み込めません: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("ca_bundle にPEM形式の証明書がありません: %s", config.CABundle)
+		}
+		transport.TLSClientConfig.RootCAs = pool
+	}
+
+	timeout, err := time.ParseDuration(config.HTTPTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: transport, Timeout: timeout}, nil
+}
+
+func validateHTTPClient(config *Config) error {
+	if config.HTTPProxy != "" {
+		u, err := url.Parse(config.HTTPProxy)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "socks5") || u.Host == "" {
+			return fmt.Errorf("http_proxy は http://、https://、socks5:// で始まるURLを指定してください: %q", config.HTTPProxy)
+		}
+	}
+	if _, ok := tlsVersions[config.TLSMinVersion]; !ok {
+		return fmt.Errorf("tls_min_version は 1.2, 1.3 のいずれかを指定してください: %q", config.TLSMinVersion)
+	}
+	if timeout, err := time.ParseDuration(config.HTTPTimeout); err != nil || timeout <= 0 {
+		return fmt.Errorf("http_timeout には正の時間（例 \"30s\"）を指定してください: %q", config.HTTPTimeout)
+	}
+	return nil
+}
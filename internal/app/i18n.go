@@ -0,0 +1,159 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"strings"
+	"text/template"
+)
+
+// language は設定の language から決まる通知の言語。billingDay と同じく Config.apply で設定する。
+var language = "ja"
+
+// translations は日本語以外の言語の通知の文言を、日本語の文言をキーにして持つ。
+// 書式指定のある文言は、その順序を変えない。
+var translations = map[string]map[string]string{
+	"en": {
+		"%s の帯域を %s に制限（%s）":        "Limit %s to %s (%s)",
+		"%s の新しい期間が始まったため制限を解除しました": "A new period started on %s and the limit was lifted",
+		"%s の通信量が上限に達したため制限しました":    "%s reached its cap and was throttled",
+		"%s のジョブ %s が失敗しました":        "Job %[2]s failed on %[1]s",
+		"試行回数":                       "Attempts",
+		"原因":                         "Cause",
+		"{interface} の通信量（{period}）": "Traffic on {interface} ({period})",
+		"値":                          "Value",
+		"受信":                         "Received",
+		"失敗しました: %s":                 "Failed: %s",
+		"実行しました":                     "Done",
+		"送信":                         "Sent",
+		"合計":                         "Total",
+		"2006年1月":                    "January 2006",
+		"2006年1月2日":                  "Jan 2, 2006",
+		"1月2日":                       "Jan 2",
+		"〜":                          " - ",
+		"%sの週":                       "Week of %s",
+		"[テストモード] ":                  "[Test mode] ",
+		" — 計 ":                      " — total ",
+		"%+.1f%%（%s）":                "%+.1f%% (%s)",
+		"%.1f%%（上限 %s）":              "%.1f%% (cap %s)",
+		"%d台の通信量（%s）":                "Traffic of %d hosts (%s)",
+		"%s / %s（%.1f%%）":            "%s / %s (%.1f%%)",
+		"%s がリンクダウンしました":             "%s went down",
+		"%s の%sが上限を超えました":            "%s exceeded the %s cap",
+		"%s の%sが上限を超えました（%s）":          "%s exceeded the %s cap (%s)",
+		"%s のリンクが復旧しました":               "%s is up again",
+		"%s の監視を開始しました":                "Started monitoring %s",
+		"%s の通信速度が %g Mbps を超えています":    "%s is above %g Mbps",
+		"%s の通信量が上限の %s%% に達しました":      "%s reached %s%% of its quota",
+		"%s の通信量が警告値 %s を超えました":        "%s exceeded the warning level of %s",
+		"%s の通信量（%s）が普段と大きく異なります":      "Traffic on %s (%s) is far from usual",
+		"%s 単位（1単位 = %s）":              "%s units (1 unit = %s)",
+		"%s通信量":                        "%s traffic",
+		"%s（%.2f%%）":                   "%s (%.2f%%)",
+		"%s（上位%d）":                     "%s (top %d)",
+		"%s（上限）":                       "%s (cap)",
+		"linux-traffic-checker のテスト通知": "linux-traffic-checker test notification",
+		"インターフェース":                     "Interface",
+		"インターフェース別":                    "By interface",
+		"エラー":                          "Errors",
+		"コンテナ別":                        "By container",
+		"コンテナ（%s）":                     "Containers (%s)",
+		"パケット":                         "Packets",
+		"ベースライン（%s）":                   "Baseline (%s)",
+		"上限に対する割合":                     "Share of the cap",
+		"今後のレポートはこの時点からの通信量を集計します": "Reports count the traffic from this point on",
+		"今日の終わりの予測":   "Forecast for the end of the day",
+		"今月の使用量":      "Used this month",
+		"今月末の予測":      "Forecast for the end of the month",
+		"今週末の予測":      "Forecast for the end of the week",
+		"備考":          "Note",
+		"全インターフェース":   "All interfaces",
+		"全体の合計":       "Total of all hosts",
+		"前回通知: ":      "Last notified: ",
+		"前日比":         "Day over day",
+		"前月比":         "Month over month",
+		"前週比":         "Week over week",
+		"差":           "Difference",
+		"最多":          "Top",
+		"期間":          "Period",
+		"未報告":         "Not reported",
+		"残り":          "Remaining",
+		"状態":          "State",
+		"現在の速度":       "Current rate",
+		"直近%dか月の平均":   "Average of the last %d months",
+		"相当帯域":        "Equivalent bandwidth",
+		"破棄":          "Dropped",
+		"継続時間":        "Duration",
+		"課金単位":        "Billing units",
+		"過去%dか月の通信量":  "Traffic of the last %d months",
+		"項目":          "Item",
+		"（%.0f%%）":    " (%.0f%%)",
+		"（上限の%.0f%%）": " (%.0f%% of the cap)",
+		"（月間平均）":      " (monthly average)",
+		"（週間平均）":      " (weekly average)",
+		"（1日の平均）":     " (daily average)",
+		"（消失）":        " (removed)",
+		"超過料金":        "Overage cost",
+		"超過料金見込み":     "Projected overage cost",
+		"超過料金（現時点）":   "Overage cost so far",
+		"%s（現時点 %s）":  "%s (so far %s)",
+		"通信量（%s）":     "Traffic (%s)",
+		"最終読み取り":      "Last read",
+		"現在":          "Now",
+		"%s 時点":       "As of %s",
+	},
+}
+
+// tr は text を設定した言語で返す。訳のない文言は日本語のまま。
+func tr(text string) string {
+	if translated, ok := translations[language][text]; ok {
+		return translated
+	}
+	return text
+}
+
+// messageData は report_title と field_labels がテンプレートとして参照できる値（例 "{{.Interface}}"）。
+type messageData struct {
+	Interface string
+	Period    string
+}
+
+func parseMessageTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).Option("missingkey=error").Parse(text)
+}
+
+// expandMessage は text にテンプレートがあれば実行し、その後 {interface} と {period} を置き換える。
+// 失敗したテンプレートはログに残し、そのまま使う。
+func expandMessage(name, text string, data messageData) string {
+	if strings.Contains(text, "{{") {
+		tmpl, err := parseMessageTemplate(name, text)
+		var buf bytes.Buffer
+		if err == nil {
+			err = tmpl.Execute(&buf, data)
+		}
+		if err != nil {
+			slog.Warn("テンプレートの展開エラー", "name", name, "error", err)
+		} else {
+			text = buf.String()
+		}
+	}
+	return strings.NewReplacer("{interface}", data.Interface, "{period}", data.Period).Replace(text)
+}
+
+func validateMessages(config *Config) error {
+	if config.Language != "ja" {
+		if _, ok := translations[config.Language]; !ok {
+			return fmt.Errorf("language は ja, en のいずれかを指定してください: %q", config.Language)
+		}
+	}
+	if _, err := parseMessageTemplate("report_title", config.ReportTitle); err != nil {
+		return fmt.Errorf("report_title のテンプレートが不正です: %w", err)
+	}
+	for key, label := range config.FieldLabels {
+		if _, err := parseMessageTemplate("field_labels."+key, label); err != nil {
+			return fmt.Errorf("field_labels の %s のテンプレートが不正です: %w", key, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,254 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rakku1234/linux-traffic-checker/collector"
+	"github.com/rakku1234/linux-traffic-checker/report"
+	"github.com/rakku1234/linux-traffic-checker/store"
+)
+
+func (config *Config) multiInterface() bool {
+	return len(config.Interfaces) > 1 || config.groupByInterface()
+}
+
+// groupByInterface は interface_pattern に一致するインターフェース（"all" ならすべて）を合計ではなく
+// 個別にレポートするかどうかを返す。
+func (config *Config) groupByInterface() bool {
+	return config.GroupBy == "interface" && (config.InterfacePattern != "" || config.Interface == allInterfaces)
+}
+
+// monitoredInterfaces はそれぞれの範囲を持つインターフェースで、interfaces のリストか、group_by が
+// "interface" なら再読み込みなしで新しいものも拾えるよう、今一致するインターフェース。
+func monitoredInterfaces(config *Config) []string {
+	if !config.groupByInterface() {
+		return config.Interfaces
+	}
+	var counters map[string]*collector.InterfaceStats
+	var err error
+	if config.NetnsName != "" {
+		var data []byte
+		data, err = readNetDevInNetns(config.NetnsName)
+		if err == nil {
+			counters, err = filterNetDev(string(data), config)
+		}
+	} else {
+		counters, err = readAllNetworkBytes(config)
+	}
+	if err != nil {
+		slog.Warn("インターフェースの一覧を読み込めません", "error", err)
+		return nil
+	}
+	return report.SortedInterfaceNames(counters)
+}
+
+// interfaceConfig は監視するインターフェースの1つの設定。
+func interfaceConfig(config *Config, name string) *Config {
+	scoped := *config
+	scoped.Interface = name
+	scoped.Interfaces = nil
+	scoped.InterfacePattern = ""
+	scoped.GroupBy = ""
+	return &scoped
+}
+
+// interfaceScope は監視するインターフェースの1つの設定と統計。複数のインターフェースを監視する場合は
+// それぞれが Stats.PerInterface に自分のベースラインを持ち、そうでなければ最上位の設定と統計になる。
+type interfaceScope struct {
+	config *Config
+	stats  *Stats
+	// created はインターフェースにまだベースラインがない場合に設定する。
+	created bool
+}
+
+func interfaceScopes(config *Config, stats *Stats) []interfaceScope {
+	if !config.multiInterface() {
+		return []interfaceScope{{config: config, stats: stats}}
+	}
+
+	if stats.PerInterface == nil {
+		stats.PerInterface = map[string]*Stats{}
+	}
+	names := monitoredInterfaces(config)
+	scopes := make([]interfaceScope, 0, len(names))
+	for _, name := range names {
+		sub, ok := stats.PerInterface[name]
+		if !ok {
+			sub = &Stats{}
+			stats.PerInterface[name] = sub
+		}
+		// 最初のレポートの前に他のジョブが作った統計には、まだベースラインがない。
+		scopes = append(scopes, interfaceScope{config: interfaceConfig(config, name), stats: sub, created: sub.Month == ""})
+	}
+	return scopes
+}
+
+// latestReadAt はすべてのインターフェースのうち最も新しいカウンタの読み取りの時刻を返す。
+func latestReadAt(config *Config, stats *Stats) time.Time {
+	var latest time.Time
+	for _, scope := range interfaceScopes(config, stats) {
+		if scope.stats.LastReadAt.After(latest) {
+			latest = scope.stats.LastReadAt
+		}
+	}
+	return latest
+}
+
+// scopeConfig はダイジェストの記録が属するインターフェースの設定を返す。
+func scopeConfig(config *Config, record *PeriodRecord) *Config {
+	if record.Interface == "" {
+		return config
+	}
+	return interfaceConfig(config, record.Interface)
+}
+
+// migrateStats は複数のインターフェースを設定した場合に、1つのインターフェース用に書かれた統計ファイルの
+// ベースラインをそのインターフェースの下に移す。`interfaces` に切り替えても今月の分は失われない。
+func migrateStats(config *Config, stats *Stats) {
+	if !config.multiInterface() || stats.PerInterface != nil || stats.Month == "" {
+		return
+	}
+
+	if config.groupByInterface() {
+		// 合計のベースラインはどのインターフェースのものでもないため、インターフェースごとに数え直す。
+		*stats = Stats{
+			PendingDigest:  stats.PendingDigest,
+			FleetReports:   stats.FleetReports,
+			LastNotified:   stats.LastNotified,
+			AppliedResetAt: stats.AppliedResetAt,
+			PerInterface:   map[string]*Stats{},
+		}
+		slog.Info("group_by が interface になったため、インターフェースごとに集計をやり直します")
+		return
+	}
+
+	name := config.Interfaces[0]
+	if slices.Contains(config.Interfaces, config.Interface) {
+		name = config.Interface
+	}
+
+	legacy := *stats
+	legacy.PendingDigest = nil
+	legacy.FleetReports = nil
+	*stats = Stats{
+		PendingDigest:  stats.PendingDigest,
+		FleetReports:   stats.FleetReports,
+		LastNotified:   stats.LastNotified,
+		AppliedResetAt: stats.AppliedResetAt,
+		PerInterface:   map[string]*Stats{name: &legacy},
+	}
+	for i := range stats.PendingDigest {
+		stats.PendingDigest[i].Interface = name
+	}
+	slog.Info("単一インターフェースの統計を複数インターフェース用の形式に移行しました", "interface", name)
+}
+
+// migratingStore は元の Store が読み込んだ統計に migrateStats を適用する。
+type migratingStore struct {
+	store.Store[Stats]
+	config *Config
+}
+
+func (s migratingStore) Load() (*Stats, bool, error) {
+	stats, isFirstRun, err := s.Store.Load()
+	if err != nil {
+		return nil, false, err
+	}
+	migrateStats(s.config, stats)
+	return stats, isFirstRun, nil
+}
+
+// totalRecord は合計の埋め込みのため、すべてのインターフェースの記録を1つにまとめる。
+func totalRecord(records []scopedRecord) *PeriodRecord {
+	total := &PeriodRecord{Month: records[0].record.Month, Interfaces: map[string]*collector.InterfaceStats{}, Nftables: records[0].record.Nftables}
+	for _, r := range records {
+		total.RX.Add(&total.RX, &r.record.RX)
+		total.TX.Add(&total.TX, &r.record.TX)
+		total.Interfaces[r.config.Interface] = &collector.InterfaceStats{RX: *new(big.Int).Set(&r.record.RX), TX: *new(big.Int).Set(&r.record.TX)}
+		if r.record.Packets != nil {
+			if total.Packets == nil {
+				total.Packets = &collector.PacketCounts{}
+			}
+			total.Packets.Add(r.record.Packets)
+		}
+	}
+	return total
+}
+
+// UnmarshalJSON は "interface" を1つの名前としても名前のリストとしても受け付ける。リストは "interfaces" として読む。
+func (config *Config) UnmarshalJSON(data []byte) error {
+	type plain Config
+	aux := struct {
+		*plain
+		Interface json.RawMessage `json:"interface"`
+	}{plain: (*plain)(config)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	if len(aux.Interface) == 0 || string(aux.Interface) == "null" {
+		return nil
+	}
+
+	if err := json.Unmarshal(aux.Interface, &config.Interface); err == nil {
+		return nil
+	}
+	var names []string
+	if err := json.Unmarshal(aux.Interface, &names); err != nil {
+		return fmt.Errorf("interface にはインターフェース名か、その配列を指定してください: %s", aux.Interface)
+	}
+	if len(config.Interfaces) > 0 {
+		return fmt.Errorf("interface を配列で指定する場合は interfaces と同時に指定できません")
+	}
+	config.Interfaces = names
+	return nil
+}
+
+func validateInterfaces(config *Config) error {
+	if config.Interface == autoInterface && !config.multiInterface() {
+		if config.InterfacePattern != "" || config.ContainerRuntime != "" || config.NetnsName != "" {
+			return fmt.Errorf("interface: \"auto\" は interface_pattern、container_runtime、netns_name と同時に指定できません")
+		}
+	}
+	if err := report.ValidateInterfacePattern(config.InterfacePattern); err != nil {
+		return fmt.Errorf("interface_pattern: %w", err)
+	}
+	for _, pattern := range config.ExcludeInterfaces {
+		if err := report.ValidateInterfacePattern(pattern); err != nil {
+			return fmt.Errorf("exclude_interfaces: %w", err)
+		}
+	}
+	switch config.GroupBy {
+	case "total":
+	case "interface":
+		if config.InterfacePattern == "" && config.Interface != allInterfaces {
+			return fmt.Errorf("group_by: \"interface\" には interface_pattern か interface: \"all\" を指定してください")
+		}
+		if len(config.Interfaces) > 0 || config.ContainerRuntime != "" || config.CounterCommand != "" {
+			return fmt.Errorf("group_by: \"interface\" は interfaces、container_runtime、counter_command と同時に指定できません")
+		}
+		return nil
+	default:
+		return fmt.Errorf("group_by は total, interface のいずれかを指定してください: %q", config.GroupBy)
+	}
+	if !config.multiInterface() {
+		return nil
+	}
+	if config.aggregate() || config.CounterCommand != "" {
+		return fmt.Errorf("interfaces は interface: \"all\"、interface_pattern、counter_command と同時に指定できません")
+	}
+	for _, name := range config.Interfaces {
+		if report.IsInterfacePattern(name) {
+			return fmt.Errorf("interfaces にパターンは指定できません（interface_pattern と group_by: \"interface\" を使ってください）: %q", name)
+		}
+		if name == "" || name == allInterfaces || name == autoInterface || strings.ContainsAny(name, " :") {
+			return fmt.Errorf("interfaces に不正なインターフェース名があります: %q", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/rakku1234/linux-traffic-checker/notify"
+)
+
+// retryJob はエラーを返すジョブをスケジューラーのタスクにする。予定の時刻の短い障害でプロセスが止まったり
+// 実行が失われたりしないよう、失敗したら job_retry_interval、その後は倍ずつ待って job_retries 回まで再試行する。
+// 再試行で直らないエラーは再試行せず、ctx が終わると待ちを打ち切る。すべて失敗したら job_failure_notifiers に知らせる。
+func retryJob(ctx context.Context, name string, job func(*Config) error) func(*Config) {
+	return func(config *Config) {
+		attempts, err := runJob(ctx, name, job, config)
+		if err == nil {
+			return
+		}
+		jobFailed.Store(true)
+		slog.Error("ジョブが失敗しました", "job", name, "attempts", attempts, "error", err)
+		notifyJobFailure(config, name, attempts, err)
+	}
+}
+
+func runJob(ctx context.Context, name string, job func(*Config) error, config *Config) (int, error) {
+	retries := *config.JobRetries
+	if dryRun {
+		retries = 0
+	}
+	wait, _ := time.ParseDuration(config.JobRetryInterval)
+	for attempt := 1; ; attempt++ {
+		err := job(config)
+		var permanent *PermanentError
+		if err == nil || attempt > retries || errors.As(err, &permanent) {
+			return attempt, err
+		}
+
+		slog.Warn("ジョブが失敗したため再実行します", "job", name, "attempt", attempt, "wait", wait, "error", err)
+		select {
+		case <-ctx.Done():
+			return attempt, err
+		case <-clock.After(wait):
+		}
+		wait *= 2
+	}
+}
+
+// notifyJobFailure はジョブの失敗を job_failure_notifiers に知らせる。失敗したかもしれない通知先とは別の経路を指定しておく。
+func notifyJobFailure(config *Config, name string, attempts int, jobErr error) {
+	if len(config.JobFailureNotifiers) == 0 {
+		return
+	}
+	secondary := *config
+	secondary.Notifiers = config.JobFailureNotifiers
+
+	embed := notify.Embed{
+		Title: fmt.Sprintf(tr("%s のジョブ %s が失敗しました"), interfaceDisplayName(config), name),
+		Color: 0xff0000,
+		Fields: []notify.EmbedField{
+			{Name: tr("試行回数"), Value: strconv.Itoa(attempts), Inline: true},
+			{Name: tr("原因"), Value: notify.Truncate(jobErr.Error(), 1024), Inline: false},
+		},
+		Timestamp: clock.Now().UTC().Format(time.RFC3339),
+		Interface: embedInterface(config),
+	}
+	retryBudget, _ := time.ParseDuration(config.RetryBudget)
+	err := notifyAll(&secondary, newRetryBudget(retryBudget), func(notifier Notifier) error {
+		return notifier.SendEmbeds(notify.KindAlert, []notify.Embed{embed})
+	})
+	if err != nil {
+		slog.Error("ジョブの失敗を通知できませんでした", "job", name, "notifiers", config.JobFailureNotifiers, "error", err)
+	}
+}
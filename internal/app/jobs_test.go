@@ -0,0 +1,126 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+)
+
+func TestRecoverTaskKeepsSchedulerRunning(t *testing.T) {
+	s, err := gocron.NewScheduler()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Shutdown()
+
+	var panics, runs atomic.Int32
+	panicking := func(*Config) {
+		panics.Add(1)
+		panic("統計ファイルが壊れています")
+	}
+	_, err = s.NewJob(gocron.DurationJob(10*time.Millisecond), gocron.NewTask(recoverTask("Panicking", panicking, &Config{})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.NewJob(gocron.DurationJob(10*time.Millisecond), gocron.NewTask(recoverTask("Counting", func(*Config) { runs.Add(1) }, &Config{})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Start()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for panics.Load() < 3 || runs.Load() < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("after %d panics the other job ran %d times, want 3", panics.Load(), runs.Load())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRecoverTaskReturnsAfterPanic(t *testing.T) {
+	ran := false
+	recoverTask("Panicking", func(*Config) { panic("boom") }, &Config{})()
+	recoverTask("Next", func(*Config) { ran = true }, &Config{})()
+	if !ran {
+		t.Error("the task after a panic did not run")
+	}
+}
+
+func TestReportRetryAfterRolloverOnlyResends(t *testing.T) {
+	config, counters := pollConfig(t, `{"month": "2026-09", "rx": 1000, "tx": 500, "last_rx": 1000, "last_tx": 500, "last_read_at": "2026-09-30T23:55:00Z"}`)
+	if err := os.WriteFile(counters, []byte("rx=3000 tx=1500\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// 最初の送信は再試行しない 400 で失敗させる。
+	server, calls := statusServer(t, "", http.StatusBadRequest, http.StatusNoContent)
+	config.WebhookURL = server.URL
+
+	job := reportJob()
+	if err := job(config); err == nil {
+		t.Fatal("the first attempt succeeded, want the notification failure")
+	}
+	stats, _, err := config.store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Month != "2026-10" || len(stats.UnsentReports) != 1 {
+		t.Fatalf("after the failure month %s with %d unsent reports, want 2026-10 with 1", stats.Month, len(stats.UnsentReports))
+	}
+
+	// 再試行は締めた期間のレポートを送り直すだけで、新しい期間の途中経過は送らない。
+	if err := job(config); err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("sent %d requests, want the failed one and the resend", calls.Load())
+	}
+	stats, _, err = config.store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats.UnsentReports) != 0 {
+		t.Errorf("%d reports still unsent", len(stats.UnsentReports))
+	}
+}
+
+func TestReportRetryReportsUnsentFailure(t *testing.T) {
+	config, _ := pollConfig(t, `{"month": "2026-10", "rx": 1000, "tx": 500, "unsent_reports": [{"month": "2026-09", "rx": 2000, "tx": 1000}]}`)
+	server, _ := statusServer(t, "", http.StatusBadRequest)
+	config.WebhookURL = server.URL
+
+	// 送り直しの失敗は、前のレポートしか残っていなくても成功として扱わない。
+	if err := flushUnsent(config); err == nil {
+		t.Error("flushUnsent succeeded although the resend failed")
+	}
+}
+
+func TestSendDigestKeepsOnlyUnsentChunks(t *testing.T) {
+	var pending []string
+	for month := range 12 {
+		pending = append(pending, fmt.Sprintf(`{"month": "2025-%02d", "rx": 1000, "tx": 500}`, month+1))
+	}
+	config, _ := pollConfig(t, `{"month": "2026-10", "pending_digest": [`+strings.Join(pending, ",")+`]}`)
+	// 10個ずつ送るので、最初のまとまりは届いて2つ目が失敗する。
+	server, calls := statusServer(t, "", http.StatusNoContent, http.StatusBadRequest)
+	config.WebhookURL = server.URL
+
+	if err := sendDigest(config); err == nil {
+		t.Fatal("sendDigest succeeded although the second chunk failed")
+	}
+	stats, _, err := config.store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats.PendingDigest) != 2 || stats.PendingDigest[0].Month != "2025-11" {
+		t.Errorf("%d reports still pending, want the 2 from 2025-11 that were not sent", len(stats.PendingDigest))
+	}
+	if calls.Load() != 2 {
+		t.Errorf("sent %d requests, want 2", calls.Load())
+	}
+}
@@ -0,0 +1,115 @@
+package app
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rakku1234/linux-traffic-checker/notify"
+)
+
+func readOperState(interfaceName string) (string, error) {
+	data, err := os.ReadFile(filepath.Join("/sys/class/net", interfaceName, "operstate"))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func linkClass(operState string) string {
+	switch operState {
+	case "up":
+		return "up"
+	case "down", "lowerlayerdown", "notpresent":
+		return "down"
+	default:
+		return ""
+	}
+}
+
+func linkEmbed(interfaceName, state string) notify.Embed {
+	embed := notify.Embed{
+		Timestamp: clock.Now().UTC().Format(time.RFC3339),
+		Fields: []notify.EmbedField{
+			{Name: tr("状態"), Value: state, Inline: true},
+		},
+	}
+	if state == "down" {
+		embed.Title = fmt.Sprintf(tr("%s がリンクダウンしました"), interfaceName)
+		embed.Color = 0xff0000
+	} else {
+		embed.Title = fmt.Sprintf(tr("%s のリンクが復旧しました"), interfaceName)
+		embed.Color = 0x00ff7f
+	}
+	return embed
+}
+
+func CheckInterfaceState(config *Config) {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+
+	unlock, err := lockStats(config)
+	if err != nil {
+		slog.Error("統計ファイルのロック取得エラー", "error", err)
+		return
+	}
+	defer unlock()
+
+	if config.aggregate() {
+		return
+	}
+
+	stats, _, err := config.store.Load()
+	if err != nil {
+		slog.Error("統計ファイルの読み込みエラー", "error", err)
+		return
+	}
+
+	var embeds []notify.Embed
+	for _, scope := range interfaceScopes(config, stats) {
+		device, err := resolveInterface(scope.config)
+		var operState string
+		switch {
+		case err != nil:
+		case scope.config.SNMPHost != "":
+			operState, err = readSNMPOperState(scope.config)
+		default:
+			operState, err = readOperState(device.Interface)
+		}
+		if err != nil {
+			operState = "notpresent"
+		}
+		state := linkClass(operState)
+		if state == "" || scope.stats.LinkState == state {
+			continue
+		}
+
+		previous := scope.stats.LinkState
+		scope.stats.LinkState = state
+		if previous == "" {
+			continue
+		}
+		slog.Warn("リンク状態の変化を検出しました", "interface", scope.config.Interface, "from", previous, "to", state)
+		embeds = append(embeds, linkEmbed(scope.config.Interface, state))
+	}
+
+	err = config.store.Save(stats)
+	if err != nil {
+		slog.Error("統計ファイルの保存エラー", "error", err)
+		return
+	}
+	if len(embeds) == 0 {
+		return
+	}
+
+	retryBudget, _ := time.ParseDuration(config.RetryBudget)
+	err = notifyAll(config, newRetryBudget(retryBudget), func(notifier Notifier) error {
+		return notifier.SendEmbeds(notify.KindAlert, embeds)
+	})
+	if err != nil {
+		slog.Error("通知の送信エラー", "error", err)
+	}
+}
@@ -0,0 +1,95 @@
+package app
+
+import (
+	"crypto/rand"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/rakku1234/linux-traffic-checker/store"
+)
+
+func lockStats(config *Config) (func(), error) {
+	if config.StorageBackend == "redis" {
+		return lockRedis(config)
+	}
+	if config.StatsFile == stdioStatsFile {
+		return func() {}, nil
+	}
+
+	lockFile := config.StatsFile + ".lock"
+	file, err := os.OpenFile(lockFile, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	locked, err := waitLock(config, lockFile, func() (bool, error) { return tryLockFile(file) })
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if !locked {
+		file.Close()
+		return func() {}, nil
+	}
+	return func() {
+		unlockFile(file)
+		file.Close()
+	}, nil
+}
+
+// lockRedis は redis_key を共有するホストの間で統計の読み書きを直列にする。
+// Redis に接続できない間は Load と Save と同じくメモリ上の統計で続けるため、ロックなしで続行する。
+func lockRedis(config *Config) (func(), error) {
+	redis, err := store.NewRedisStore[Stats](config.RedisURL, config.RedisKey)
+	if err != nil {
+		return nil, err
+	}
+	token := rand.Text()
+	locked, err := waitLock(config, "redis:"+redis.LockKey(), func() (bool, error) {
+		locked, err := redis.TryLock(token)
+		if err != nil {
+			slog.Warn("Redisに接続できないため、ロックなしで処理を続行します", "key", redis.LockKey(), "error", err)
+			return true, nil
+		}
+		return locked, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !locked {
+		return func() {}, nil
+	}
+	return func() { redis.Unlock(token) }, nil
+}
+
+// waitLock は lock_timeout まで tryLock を繰り返す。取得できなかった場合、lock_timeout_action が
+// "proceed" なら false と nil を返し、それ以外はエラーを返す。
+func waitLock(config *Config, name string, tryLock func() (bool, error)) (bool, error) {
+	timeout, err := time.ParseDuration(config.LockTimeout)
+	if err != nil {
+		return false, fmt.Errorf("lock_timeout が不正です: %w", err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		locked, err := tryLock()
+		if err != nil {
+			return false, err
+		}
+		if locked {
+			return true, nil
+		}
+		if time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	if config.LockTimeoutAction == "proceed" {
+		slog.Warn("統計のロックを取得できないまま処理を続行します", "lock", name, "timeout", timeout)
+		return false, nil
+	}
+	return false, fmt.Errorf("%s のロックを %s 以内に取得できませんでした", name, timeout)
+}
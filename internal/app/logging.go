@@ -0,0 +1,70 @@
+package app
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"os"
+)
+
+var logLevels = map[string]slog.Level{
+	"debug": slog.LevelDebug,
+	"info":  slog.LevelInfo,
+	"warn":  slog.LevelWarn,
+	"error": slog.LevelError,
+}
+
+// setupLogger は log_format と log_level を既定のロガーに適用する。
+// text は今までのログの解析がそのまま使えるよう、標準の log の出力のまま。
+func setupLogger(config *Config) {
+	level := logLevels[config.LogLevel]
+	switch config.LogFormat {
+	case "json":
+		slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
+		return
+	case "journald":
+		slog.SetDefault(slog.New(slog.NewTextHandler(journaldWriter{os.Stderr}, &slog.HandlerOptions{
+			Level: level,
+			// journaldが受信した時刻を記録するため、時刻は出力しない。
+			ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
+				if len(groups) == 0 && attr.Key == slog.TimeKey {
+					return slog.Attr{}
+				}
+				return attr
+			},
+		})))
+		return
+	}
+	slog.SetLogLoggerLevel(level)
+}
+
+// journaldPriorities は text のハンドラーの各行の先頭の level= に対応する sd-daemon(3) の syslog の優先度。
+var journaldPriorities = []struct {
+	prefix   string
+	priority string
+}{
+	{"level=DEBUG", "<7>"},
+	{"level=INFO", "<6>"},
+	{"level=WARN", "<4>"},
+	{"level=ERROR", "<3>"},
+}
+
+// journaldWriter はログの各行の先頭に優先度を付ける。journald がレベルを残すので、
+// `journalctl -p warning` で絞り込める。
+type journaldWriter struct {
+	w io.Writer
+}
+
+func (w journaldWriter) Write(line []byte) (int, error) {
+	priority := "<6>"
+	for _, p := range journaldPriorities {
+		if bytes.HasPrefix(line, []byte(p.prefix)) {
+			priority = p.priority
+			break
+		}
+	}
+	if _, err := io.WriteString(w.w, priority); err != nil {
+		return 0, err
+	}
+	return w.w.Write(line)
+}
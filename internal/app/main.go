@@ -0,0 +1,2320 @@
+// Package app は linux-traffic-checker コマンドの本体で、設定の読み込み・スケジュール・各通知先の実装を持つ。
+// 他のプログラムから使う部分は collector・store・report・notify パッケージにある。
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime/debug"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/rakku1234/linux-traffic-checker/collector"
+	"github.com/rakku1234/linux-traffic-checker/notify"
+	"github.com/rakku1234/linux-traffic-checker/report"
+	"github.com/rakku1234/linux-traffic-checker/store"
+	"github.com/robfig/cron/v3"
+)
+
+type Config struct {
+	TimeZone string `json:"timezone"`
+	// ログの形式（"text"（既定）、"json"、"journald"）と、出力する最低レベル（"debug"、"info"（既定）、"warn"、"error"）。
+	LogFormat string `json:"log_format"`
+	LogLevel  string `json:"log_level"`
+	// "all" を指定すると exclude_interfaces に一致しない全インターフェースの合計を集計し、
+	// 最も多く通信したインターフェースも表示する。
+	// "auto" を指定すると読み取りのたびにデフォルトルートのインターフェースを調べて集計する。
+	// ["eth0", "wg0"] のように配列で指定すると interfaces と同じ意味になる。
+	Interface string `json:"interface"`
+	// 複数のインターフェースを個別に監視する場合に指定する。インターフェースごとに集計し、
+	// レポートにはインターフェースごとの埋め込みと合計の埋め込みを並べる。1つだけなら interface と同じ。
+	Interfaces []string `json:"interfaces"`
+	// "-" を指定すると統計を標準入力から読み込み、1回だけ処理して更新後の統計を標準出力に書き出す。
+	StatsFile  string `json:"stats_file"`
+	WebhookURL string `json:"discord_webhook_url"`
+	// 同じ通知を送る追加のDiscord Webhook。discord_webhook_url と合わせて全てに同時に送り、
+	// 1つでも届けば成功とする。
+	WebhookURLs []string `json:"discord_webhook_urls"`
+	BotName     string   `json:"bot_name"`
+	// 送るメッセージの種類とインターフェースを個別に絞り込めるDiscord Webhook。
+	DiscordWebhooks []DiscordWebhook `json:"discord_webhooks"`
+	// true の場合、通知先の設定が足りなければ起動時にエラーにする。notifiers に discord があれば Discord の Webhook URL が、
+	// それ以外は少なくとも1つの通知先の設定が必要。false なら未設定の通知先をスキップして動作を続ける。
+	WebhookRequired bool `json:"webhook_required"`
+	// /proc/net/dev の代わりにこのコマンドの標準出力からカウンタを読み取る。
+	// counter_regex には名前付きグループ rx と tx を含め、それぞれバイト数に一致させる。
+	CounterCommand string `json:"counter_command"`
+	CounterRegex   string `json:"counter_regex"`
+	// カウンタのビット幅（32 か 64）。桁あふれの検出に使う。省略時は SNMP で32ビットのカウンタを読んだ場合だけ 32、それ以外は 64。
+	CounterBits int `json:"counter_bits"`
+	// SNMPで読み取る機器（ルーターなど）のアドレス（例 "192.168.1.1" や "router:161"）。指定すると interface の ifName
+	// （または snmp_if_index の ifIndex）のインターフェースのカウンタを、ローカルのインターフェースの代わりに読み取る。
+	// snmp_version は "1"、"2c"（既定）、"3"。"3" では snmp_user と、必要に応じて認証（MD5、SHA、SHA256 など）と暗号化（DES、AES など）を指定する。
+	SNMPHost         string `json:"snmp_host"`
+	SNMPVersion      string `json:"snmp_version"`
+	SNMPCommunity    string `json:"snmp_community"`
+	SNMPUser         string `json:"snmp_user"`
+	SNMPAuthProtocol string `json:"snmp_auth_protocol"`
+	SNMPAuthPassword string `json:"snmp_auth_password"`
+	SNMPPrivProtocol string `json:"snmp_priv_protocol"`
+	SNMPPrivPassword string `json:"snmp_priv_password"`
+	SNMPIfIndex      int    `json:"snmp_if_index"`
+	// 1回の問い合わせの待ち時間（既定 5s）。
+	SNMPTimeout string `json:"snmp_timeout"`
+	// 合計・上限判定・表示の対象にする方向。"both"（既定）、"rx"、"tx"。
+	CountDirection string `json:"count_direction"`
+	// 月の境界をまたいだ分の扱い。"job"（既定）はジョブ実行時点で区切り、実行が遅れた分は前月に含まれる。
+	// "interpolate" は前回の読み取りと今回の読み取りから境界時点のカウンタを線形補間して区切る。
+	// 補間の精度は読み取り間隔に依存するため、頻繁に読み取っているほど正確になる。
+	BoundaryMode string `json:"boundary_mode"`
+	// true の場合、起動時に前回の実行以降のスケジュールを取りこぼしていれば直ちにレポートを実行する。
+	CatchUpMissed bool `json:"catch_up_missed"`
+	// true の場合、初回起動時にベースラインを設定した旨を通知する。既定では初回は通知しない。
+	ReportOnFirstRun bool `json:"report_on_first_run"`
+	// DigestSchedule を設定すると、締めた期間のレポートを都度送らずに溜めておき、
+	// このcron式のタイミングでまとめて1通のダイジェストとして送信する。
+	DigestSchedule string `json:"digest_schedule"`
+	// 月間の通信量上限（バイト）。cap_bytes は合計、rx_cap_bytes と tx_cap_bytes は方向ごとの上限で、
+	// それぞれ独立して超過時に月1回アラートを送る。pagerduty_routing_key があればインシデントも起票する。
+	// cap_basis でどの上限を判定するかを選ぶ（combined / rx / tx / both）。未指定なら設定された上限をすべて判定する。
+	// 判定は count_direction による表示とは独立で、表示されない方向の上限は別フィールドで示す。
+	CapBasis            string `json:"cap_basis"`
+	CapBytes            int64  `json:"cap_bytes"`
+	RXCapBytes          int64  `json:"rx_cap_bytes"`
+	TXCapBytes          int64  `json:"tx_cap_bytes"`
+	PagerDutyRoutingKey string `json:"pagerduty_routing_key"`
+	// 次の単位に切り替える境目を、次の単位の何倍にするか（既定 1）。
+	// 例えば 1000 なら 1000 KiB 未満は B のまま、1000 MiB 未満は KiB のまま表示する。
+	UnitThreshold float64 `json:"unit_threshold"`
+	// 通知の言語。"ja"（既定）または "en"。
+	Language string `json:"language"`
+	// 埋め込みの項目名を "rx"、"tx"、"total" のキーごとに上書きする。未指定のものは既定の表記になる。
+	// {{.Interface}} のようにGoのテンプレートも使える。
+	FieldLabels map[string]string `json:"field_labels"`
+	// レポートのタイトル。{interface} はインターフェース名、{period} は期間に置き換わる。
+	// {{.Interface}}、{{.Period}} のようにGoのテンプレートも使える。
+	// 空なら "{interface} の通信量（{period}）"（language が "en" なら "Traffic on {interface} ({period})"）。
+	ReportTitle string `json:"report_title"`
+	// レポートの埋め込みの色。10進数または "0x1e90ff" のような16進数で指定する（既定 0x00bfff）。
+	EmbedColor *EmbedColor `json:"embed_color"`
+	// true の場合、埋め込みのタイトル末尾に合計通信量を付ける。
+	TitleIncludeTotal bool `json:"title_include_total"`
+	// true の場合、期間の合計を平均帯域（bps）に換算した値をレポートに載せる。
+	ShowEquivalentBandwidth bool `json:"show_equivalent_bandwidth"`
+	// ISPが通信量を一定バイト数の単位で数える場合のその単位。合計を切り上げた単位数をレポートに載せる。
+	BillingUnitBytes int64 `json:"billing_unit_bytes"`
+	// true でレポートに前の期間との比較（前月比など）を表示する。前の期間が履歴にない場合は表示しない。
+	ShowPeriodComparison bool `json:"show_period_comparison"`
+	// true で期間の途中のレポートに期間末の通信量の予測を表示する。history_db があれば直近の日ごとの平均から、
+	// なければ期間の始めからの平均から予測し、quota_bytes（なければ cap_bytes）に対する割合も載せる。
+	ShowForecast bool `json:"show_forecast"`
+	// 通信量が included_gb を超えた分に price_per_gb を掛けた超過料金をレポートに載せる。
+	// show_forecast も指定すると、期間の途中のレポートには期間末の見込みを載せる。
+	Pricing *Pricing `json:"pricing"`
+	// 統計ファイルに残す締めた期間の数（既定 24）。
+	HistoryLimit int `json:"history_limit"`
+	// 日ごと・月ごとの通信量をインターフェース別に記録するデータベース（bbolt）のパス。
+	// 統計ファイルと違い期間が変わっても消えない。
+	HistoryDB string `json:"history_db"`
+	// 1以上でレポートに history_db の直近この月数の通信量を載せる。
+	HistoryMonths int `json:"history_months"`
+	// カウンタを読み取るたびに、前回からの受信・送信の差分を line protocol で timeseries_url の
+	// /api/v2/write に書き込む。InfluxDB v2 では timeseries_org・timeseries_bucket・timeseries_token も指定する。
+	// 測定名は timeseries_measurement（既定 "linux_traffic"）。
+	TimeseriesURL         string `json:"timeseries_url"`
+	TimeseriesOrg         string `json:"timeseries_org"`
+	TimeseriesBucket      string `json:"timeseries_bucket"`
+	TimeseriesToken       string `json:"timeseries_token"`
+	TimeseriesMeasurement string `json:"timeseries_measurement"`
+	// MQTTブローカー（例 "tcp://broker:1883"）に mqtt_interval（既定 1m）ごとに今期の通信量と速度を送る。
+	// Home Assistant の MQTT Discovery に対応し、mqtt_discovery_prefix（既定 "homeassistant"）にセンサーを登録する。
+	// 状態は mqtt_topic_prefix（既定 "linux-traffic-checker"）/<ホスト名>/<インターフェース>/state に送る。
+	MQTTBrokerURL       string `json:"mqtt_broker_url"`
+	MQTTUsername        string `json:"mqtt_username"`
+	MQTTPassword        string `json:"mqtt_password"`
+	MQTTTopicPrefix     string `json:"mqtt_topic_prefix"`
+	MQTTDiscoveryPrefix string `json:"mqtt_discovery_prefix"`
+	MQTTInterval        string `json:"mqtt_interval"`
+	// true でレポートに期間中の受信・送信パケット数を載せる。エラーと破棄の数は show_packets か
+	// drop_warning_percent のどちらかを指定すると載せる。
+	ShowPackets bool `json:"show_packets"`
+	// 期間中に破棄されたパケットの割合（%）がこの値を超えると、レポートの埋め込みを警告色にする。
+	DropWarningPercent float64 `json:"drop_warning_percent"`
+	// 上級者向け: ethtool -S で得られるドライバ固有の統計のうち、レポートに載せるものの名前。
+	EthtoolStats []string `json:"ethtool_stats"`
+	// nftables の名前付きカウンタのうち、バイト数をレポートに載せるもの。
+	NftablesCounters []string `json:"nftables_counters"`
+	// 起動時に inet linux_traffic_checker テーブルへ名前付きカウンタとそれを数えるルールを作り、
+	// 期間ごとのバイト数をレポートに載せる。
+	NftablesRules []NftablesRule `json:"nftables_rules"`
+	// "json" または "csv" を指定すると、期間ごとの内訳をファイルとしてレポートに添付する。
+	AttachmentFormat string `json:"attachment_format"`
+	// true の場合、history_db の日ごとの通信量を棒グラフのPNGにしてレポートに添付する。
+	ReportChart bool `json:"report_chart"`
+	// インターフェースのリンクダウン・復旧を link_check_interval（既定 1m）ごとに確認して通知する。
+	NotifyOnInterfaceDown bool   `json:"notify_on_interface_down"`
+	LinkCheckInterval     string `json:"link_check_interval"`
+	// rate_sample_interval（既定 30s）ごとにカウンタを読んで通信速度を計算し、count_direction の方向の合計が
+	// rate_alert_mbps（Mbps）以上の状態が rate_alert_duration（既定 10m）続いたらアラートを送る。
+	// 超過が続いている間も rate_alert_cooldown（既定 1h）に1回までしか送らない。0 なら無効。
+	RateAlertMbps      float64 `json:"rate_alert_mbps"`
+	RateAlertDuration  string  `json:"rate_alert_duration"`
+	RateSampleInterval string  `json:"rate_sample_interval"`
+	RateAlertCooldown  string  `json:"rate_alert_cooldown"`
+	// 通知の送信先が複数ある場合に同時に送信する数（既定 4）。
+	NotifyConcurrency int `json:"notify_concurrency"`
+	// 1回のレポート処理で、全ての送信の再試行に使える時間（待ち時間と失敗した試行にかかった時間）の合計（既定 1m）。使い切ると以降は即失敗する。
+	RetryBudget string `json:"retry_budget"`
+	// レポートとダイジェストのジョブが失敗したときに再実行する回数（既定 3）と、最初の再実行までの待ち時間（既定 1m、以降は2倍ずつ延ばす）。
+	JobRetries       *int   `json:"job_retries"`
+	JobRetryInterval string `json:"job_retry_interval"`
+	// 再実行してもジョブが失敗したときにアラートを送る通知先（notifiers と同じ名前、例 ["ntfy"]）。空なら送らない。
+	JobFailureNotifiers []string `json:"job_failure_notifiers"`
+	// 通知などの外向きのHTTP通信に使うプロキシ（例 "http://proxy.example.com:3128"）。未指定なら環境変数 HTTPS_PROXY などに従う。
+	HTTPProxy string `json:"http_proxy"`
+	// システムのCAに加えて信頼するCA証明書（PEM）のファイル。
+	CABundle string `json:"ca_bundle"`
+	// TLSの最低バージョン（"1.2"（既定）、"1.3"）。
+	TLSMinVersion string `json:"tls_min_version"`
+	// 1回のHTTPリクエストの待ち時間（既定 "30s"）。
+	HTTPTimeout string `json:"http_timeout"`
+	// このファイルが作成されると、reset_trigger_interval（既定 1m）ごとの確認時に
+	// 今月のベースラインを現在のカウンタにリセットし、ファイルを削除する。
+	ResetTriggerFile     string `json:"reset_trigger_file"`
+	ResetTriggerInterval string `json:"reset_trigger_interval"`
+	// Prometheus 形式のメトリクスを公開するアドレス（例 ":9090"）。
+	// 過去の月ごとの合計は直近 metrics_history_months（既定 12）か月分だけ公開する。
+	MetricsAddr          string `json:"metrics_addr"`
+	MetricsHistoryMonths int    `json:"metrics_history_months"`
+	// 現在の通信量などをJSONで返す /status を公開するアドレス（例 ":8080"）。
+	// 通信量はリクエストのたびにカウンタを読み取り、保存済みのベースラインとの差で計算する。
+	// listen_addr は status_addr の別名。
+	StatusAddr string `json:"status_addr"`
+	ListenAddr string `json:"listen_addr"`
+	// 統計ファイルのロックを待つ時間（既定 30s）。超えた場合 lock_timeout_action が
+	// "abort"（既定）ならその回の処理を中止し、"proceed" なら警告を出してロックなしで続行する。
+	LockTimeout       string `json:"lock_timeout"`
+	LockTimeoutAction string `json:"lock_timeout_action"`
+	// "all" と interface_pattern で集計から除外するインターフェース名。"veth*" のようなパターンや、"^" で始まる正規表現も指定できる。既定は ["lo"]。
+	ExcludeInterfaces []string `json:"exclude_interfaces"`
+	// コンテナごとの通信量を集計する場合のランタイム（docker / podman）。指定すると interface の代わりに、
+	// 実行中のコンテナをAPIから探してそれぞれのネットワーク名前空間のカウンタを合計する。
+	// container_socket は API のソケット（既定 docker は /var/run/docker.sock、podman は /run/podman/podman.sock）。
+	ContainerRuntime string `json:"container_runtime"`
+	ContainerSocket  string `json:"container_socket"`
+	// 集計から除外するコンテナ名。"buildkit*" のようなパターンも指定できる。
+	ExcludeContainers []string `json:"exclude_containers"`
+	// レポートに載せる通信量の多いコンテナの数（既定 5）。
+	ContainerTop int `json:"container_top"`
+	// 通知先ごとのメッセージの最大文字数（例 {"discord": 1000, "pagerduty": 200}）。
+	// 超える場合は合計、受信・送信の順に重要な項目を残して省略する。discord は1つのメッセージの埋め込みの合計で数え、
+	// 指定しなくても Discord の上限（6000文字）に収める。
+	MaxMessageLength map[string]int `json:"max_message_length"`
+	// "scheduled"（既定）はレポートなどの予定された実行時だけカウンタを読み取り、常駐中の負荷はほぼない。
+	// "continuous" は poll_interval（既定 5m）ごとにカウンタを読み取り、上限超過の検知が早くなり、
+	// boundary_mode が "interpolate" の場合の月境界の補間も正確になる。
+	PollMode     string `json:"poll_mode"`
+	PollInterval string `json:"poll_interval"`
+	// "table" を指定すると、"all" での集計時にインターフェースごとの受信・送信・合計を表にして1つの項目に載せる。
+	ReportLayout string `json:"report_layout"`
+	// 通信量と帯域の表示の小数点以下の桁数（既定 2）と丸め方。
+	// round_mode は "nearest"（既定、四捨五入）、"up"（切り上げ）、"down"（切り捨て）。
+	DecimalPlaces *int   `json:"decimal_places"`
+	RoundMode     string `json:"round_mode"`
+	// この日時（RFC3339）になったら一度だけベースラインをリセットする。プラン変更日などに使う。
+	// 適用済みかどうかは統計ファイルに記録されるため、再起動しても二重にリセットされない。
+	NextResetAt *time.Time `json:"next_reset_at"`
+	// 指定すると /var/run/netns/<名前> のネットワーク名前空間に入ってカウンタを読み取る。root権限が必要。
+	NetnsName string `json:"netns_name"`
+	// 締めた期間の合計が直近 anomaly_periods（既定 3）期間の平均から anomaly_percent（%）以上
+	// 離れていればアラートを送る。0 なら無効。
+	AnomalyPercent float64 `json:"anomaly_percent"`
+	AnomalyPeriods int     `json:"anomaly_periods"`
+	// 指定すると、レポートのたびに最新のレポートをJSONでこのファイルに書き出す。
+	// バイト数とその表示用の文字列の両方を含むため、ダッシュボードやMOTDなどから読み取れる。
+	ReportOutputFile string `json:"report_output_file"`
+	// 監視するインターフェース名のパターン（例 "wg*"）。実行のたびに一致するインターフェースを探し直し、
+	// 新しく現れたものはその時点をベースラインとして集計に加え、消えたものはそれまでの通信量を記録する。
+	// "^" で始まる場合は正規表現（例 "^(en|wl).*"）として扱う。interface に "eth*" のようなパターンを指定した場合もここに入る。
+	InterfacePattern string `json:"interface_pattern"`
+	// interface_pattern か interface: "all" で一致したインターフェースを、"total"（既定）なら合計して1つとして、
+	// "interface" ならインターフェースごとに集計する。
+	GroupBy string `json:"group_by"`
+	// レポートのタイトルをこのURLへのリンクにする。メトリクスやダッシュボードへの導線に使う。
+	DashboardURL string `json:"dashboard_url"`
+	// 通知先（discord / slack / telegram / webhook / pagerduty / email）ごと、種類（report / alert）ごとのタイトル・色・メンションの上書き。
+	// email ではタイトルを件名と見出しに、色を見出しの色に使い、メンションは使わない。
+	MessageStyles map[string]map[string]MessageStyle `json:"message_styles"`
+	// 統計の保存先（file / redis）。redis の場合は redis_url のRedisに redis_key をキーとしてJSONで保存する。
+	// redis_key の既定値は "linux-traffic-checker:<ホスト名>:<インターフェース>"。
+	StorageBackend string `json:"storage_backend"`
+	RedisURL       string `json:"redis_url"`
+	RedisKey       string `json:"redis_key"`
+
+	// 1回の読み取りでカウンタを読む回数（既定 1）。2以上なら read_sample_interval 間隔で読み、
+	// read_sample_mode（median / max、既定 median）で1つの値にまとめる。
+	ReadSamples        int    `json:"read_samples"`
+	ReadSampleMode     string `json:"read_sample_mode"`
+	ReadSampleInterval string `json:"read_sample_interval"`
+
+	// 月間の通信量がこの値を超えたら、月に1回警告を送る。threshold は "900GB" のような表記で、
+	// threshold_bytes より優先する。alert_webhook_url を設定すると警告はそのWebhookに送る。
+	ThresholdBytes  int64  `json:"threshold_bytes"`
+	Threshold       string `json:"threshold"`
+	AlertWebhookURL string `json:"alert_webhook_url"`
+	// 契約上の月間の通信量上限（バイト）。使用量が alert_thresholds（上限に対する%、既定 [50, 80, 95]）の
+	// それぞれに初めて達したときに、期間ごとに1回ずつアラートを送る。
+	QuotaBytes      int64     `json:"quota_bytes"`
+	AlertThresholds []float64 `json:"alert_thresholds"`
+	// DiscordのアラートでメンションするロールのID。message_styles でアラートのメンションを指定した場合はそちらを使う。
+	AlertRoleID string `json:"alert_role_id"`
+	// 警告値を超えたときに期間ごとに1回だけ実行するコマンド（例 ["/usr/local/bin/throttle", "eth0"]）。
+	// シェルを介さず先頭の要素をそのまま実行し、このプロセスと同じ権限で動くため、
+	// 設定ファイルを書き換えられる人は任意のコマンドを実行できる。設定ファイルの権限に注意すること。
+	// 実行は on_quota_exceeded_timeout（既定 1m）で打ち切る。
+	OnQuotaExceeded        []string `json:"on_quota_exceeded"`
+	OnQuotaExceededTimeout string   `json:"on_quota_exceeded_timeout"`
+	// 通信量が上限（cap_basis で判定する cap_bytes などか quota_bytes）に達したときに期間ごとに1回行う処理。
+	// コマンドの実行か tc による帯域の制限で、次の期間が始まると取り消す。実行は on_quota_exceeded_timeout で打ち切る。
+	QuotaActions []QuotaAction `json:"quota_actions"`
+
+	// レポートを送るタイミング（標準のcron式、既定 "0 0 1 * *"）と、集計を区切る期間（monthly / weekly / daily、既定 monthly）。
+	// 期間が変わった後の最初の実行で前の期間を締めて報告するため、schedule は period の区切りの直後に合わせる。
+	// 例えば毎朝9時の日次レポートなら schedule を "0 9 * * *"、period を "daily" にする。
+	// period より細かい schedule にすると、期間の途中経過が送られる。
+	Schedule string `json:"schedule"`
+	Period   string `json:"period"`
+	// 月次の期間が始まる日（1〜31、既定 1）。例えば 20 なら毎月20日から翌月19日までを1か月として集計し、
+	// schedule の既定値もその日の0時になる。日数が足りない月は月末から始まる。
+	BillingDay int `json:"billing_day"`
+	// 日次・週次・月次など複数のレポートを送る場合に、cron と period の組を並べる（例 [{"cron": "0 9 * * *", "period": "daily"}]）。
+	// 指定した場合は schedule と period は使わない。2つ目以降のレポートは stats_file の末尾に ".<period>" を付けた
+	// 別の統計ファイルで集計し、上限・警告値・on_quota_exceeded・ダイジェストは1つ目のレポートだけで扱う。
+	Schedules []ReportSchedule `json:"schedules"`
+
+	// 通知先（discord / slack / telegram / webhook / collector / email / ntfy / gotify、既定 discord）。slack は slack_webhook_url の Incoming Webhook に、
+	// telegram は telegram_bot_token のボットから telegram_chat_id のチャットに、webhook は generic_webhook_url にJSONで送り、
+	// email は smtp_host からメールで、ntfy と gotify はプッシュ通知で送る。
+	Notifier         string `json:"notifier"`
+	SlackWebhookURL  string `json:"slack_webhook_url"`
+	TelegramBotToken string `json:"telegram_bot_token"`
+	TelegramChatID   string `json:"telegram_chat_id"`
+	// 同時に送る通知先の一覧（例: ["discord", "slack"]）。未指定なら notifier の1つだけ。
+	Notifiers []string `json:"notifiers"`
+	// notifier "webhook" の送信先。レポートとアラートをJSONでPOSTする。
+	GenericWebhookURL string `json:"generic_webhook_url"`
+	// notifier "collector" の送信先の集約サーバーのURLと、集約サーバーと共有するトークン。
+	// agent_name（既定はホスト名）をこのホストの名前として送る。
+	CollectorURL   string `json:"collector_url"`
+	CollectorToken string `json:"collector_token"`
+	AgentName      string `json:"agent_name"`
+	// 指定すると集約サーバーとして collector_addr でエージェントのレポートを受け取り、期間ごとにまとめて1つのメッセージで送る。
+	// collector_hosts の全ホストが揃った時点か、collector_schedule（既定 "0 1 * * *"）の時点で揃っている分を送る。
+	CollectorAddr     string   `json:"collector_addr"`
+	CollectorHosts    []string `json:"collector_hosts"`
+	CollectorSchedule string   `json:"collector_schedule"`
+
+	// notifier "email" の送信先のSMTPサーバー。smtp_tls は "starttls"（既定）、"tls"（接続から暗号化）、"none" から選び、
+	// smtp_port の既定はそれぞれ 587、465、25。smtp_username を指定するとPLAIN認証を行う。
+	SMTPHost     string   `json:"smtp_host"`
+	SMTPPort     int      `json:"smtp_port"`
+	SMTPTLS      string   `json:"smtp_tls"`
+	SMTPUsername string   `json:"smtp_username"`
+	SMTPPassword string   `json:"smtp_password"`
+	SMTPFrom     string   `json:"smtp_from"`
+	SMTPTo       []string `json:"smtp_to"`
+	// メールの件名。{title} は通知のタイトル、{interface} と {period} は report_title と同じ（既定 "{title}"）。
+	SMTPSubject string `json:"smtp_subject"`
+
+	// notifier "ntfy" の送信先。ntfy_url（既定 "https://ntfy.sh"）の ntfy_topic に送り、ntfy_token を指定するとアクセストークンで認証する。
+	// 優先度はレポートが ntfy_priority（既定 3）、アラートが ntfy_alert_priority（既定 4）で、1から5で指定する。
+	NtfyURL           string `json:"ntfy_url"`
+	NtfyTopic         string `json:"ntfy_topic"`
+	NtfyToken         string `json:"ntfy_token"`
+	NtfyPriority      int    `json:"ntfy_priority"`
+	NtfyAlertPriority int    `json:"ntfy_alert_priority"`
+	// notifier "gotify" の送信先のGotifyサーバーと、アプリケーションのトークン。
+	// 優先度はレポートが gotify_priority（既定 5）、アラートが gotify_alert_priority（既定 8）で、1から10で指定する。
+	GotifyURL           string `json:"gotify_url"`
+	GotifyToken         string `json:"gotify_token"`
+	GotifyPriority      int    `json:"gotify_priority"`
+	GotifyAlertPriority int    `json:"gotify_alert_priority"`
+
+	// 単位の表し方。"binary"（既定）は 1024 倍ごとの KiB / MiB / GiB …、"decimal" は 1000 倍ごとの KB / MB / GB …。
+	UnitMode string `json:"unit_mode"`
+	// 単位をまとめて指定する。"iec" は unit_mode "binary"、"si" は "decimal" と同じで、
+	// "GB" や "GiB" のような単位名を指定すると、値の大きさにかかわらずその単位で表示する。
+	Units string `json:"units"`
+
+	store     store.Store[Stats]
+	threshold *big.Int
+	location  *time.Location
+	// httpClient と byteFormat は apply で切り替える、この設定から作ったもの。
+	httpClient *http.Client
+	byteFormat report.ByteFormat
+	// reports は schedules ごとの設定。先頭はこの設定自身。
+	reports []*Config
+	// cycleStartOnly は既定のスケジュールが期間の始まる日以外にも発火する場合に設定する（onCycleStart を参照）。
+	cycleStartOnly bool
+	// historyReadOnly は2つ目以降のスケジュールに設定する。最初のものと同じカウンタを読むので、
+	// 通信量を二重に記録しないようにする。
+	historyReadOnly bool
+}
+
+type Stats struct {
+	Month          string                               `json:"month"`
+	RX             big.Int                              `json:"rx"`
+	TX             big.Int                              `json:"tx"`
+	History        []PeriodRecord                       `json:"history,omitempty"`
+	PendingDigest  []PeriodRecord                       `json:"pending_digest,omitempty"`
+	LinkState      string                               `json:"link_state,omitempty"`
+	LastRX         big.Int                              `json:"last_rx"`
+	LastTX         big.Int                              `json:"last_tx"`
+	LastReadAt     time.Time                            `json:"last_read_at"`
+	CapAlerts      map[string]string                    `json:"cap_alerts,omitempty"`
+	QuotaAlerts    map[string]string                    `json:"quota_alerts,omitempty"`
+	Interfaces     map[string]*collector.InterfaceStats `json:"interfaces,omitempty"`
+	LastNotified   time.Time                            `json:"last_notified"`
+	AppliedResetAt time.Time                            `json:"applied_reset_at"`
+	LastInterfaces map[string]*collector.InterfaceStats `json:"last_interfaces,omitempty"`
+	Archived       []report.ArchivedInterface           `json:"archived,omitempty"`
+	PerInterface   map[string]*Stats                    `json:"per_interface,omitempty"`
+	// AlertedThreshold は今月すでに警告値超過を通知したかどうか。月が変わると false に戻る。
+	AlertedThreshold bool `json:"alerted_threshold,omitempty"`
+	// Carried は今期の途中でカウンタがリセットされた場合に、リセット前までの通信量を引き継いだ分。
+	Carried *collector.InterfaceStats `json:"carried,omitempty"`
+	// Accumulated は poll_mode が "continuous" の場合の今期の通信量。読み取りのたびに前回からの増分を加え、
+	// 期間が変わると0に戻る。読み取りの間にカウンタがリセットされても、それまでの分は失われない。
+	Accumulated *collector.InterfaceStats `json:"accumulated,omitempty"`
+	// RanQuotaHook は今期すでに on_quota_exceeded を実行したかどうか。期間が変わると false に戻る。
+	RanQuotaHook bool `json:"ran_quota_hook,omitempty"`
+	// Throttled は quota_actions を実行した期間。次の期間が始まると取り消して空に戻る。
+	Throttled string `json:"throttled,omitempty"`
+	// UnsentReports は再試行しても送信できなかった締めた期間のレポート。次回の実行時に送り直す。
+	UnsentReports []PeriodRecord `json:"unsent_reports,omitempty"`
+	// UnsentStarts は送信できなかった期間の開始の通知。未送信のレポートより先に送り直す。
+	UnsentStarts []notify.Embed `json:"unsent_starts,omitempty"`
+	// FleetReports は集約サーバーとして受け取り、まだ送っていないエージェントのレポート。
+	FleetReports []FleetReport `json:"fleet_reports,omitempty"`
+	// PacketBaseline は今期の始めのパケット・エラー・破棄のカウンタ。show_packets か drop_warning_percent の場合のみ。
+	PacketBaseline *collector.PacketCounts `json:"packet_baseline,omitempty"`
+	// NftablesBaseline は今期の始めの nftables_rules のカウンタの値。
+	NftablesBaseline *NftablesBaseline `json:"nftables_baseline,omitempty"`
+	// AutoInterface は interface が "auto" の場合に、ベースラインを記録したデフォルトルートのインターフェース。
+	AutoInterface string `json:"auto_interface,omitempty"`
+}
+
+type PeriodRecord struct {
+	Month        string                               `json:"month"`
+	Interface    string                               `json:"interface,omitempty"`
+	RX           big.Int                              `json:"rx"`
+	TX           big.Int                              `json:"tx"`
+	TopInterface string                               `json:"top_interface,omitempty"`
+	TopBytes     *big.Int                             `json:"top_bytes,omitempty"`
+	Interfaces   map[string]*collector.InterfaceStats `json:"interfaces,omitempty"`
+	Packets      *collector.PacketCounts              `json:"packets,omitempty"`
+	Nftables     map[string]*big.Int                  `json:"nftables,omitempty"`
+}
+
+// reportSchedule は既定のスケジュールで、月ごとの期間が始まる日の0時。
+func reportSchedule(day int) string {
+	if day > 28 {
+		return "0 0 28-31 * *"
+	}
+	return fmt.Sprintf("0 0 %d * *", day)
+}
+
+var (
+	dryRun        bool
+	simulateReset bool
+	testClock     time.Duration
+)
+
+func readConfig(filename string) (*Config, error) {
+	data, err := os.ReadFile(filename)
+	// 環境変数や -set だけで設定する場合は設定ファイルがなくてもよい。
+	if errors.Is(err, fs.ErrNotExist) && hasOverrides() {
+		data, err = nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	data, err = applyOverrides(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var config Config
+	err = json.Unmarshal(data, &config)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range config.paths() {
+		*path, err = expandHome(*path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(config.Interfaces) == 1 {
+		config.Interface = config.Interfaces[0]
+		config.Interfaces = nil
+	}
+	if report.IsInterfacePattern(config.Interface) && config.InterfacePattern == "" {
+		config.InterfacePattern = config.Interface
+	}
+
+	applyDefaults(&config)
+
+	err = validateConfig(&config)
+	if err != nil {
+		return nil, err
+	}
+	setupLogger(&config)
+
+	if testClock != 0 {
+		config.StatsFile += ".test"
+		config.RedisKey += ":test"
+		if config.HistoryDB != "" {
+			config.HistoryDB += ".test"
+		}
+		config.NotifyOnInterfaceDown = false
+		config.ResetTriggerFile = ""
+	}
+
+	statsStore, err := openStore(&config)
+	if err != nil {
+		return nil, err
+	}
+	config.store = migratingStore{Store: statsStore, config: &config}
+
+	if config.TimeZone == "" {
+		config.location = time.Local
+		zone, offset := clock.Now().Zone()
+		slog.Info("timezone が未設定のため、システムのタイムゾーンを使用します", "zone", zone, "offset_seconds", offset)
+	} else {
+		config.location, err = time.LoadLocation(config.TimeZone)
+		if err != nil {
+			return nil, fmt.Errorf("timezone %q を読み込めません（例: \"Asia/Tokyo\"）: %w", config.TimeZone, err)
+		}
+	}
+
+	config.reports, err = reportConfigs(&config)
+	if err != nil {
+		return nil, err
+	}
+
+	config.httpClient, err = newHTTPClient(&config)
+	if err != nil {
+		return nil, err
+	}
+
+	config.byteFormat = report.ByteFormat{
+		Threshold:     config.UnitThreshold,
+		DecimalPlaces: *config.DecimalPlaces,
+		RoundMode:     config.RoundMode,
+		UnitMode:      config.UnitMode,
+		Unit:          fixedUnit(config.Units),
+	}
+
+	return &config, nil
+}
+
+// apply は、この設定で動作を始めるときに、HTTPクライアントや締め日、言語、単位を
+// この設定のものに切り替える。readConfig では切り替えないので、再読み込みで新しい
+// 設定を使えなかった場合も、動作中の設定のまま残る。
+func (config *Config) apply() {
+	httpClient = config.httpClient
+	billingDay = config.BillingDay
+	language = config.Language
+	byteFormat = config.byteFormat
+}
+
+func applyDefaults(config *Config) {
+	if config.Language == "" {
+		config.Language = "ja"
+	}
+	if config.LogFormat == "" {
+		config.LogFormat = "text"
+	}
+	if config.QuotaBytes > 0 && len(config.AlertThresholds) == 0 {
+		config.AlertThresholds = defaultAlertThresholds
+	}
+	if config.OnQuotaExceededTimeout == "" {
+		config.OnQuotaExceededTimeout = "1m"
+	}
+	for i := range config.QuotaActions {
+		if config.QuotaActions[i].Type == "tc" && config.QuotaActions[i].Direction == "" {
+			config.QuotaActions[i].Direction = "tx"
+		}
+	}
+	if config.LogLevel == "" {
+		config.LogLevel = "info"
+	}
+	if config.LinkCheckInterval == "" {
+		config.LinkCheckInterval = "1m"
+	}
+	if config.RateAlertDuration == "" {
+		config.RateAlertDuration = "10m"
+	}
+	if config.RateSampleInterval == "" {
+		config.RateSampleInterval = "30s"
+	}
+	if config.RateAlertCooldown == "" {
+		config.RateAlertCooldown = "1h"
+	}
+	if config.BoundaryMode == "" {
+		config.BoundaryMode = "job"
+	}
+	if config.UnitThreshold == 0 {
+		config.UnitThreshold = 1
+	}
+	if config.CountDirection == "" {
+		config.CountDirection = "both"
+	}
+	if config.StorageBackend == "" {
+		config.StorageBackend = "file"
+	}
+	if config.RedisKey == "" {
+		host, err := os.Hostname()
+		if err != nil {
+			host = "localhost"
+		}
+		config.RedisKey = fmt.Sprintf("linux-traffic-checker:%s:%s", host, config.Interface)
+	}
+	if config.NotifyConcurrency == 0 {
+		config.NotifyConcurrency = 4
+	}
+	if config.RetryBudget == "" {
+		config.RetryBudget = "1m"
+	}
+	if config.JobRetries == nil {
+		retries := 3
+		config.JobRetries = &retries
+	}
+	if config.JobRetryInterval == "" {
+		config.JobRetryInterval = "1m"
+	}
+	if config.TLSMinVersion == "" {
+		config.TLSMinVersion = "1.2"
+	}
+	if config.HTTPTimeout == "" {
+		config.HTTPTimeout = "30s"
+	}
+	if config.ResetTriggerInterval == "" {
+		config.ResetTriggerInterval = "1m"
+	}
+	if config.TimeseriesMeasurement == "" {
+		config.TimeseriesMeasurement = "linux_traffic"
+	}
+	if config.AgentName == "" {
+		host, err := os.Hostname()
+		if err != nil {
+			host = "localhost"
+		}
+		config.AgentName = host
+	}
+	if config.CollectorSchedule == "" {
+		config.CollectorSchedule = "0 1 * * *"
+	}
+	if config.SMTPTLS == "" {
+		config.SMTPTLS = "starttls"
+	}
+	if config.SMTPPort == 0 {
+		config.SMTPPort = map[string]int{"starttls": 587, "tls": 465}[config.SMTPTLS]
+		if config.SMTPPort == 0 {
+			config.SMTPPort = 25
+		}
+	}
+	if config.SMTPSubject == "" {
+		config.SMTPSubject = "{title}"
+	}
+	if config.NtfyURL == "" {
+		config.NtfyURL = "https://ntfy.sh"
+	}
+	if config.NtfyPriority == 0 {
+		config.NtfyPriority = 3
+	}
+	if config.NtfyAlertPriority == 0 {
+		config.NtfyAlertPriority = 4
+	}
+	if config.GotifyPriority == 0 {
+		config.GotifyPriority = 5
+	}
+	if config.GotifyAlertPriority == 0 {
+		config.GotifyAlertPriority = 8
+	}
+	if config.MQTTTopicPrefix == "" {
+		config.MQTTTopicPrefix = "linux-traffic-checker"
+	}
+	if config.MQTTDiscoveryPrefix == "" {
+		config.MQTTDiscoveryPrefix = "homeassistant"
+	}
+	if config.MQTTInterval == "" {
+		config.MQTTInterval = "1m"
+	}
+	if config.HistoryLimit == 0 {
+		config.HistoryLimit = 24
+	}
+	if config.MetricsHistoryMonths == 0 {
+		config.MetricsHistoryMonths = 12
+	}
+	if config.ExcludeInterfaces == nil {
+		config.ExcludeInterfaces = []string{"lo"}
+	}
+	if config.GroupBy == "" {
+		config.GroupBy = "total"
+	}
+	if config.SNMPVersion == "" {
+		config.SNMPVersion = "2c"
+	}
+	if config.SNMPCommunity == "" {
+		config.SNMPCommunity = "public"
+	}
+	if config.SNMPTimeout == "" {
+		config.SNMPTimeout = "5s"
+	}
+	if config.SNMPHost != "" && config.Interface == "" && config.SNMPIfIndex > 0 {
+		config.Interface = fmt.Sprintf("ifIndex %d", config.SNMPIfIndex)
+	}
+	if config.ContainerRuntime != "" && config.ContainerSocket == "" {
+		config.ContainerSocket = containerSockets[config.ContainerRuntime]
+	}
+	if config.ContainerTop == 0 {
+		config.ContainerTop = 5
+	}
+	if config.PollMode == "" {
+		config.PollMode = "scheduled"
+	}
+	if config.PollInterval == "" {
+		config.PollInterval = "5m"
+	}
+	if config.DecimalPlaces == nil {
+		places := 2
+		config.DecimalPlaces = &places
+	}
+	if config.RoundMode == "" {
+		config.RoundMode = "nearest"
+	}
+	if config.UnitMode == "" {
+		config.UnitMode = unitsMode(config.Units)
+	}
+	if config.UnitMode == "" {
+		config.UnitMode = "binary"
+	}
+	if config.Notifier == "" {
+		config.Notifier = "discord"
+	}
+	if config.StatusAddr == "" {
+		config.StatusAddr = config.ListenAddr
+	}
+	if len(config.Notifiers) == 0 {
+		config.Notifiers = []string{config.Notifier}
+	}
+	if config.BillingDay == 0 {
+		config.BillingDay = 1
+	}
+	applyScheduleDefaults(config)
+	if config.Schedule == "" {
+		config.Schedule = reportSchedule(config.BillingDay)
+		config.cycleStartOnly = config.BillingDay > 28
+	}
+	if config.Period == "" {
+		config.Period = "monthly"
+	}
+	if config.ReadSamples == 0 {
+		config.ReadSamples = 1
+	}
+	if config.ReadSampleMode == "" {
+		config.ReadSampleMode = "median"
+	}
+	if config.ReadSampleInterval == "" {
+		config.ReadSampleInterval = "50ms"
+	}
+	if config.AnomalyPeriods == 0 {
+		config.AnomalyPeriods = 3
+	}
+	if config.LockTimeout == "" {
+		config.LockTimeout = "30s"
+	}
+	if config.LockTimeoutAction == "" {
+		config.LockTimeoutAction = "abort"
+	}
+}
+
+func redactedConfig(config *Config) Config {
+	redacted := *config
+	for _, secret := range []*string{&redacted.WebhookURL, &redacted.AlertWebhookURL, &redacted.PagerDutyRoutingKey, &redacted.RedisURL, &redacted.SlackWebhookURL, &redacted.TelegramBotToken, &redacted.GenericWebhookURL, &redacted.TimeseriesToken, &redacted.MQTTPassword, &redacted.CollectorToken, &redacted.SNMPCommunity, &redacted.SNMPAuthPassword, &redacted.SNMPPrivPassword, &redacted.SMTPPassword, &redacted.NtfyToken, &redacted.GotifyToken} {
+		if *secret != "" {
+			*secret = "<redacted>"
+		}
+	}
+	// プロキシのURLは、認証情報のパスワードだけを伏せる。
+	if proxy, err := url.Parse(config.HTTPProxy); err == nil && proxy.User != nil {
+		redacted.HTTPProxy = proxy.Redacted()
+	}
+	redacted.WebhookURLs = nil
+	for range config.WebhookURLs {
+		redacted.WebhookURLs = append(redacted.WebhookURLs, "<redacted>")
+	}
+	redacted.DiscordWebhooks = slices.Clone(config.DiscordWebhooks)
+	for i := range redacted.DiscordWebhooks {
+		redacted.DiscordWebhooks[i].URL = "<redacted>"
+	}
+	return redacted
+}
+
+// now は設定したタイムゾーンで今の時刻を返す。同じタイムゾーンのスケジューラーが発火したときに期間のキーが変わる。
+func (config *Config) now() time.Time {
+	if config.location == nil {
+		return clock.Now()
+	}
+	return clock.Now().In(config.location)
+}
+
+func (config *Config) paths() []*string {
+	return []*string{&config.StatsFile, &config.ResetTriggerFile, &config.ReportOutputFile, &config.HistoryDB, &config.ContainerSocket, &config.CABundle}
+}
+
+// userHomeDir は設定したパスの "~" を解決する。HOME がない場合を確かめられるよう、差し替えられる変数にしている。
+var userHomeDir = os.UserHomeDir
+
+func expandHome(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+
+	homeDir, err := userHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("%s の ~ を展開できません（HOME が未設定の場合は絶対パスを指定してください）: %w", path, err)
+	}
+	if homeDir == "" {
+		return "", fmt.Errorf("%s の ~ を展開できません: ホームディレクトリが空です", path)
+	}
+	return filepath.Join(homeDir, path[1:]), nil
+}
+
+func validateConfig(config *Config) error {
+	switch config.LogFormat {
+	case "text", "json", "journald":
+	default:
+		return fmt.Errorf("log_format は text, json, journald のいずれかを指定してください: %q", config.LogFormat)
+	}
+	if _, ok := logLevels[config.LogLevel]; !ok {
+		return fmt.Errorf("log_level は debug, info, warn, error のいずれかを指定してください: %q", config.LogLevel)
+	}
+	if config.UnitThreshold < 1 {
+		return fmt.Errorf("unit_threshold は1以上を指定してください: %v", config.UnitThreshold)
+	}
+
+	switch config.CountDirection {
+	case "both", "rx", "tx":
+	default:
+		return fmt.Errorf("count_direction は both, rx, tx のいずれかを指定してください: %q", config.CountDirection)
+	}
+	if config.HistoryLimit < 1 {
+		return fmt.Errorf("history_limit は1以上を指定してください: %d", config.HistoryLimit)
+	}
+	if config.HistoryMonths < 0 {
+		return fmt.Errorf("history_months は0以上を指定してください: %d", config.HistoryMonths)
+	}
+	if config.HistoryMonths > 0 && config.HistoryDB == "" {
+		return fmt.Errorf("history_months を使うには history_db を指定してください")
+	}
+	if config.ReadSamples < 1 {
+		return fmt.Errorf("read_samples は1以上を指定してください: %d", config.ReadSamples)
+	}
+	switch config.ReadSampleMode {
+	case "median", "max":
+	default:
+		return fmt.Errorf("read_sample_mode は median, max のいずれかを指定してください: %q", config.ReadSampleMode)
+	}
+	if _, err := time.ParseDuration(config.ReadSampleInterval); err != nil {
+		return fmt.Errorf("read_sample_interval の形式が正しくありません: %w", err)
+	}
+	switch config.StorageBackend {
+	case "file":
+	case "redis":
+		if config.RedisURL == "" {
+			return fmt.Errorf("storage_backend が redis の場合は redis_url を指定してください")
+		}
+	default:
+		return fmt.Errorf("storage_backend は file, redis のいずれかを指定してください: %q", config.StorageBackend)
+	}
+	if err := validateSchedules(config); err != nil {
+		return err
+	}
+	if config.ContainerRuntime != "" {
+		if _, ok := containerSockets[config.ContainerRuntime]; !ok {
+			return fmt.Errorf("container_runtime は docker, podman のいずれかを指定してください: %q", config.ContainerRuntime)
+		}
+		if len(config.Interfaces) > 0 || config.InterfacePattern != "" || config.NetnsName != "" || config.CounterCommand != "" {
+			return fmt.Errorf("container_runtime は interfaces, interface_pattern, netns_name, counter_command と同時に指定できません")
+		}
+	}
+	if config.ContainerTop < 1 {
+		return fmt.Errorf("container_top は1以上を指定してください: %d", config.ContainerTop)
+	}
+	if config.RateAlertMbps < 0 {
+		return fmt.Errorf("rate_alert_mbps は0以上を指定してください: %v", config.RateAlertMbps)
+	}
+	for _, option := range []struct{ name, value string }{
+		{"rate_alert_duration", config.RateAlertDuration},
+		{"rate_sample_interval", config.RateSampleInterval},
+		{"rate_alert_cooldown", config.RateAlertCooldown},
+		{"mqtt_interval", config.MQTTInterval},
+	} {
+		if d, err := time.ParseDuration(option.value); err != nil || d <= 0 {
+			return fmt.Errorf("%s が不正です: %q", option.name, option.value)
+		}
+	}
+	if config.BillingDay < 1 || config.BillingDay > 31 {
+		return fmt.Errorf("billing_day は1から31の間で指定してください: %d", config.BillingDay)
+	}
+	if _, err := cron.ParseStandard(config.Schedule); err != nil {
+		return fmt.Errorf("schedule のcron式が正しくありません（%q）: %w", config.Schedule, err)
+	}
+	switch config.UnitMode {
+	case "binary", "decimal":
+	default:
+		return fmt.Errorf("unit_mode は binary, decimal のいずれかを指定してください: %q", config.UnitMode)
+	}
+	if config.Units != "" {
+		switch strings.ToLower(config.Units) {
+		case "iec", "si":
+		default:
+			if _, _, err := report.ParseUnit(config.Units); err != nil {
+				return fmt.Errorf("units は iec, si または単位名（例: \"GB\"、\"GiB\"）を指定してください: %q", config.Units)
+			}
+		}
+		if mode := unitsMode(config.Units); mode != "" && mode != config.UnitMode {
+			return fmt.Errorf("units（%q）と unit_mode（%q）が矛盾しています", config.Units, config.UnitMode)
+		}
+	}
+	for _, name := range slices.Concat([]string{config.Notifier}, config.Notifiers, config.JobFailureNotifiers) {
+		switch name {
+		case "discord", "slack", "telegram", "webhook", "collector", "email", "ntfy", "gotify":
+		default:
+			return fmt.Errorf("notifier は discord, slack, telegram, webhook, collector, email, ntfy, gotify のいずれかを指定してください: %q", name)
+		}
+	}
+	if *config.JobRetries < 0 {
+		return fmt.Errorf("job_retries は0以上を指定してください: %d", *config.JobRetries)
+	}
+	if interval, err := time.ParseDuration(config.JobRetryInterval); err != nil || interval <= 0 {
+		return fmt.Errorf("job_retry_interval が不正です: %q", config.JobRetryInterval)
+	}
+	if err := validateEmail(config); err != nil {
+		return err
+	}
+	if err := validatePush(config); err != nil {
+		return err
+	}
+	if err := validateHTTPClient(config); err != nil {
+		return err
+	}
+	if config.usesNotifier("collector") && (config.CollectorURL == "" || config.CollectorToken == "") {
+		return fmt.Errorf("notifier collector を使うには collector_url と collector_token を指定してください")
+	}
+	if config.CollectorAddr != "" {
+		if config.CollectorToken == "" {
+			return fmt.Errorf("collector_addr を使うには collector_token を指定してください")
+		}
+		if _, err := cron.ParseStandard(config.CollectorSchedule); err != nil {
+			return fmt.Errorf("collector_schedule のcron式が正しくありません（%q）: %w", config.CollectorSchedule, err)
+		}
+	}
+	if len(slices.Compact(slices.Sorted(slices.Values(config.Notifiers)))) != len(config.Notifiers) {
+		return fmt.Errorf("notifiers に同じ通知先が重複しています: %v", config.Notifiers)
+	}
+	switch config.Period {
+	case "monthly", "weekly", "daily":
+	default:
+		return fmt.Errorf("period は monthly, weekly, daily のいずれかを指定してください: %q", config.Period)
+	}
+	if config.ListenAddr != "" && config.ListenAddr != config.StatusAddr {
+		return fmt.Errorf("listen_addr は status_addr の別名です。どちらか一方だけを指定してください: %q, %q", config.ListenAddr, config.StatusAddr)
+	}
+	if config.StatusAddr != "" && config.StatusAddr == config.MetricsAddr {
+		return fmt.Errorf("status_addr と metrics_addr には異なるアドレスを指定してください: %q", config.StatusAddr)
+	}
+	if err := validateInterfaces(config); err != nil {
+		return err
+	}
+	if config.Threshold != "" {
+		threshold, err := report.ParseBytes(config.Threshold, config.UnitMode)
+		if err != nil {
+			return fmt.Errorf("threshold の形式が正しくありません: %w", err)
+		}
+		config.threshold = threshold
+	} else if config.ThresholdBytes > 0 {
+		config.threshold = big.NewInt(config.ThresholdBytes)
+	}
+	if len(config.AlertThresholds) > 0 && config.QuotaBytes <= 0 {
+		return fmt.Errorf("alert_thresholds を使う場合は quota_bytes を指定してください")
+	}
+	for _, threshold := range config.AlertThresholds {
+		if threshold <= 0 {
+			return fmt.Errorf("alert_thresholds には0より大きい割合（%%）を指定してください: %v", threshold)
+		}
+	}
+	if config.AlertRoleID != "" && strings.Trim(config.AlertRoleID, "0123456789") != "" {
+		return fmt.Errorf("alert_role_id にはロールのID（数字）を指定してください: %q", config.AlertRoleID)
+	}
+	if len(config.OnQuotaExceeded) > 0 {
+		if config.threshold == nil {
+			return fmt.Errorf("on_quota_exceeded を使う場合は threshold か threshold_bytes を指定してください")
+		}
+		if config.OnQuotaExceeded[0] == "" {
+			return fmt.Errorf("on_quota_exceeded の先頭には実行するコマンドを指定してください")
+		}
+		if timeout, err := time.ParseDuration(config.OnQuotaExceededTimeout); err != nil || timeout <= 0 {
+			return fmt.Errorf("on_quota_exceeded_timeout が不正です: %q", config.OnQuotaExceededTimeout)
+		}
+	}
+	if err := validateQuotaActions(config); err != nil {
+		return err
+	}
+	switch config.CapBasis {
+	case "", "combined", "rx", "tx", "both":
+	default:
+		return fmt.Errorf("cap_basis は combined, rx, tx, both のいずれかを指定してください: %q", config.CapBasis)
+	}
+	if config.DropWarningPercent < 0 || config.DropWarningPercent > 100 {
+		return fmt.Errorf("drop_warning_percent は0から100の範囲で指定してください: %v", config.DropWarningPercent)
+	}
+	if err := validatePricing(config.Pricing); err != nil {
+		return err
+	}
+	if err := validateMessages(config); err != nil {
+		return err
+	}
+	if config.ReportChart && config.HistoryDB == "" {
+		return fmt.Errorf("report_chart には history_db の設定が必要です")
+	}
+	if err := validateNftablesRules(config.NftablesRules); err != nil {
+		return err
+	}
+	if err := validateSNMP(config); err != nil {
+		return err
+	}
+	if config.CounterBits != 0 && config.CounterBits != 32 && config.CounterBits != 64 {
+		return fmt.Errorf("counter_bits は 32 か 64 を指定してください: %d", config.CounterBits)
+	}
+	if config.packetStats() && (config.CounterCommand != "" || config.ContainerRuntime != "") {
+		return fmt.Errorf("show_packets と drop_warning_percent は counter_command, container_runtime と併用できません")
+	}
+	if *config.DecimalPlaces < 0 {
+		return fmt.Errorf("decimal_places は0以上を指定してください: %d", *config.DecimalPlaces)
+	}
+	switch config.RoundMode {
+	case "nearest", "up", "down":
+	default:
+		return fmt.Errorf("round_mode は nearest, up, down のいずれかを指定してください: %q", config.RoundMode)
+	}
+	switch config.PollMode {
+	case "scheduled", "continuous":
+	default:
+		return fmt.Errorf("poll_mode は scheduled, continuous のいずれかを指定してください: %q", config.PollMode)
+	}
+
+	if err := validateDiscordWebhooks(config); err != nil {
+		return err
+	}
+	if config.WebhookRequired {
+		if err := requireNotifiers(config); err != nil {
+			return err
+		}
+	}
+	webhookURLs := config.webhookURLs()
+	if len(webhookURLs) == 0 && len(config.DiscordWebhooks) == 0 {
+		return nil
+	}
+
+	for _, webhookURL := range webhookURLs {
+		u, err := url.Parse(webhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("discord_webhook_url が不正です: %q", webhookURL)
+		}
+	}
+
+	return nil
+}
+
+// usesNotifier は name が notifiers か job_failure_notifiers にあり、その設定が必要かどうかを返す。
+func (config *Config) usesNotifier(name string) bool {
+	return slices.Contains(config.Notifiers, name) || slices.Contains(config.JobFailureNotifiers, name)
+}
+
+// missingNotifierSetting は通知先 name に足りない設定の名前を返す。揃っていれば空文字列を返す。
+func missingNotifierSetting(config *Config, name string) string {
+	switch name {
+	case "slack":
+		if config.SlackWebhookURL == "" {
+			return "slack_webhook_url"
+		}
+	case "telegram":
+		if config.TelegramBotToken == "" || config.TelegramChatID == "" {
+			return "telegram_bot_token と telegram_chat_id"
+		}
+	case "webhook":
+		if config.GenericWebhookURL == "" {
+			return "generic_webhook_url"
+		}
+	case "collector":
+		if config.CollectorURL == "" || config.CollectorToken == "" {
+			return "collector_url と collector_token"
+		}
+	case "email":
+		if config.SMTPHost == "" || config.SMTPFrom == "" || len(config.SMTPTo) == 0 {
+			return "smtp_host・smtp_from・smtp_to"
+		}
+	case "ntfy":
+		if config.NtfyTopic == "" {
+			return "ntfy_topic"
+		}
+	case "gotify":
+		if config.GotifyURL == "" || config.GotifyToken == "" {
+			return "gotify_url と gotify_token"
+		}
+	default:
+		if len(config.webhookURLs()) == 0 && len(config.DiscordWebhooks) == 0 {
+			return "discord_webhook_url"
+		}
+	}
+	return ""
+}
+
+// requireNotifiers は webhook_required の確認。discord を使う場合は Discord の Webhook URL を必須とし、
+// それ以外は notifiers の少なくとも1つに送れればよい。
+func requireNotifiers(config *Config) error {
+	if slices.Contains(config.Notifiers, "discord") {
+		if setting := missingNotifierSetting(config, "discord"); setting != "" {
+			return fmt.Errorf("notifiers に discord がありますが、%s が設定されていません", setting)
+		}
+		return nil
+	}
+	var missing []string
+	for _, name := range config.Notifiers {
+		setting := missingNotifierSetting(config, name)
+		if setting == "" {
+			return nil
+		}
+		missing = append(missing, fmt.Sprintf("%s の %s", name, setting))
+	}
+	return fmt.Errorf("設定済みの通知先がありません（%s が設定されていません）", strings.Join(missing, "、"))
+}
+
+// webhookURLs は discord_webhook_url と、それに続く discord_webhook_urls を空と重複を除いて返す。
+func (config *Config) webhookURLs() []string {
+	var urls []string
+	for _, webhookURL := range append([]string{config.WebhookURL}, config.WebhookURLs...) {
+		if webhookURL != "" && !slices.Contains(urls, webhookURL) {
+			urls = append(urls, webhookURL)
+		}
+	}
+	return urls
+}
+
+func readCountersOnce(config *Config) (big.Int, big.Int, map[string]*collector.InterfaceStats, error) {
+	if config.CounterCommand != "" {
+		rx, tx, err := readCommandCounters(config.CounterCommand, config.CounterRegex)
+		return rx, tx, nil, err
+	}
+	if config.SNMPHost != "" {
+		rx, tx, err := readSNMPCounters(config)
+		return rx, tx, nil, err
+	}
+	config, err := resolveInterface(config)
+	if err != nil {
+		return big.Int{}, big.Int{}, nil, err
+	}
+	if config.NetnsName != "" {
+		data, err := readNetDevInNetns(config.NetnsName)
+		if err != nil {
+			return big.Int{}, big.Int{}, nil, err
+		}
+		if config.aggregate() {
+			counters, err := filterNetDev(string(data), config)
+			if err != nil {
+				return big.Int{}, big.Int{}, nil, err
+			}
+			rx, tx := report.SumCounters(counters)
+			return rx, tx, counters, nil
+		}
+		rx, tx, err := collector.FindInterface(string(data), config.Interface)
+		return rx, tx, nil, err
+	}
+	if config.ContainerRuntime != "" {
+		counters, err := readContainerCounters(config)
+		if err != nil {
+			return big.Int{}, big.Int{}, nil, err
+		}
+		rx, tx := report.SumCounters(counters)
+		return rx, tx, counters, nil
+	}
+	if config.aggregate() {
+		counters, err := readAllNetworkBytes(config)
+		if err != nil {
+			return big.Int{}, big.Int{}, nil, err
+		}
+		rx, tx := report.SumCounters(counters)
+		return rx, tx, counters, nil
+	}
+	rx, tx, err := collector.Default.Interface(config.Interface)
+	return rx, tx, nil, err
+}
+
+const stdioStatsFile = "-"
+
+var (
+	stdinStats     []byte
+	stdinStatsOnce sync.Once
+	stdinStatsErr  error
+	stdoutStats    []byte
+)
+
+func readStdinStats() ([]byte, error) {
+	stdinStatsOnce.Do(func() {
+		stdinStats, stdinStatsErr = io.ReadAll(os.Stdin)
+	})
+	if stdoutStats != nil {
+		return stdoutStats, nil
+	}
+	return stdinStats, stdinStatsErr
+}
+
+func loadStats(statsFile string) (*Stats, bool, error) {
+	if statsFile != stdioStatsFile {
+		return store.Load[Stats](statsFile)
+	}
+
+	data, err := readStdinStats()
+	if err != nil {
+		return nil, false, err
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return &Stats{}, true, nil
+	}
+	var stats Stats
+	err = json.Unmarshal(data, &stats)
+	if err != nil {
+		return nil, false, err
+	}
+	return &stats, false, nil
+}
+
+func saveStats(statsFile string, stats *Stats) error {
+	data, err := json.Marshal(stats)
+	if err != nil {
+		return err
+	}
+
+	if dryRun {
+		var old []byte
+		if statsFile == stdioStatsFile {
+			old, err = readStdinStats()
+		} else {
+			old, err = os.ReadFile(statsFile)
+		}
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return printStatsDiff(os.Stdout, statsFile, old, data)
+	}
+
+	if statsFile == stdioStatsFile {
+		stdoutStats = data
+		return nil
+	}
+
+	return store.Save(statsFile, data)
+}
+
+func printStatsDiff(w io.Writer, statsFile string, old, new []byte) error {
+	oldFields := map[string]json.RawMessage{}
+	if len(old) > 0 {
+		if err := json.Unmarshal(old, &oldFields); err != nil {
+			return err
+		}
+	}
+	newFields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(new, &newFields); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(oldFields)+len(newFields))
+	for key := range oldFields {
+		keys = append(keys, key)
+	}
+	for key := range newFields {
+		if _, ok := oldFields[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	fmt.Fprintf(w, "[dry-run] %s に書き込まれる内容:\n", statsFile)
+	changed := false
+	for _, key := range keys {
+		oldValue, newValue := string(oldFields[key]), string(newFields[key])
+		if oldValue == newValue {
+			continue
+		}
+		changed = true
+		if oldValue == "" {
+			oldValue = "(なし)"
+		}
+		if newValue == "" {
+			newValue = "(なし)"
+		}
+		fmt.Fprintf(w, "  %s: %s -> %s\n", key, oldValue, newValue)
+	}
+	if !changed {
+		fmt.Fprintln(w, "  変更なし")
+	}
+
+	return nil
+}
+
+var defaultFieldLabels = map[string]string{
+	"rx":    "受信",
+	"tx":    "送信",
+	"total": "合計",
+}
+
+func fieldLabel(config *Config, key string) string {
+	if label, ok := config.FieldLabels[key]; ok && label != "" {
+		return expandMessage("field_labels."+key, label, messageData{Interface: interfaceDisplayName(config)})
+	}
+	return tr(defaultFieldLabels[key])
+}
+
+func embedColor(config *Config) int {
+	if config.EmbedColor != nil {
+		return int(*config.EmbedColor)
+	}
+	return 0x00bfff
+}
+
+func buildEmbed(config *Config, month, rx, tx, total string) notify.Embed {
+	return notify.Embed{
+		Title:     formatReportTitle(config, interfaceDisplayName(config), month),
+		Color:     embedColor(config),
+		Timestamp: clock.Now().UTC().Format(time.RFC3339),
+		Fields: []notify.EmbedField{
+			{Name: fieldLabel(config, "rx"), Value: rx, Inline: true},
+			{Name: fieldLabel(config, "tx"), Value: tx, Inline: true},
+			{Name: fieldLabel(config, "total"), Value: total, Inline: false},
+		},
+		Interface: embedInterface(config),
+	}
+}
+
+func sendToDiscord(config *Config, kind notify.Kind, embeds []notify.Embed, files ...notify.Attachment) error {
+	destinations := config.discordDestinations()
+	if len(destinations) == 0 {
+		slog.Warn("Webhook URLが未設定のためDiscord通知をスキップします")
+		return nil
+	}
+	style := messageStyle(config, "discord", kind)
+	// 長さはタイトルを整えた後のメッセージ全体で数える。
+	embeds = limitDiscordEmbeds(config, styleEmbeds(style, embeds))
+	if dryRun {
+		for _, embed := range embeds {
+			slog.Info("[dry-run] Discordへの送信をスキップします", "title", embed.Title, "attachments", len(files))
+		}
+		return nil
+	}
+
+	var sends []func() error
+	for _, destination := range destinations {
+		filtered := destination.filterEmbeds(config, kind, embeds)
+		if len(filtered) == 0 {
+			continue
+		}
+		payload := notify.DiscordPayload{
+			Username: config.BotName,
+			Content:  style.Mention,
+			Embeds:   filtered,
+		}
+		if destination.BotName != "" {
+			payload.Username = destination.BotName
+		}
+		contentType, body, err := payload.Encode(files)
+		if err != nil {
+			return err
+		}
+		sends = append(sends, func() error { return postToDiscord(destination.URL, contentType, body) })
+	}
+	switch len(sends) {
+	case 0:
+		slog.Debug("送信先のDiscord Webhookがないため通知をスキップします", "kind", kind)
+		return nil
+	case 1:
+		return sends[0]()
+	}
+
+	errs := fanOut(config.NotifyConcurrency, sends)
+	if !slices.Contains(errs, nil) {
+		return errors.Join(errs...)
+	}
+	for i, err := range errs {
+		if err != nil {
+			slog.Warn("一部のDiscord Webhookへの送信に失敗しました", "webhook", i+1, "of", len(sends), "error", err)
+		}
+	}
+	return nil
+}
+
+// postToDiscord は Webhook に1回リクエストを送る。応答しない送信先が他を止めないよう、httpClient が http_timeout で打ち切る。
+func postToDiscord(webhookURL, contentType string, body []byte) error {
+	if err := notify.PostDiscord(httpClient, webhookURL, contentType, body); err != nil {
+		discordSendsFailed.Add(1)
+		return err
+	}
+	discordSendsSucceeded.Add(1)
+	return nil
+}
+
+func periodElapsed(key string, now time.Time) time.Duration {
+	start, end, err := periodBounds(key, now.Location())
+	if err != nil {
+		return 0
+	}
+	if now.Before(end) {
+		end = now
+	}
+	return end.Sub(start)
+}
+
+func billingUnits(total *big.Int, unitBytes int64) *big.Int {
+	unit := big.NewInt(unitBytes)
+	units := new(big.Int).Add(total, unit)
+	units.Sub(units, big.NewInt(1))
+	return units.Quo(units, unit)
+}
+
+func startEmbed(config *Config, rx, tx *big.Int) notify.Embed {
+	return notify.Embed{
+		Title:     fmt.Sprintf(tr("%s の監視を開始しました"), interfaceDisplayName(config)),
+		Color:     0x00bfff,
+		Timestamp: clock.Now().UTC().Format(time.RFC3339),
+		Fields: []notify.EmbedField{
+			{Name: fmt.Sprintf(tr("ベースライン（%s）"), fieldLabel(config, "rx")), Value: formatBytes(rx), Inline: true},
+			{Name: fmt.Sprintf(tr("ベースライン（%s）"), fieldLabel(config, "tx")), Value: formatBytes(tx), Inline: true},
+			{Name: tr("備考"), Value: tr("今後のレポートはこの時点からの通信量を集計します"), Inline: false},
+		},
+	}
+}
+
+func countedTotal(config *Config, rx, tx *big.Int) *big.Int {
+	switch config.CountDirection {
+	case "rx":
+		return new(big.Int).Set(rx)
+	case "tx":
+		return new(big.Int).Set(tx)
+	default:
+		return new(big.Int).Add(rx, tx)
+	}
+}
+
+// recordEmbed は record のレポートの埋め込みを組み立てる。previous はその前の期間の記録で、比べるものがなければ nil。
+func recordEmbed(config *Config, record, previous *PeriodRecord) notify.Embed {
+	total := countedTotal(config, &record.RX, &record.TX)
+	embed := buildEmbed(
+		config,
+		periodLabel(record.Month),
+		formatBytes(&record.RX),
+		formatBytes(&record.TX),
+		formatBytes(total),
+	)
+	shown := map[string]bool{"rx": true, "tx": true, "total": true}
+	switch config.CountDirection {
+	case "rx":
+		shown["tx"] = false
+	case "tx":
+		shown["rx"] = false
+	}
+	var hidden []notify.EmbedField
+	for _, check := range capChecks(config, &record.RX, &record.TX) {
+		if check.limit <= 0 {
+			continue
+		}
+		i := map[string]int{"rx": 0, "tx": 1, "total": 2}[check.key]
+		// 合計欄は count_direction 次第で cap_bytes の判定値（受信＋送信）と一致しないため、その場合も別フィールドにする。
+		if shown[check.key] && (check.key != "total" || check.used.Cmp(total) == 0) {
+			embed.Fields[i].Value = capUsage(check.used, check.limit)
+			continue
+		}
+		hidden = append(hidden, notify.EmbedField{Name: fmt.Sprintf(tr("%s（上限）"), check.label), Value: capUsage(check.used, check.limit), Inline: false})
+	}
+	switch config.CountDirection {
+	case "rx":
+		embed.Fields = []notify.EmbedField{embed.Fields[0], embed.Fields[2]}
+	case "tx":
+		embed.Fields = []notify.EmbedField{embed.Fields[1], embed.Fields[2]}
+	}
+	embed.Fields = append(embed.Fields, hidden...)
+	embed.URL = config.DashboardURL
+	if config.TitleIncludeTotal {
+		embed.Title += tr(" — 計 ") + formatBytes(total)
+	}
+
+	if config.ReportLayout == "table" && len(record.Interfaces) > 0 {
+		embed.Fields = append(embed.Fields, notify.EmbedField{
+			Name:   tr("インターフェース別"),
+			Value:  usageTable(config, record.Interfaces),
+			Inline: false,
+		})
+	}
+
+	if config.ContainerRuntime != "" && len(record.Interfaces) > 0 {
+		embed.Fields = append(embed.Fields, topUsageField(config, tr("コンテナ別"), record.Interfaces, config.ContainerTop))
+	} else if record.TopInterface != "" {
+		embed.Fields = append(embed.Fields, notify.EmbedField{
+			Name:   tr("最多"),
+			Value:  fmt.Sprintf("%s (%s)", record.TopInterface, formatBytes(record.TopBytes)),
+			Inline: false,
+		})
+	}
+
+	if record.Packets != nil {
+		embed.Fields = append(embed.Fields, packetFields(config, record.Packets)...)
+		if config.DropWarningPercent > 0 && record.Packets.DroppedPercent() > config.DropWarningPercent {
+			embed.Color = 0xffa500
+		}
+	}
+
+	if len(record.Nftables) > 0 {
+		embed.Fields = append(embed.Fields, nftablesFields(config, record)...)
+	}
+
+	if config.ShowPeriodComparison && previous != nil {
+		embed.Fields = append(embed.Fields, comparisonField(config, record, previous))
+	}
+
+	if config.ShowForecast {
+		if field, ok := forecastField(config, record, config.now()); ok {
+			embed.Fields = append(embed.Fields, field)
+		}
+	}
+
+	if config.Pricing != nil {
+		embed.Fields = append(embed.Fields, pricingField(config, record))
+	}
+
+	if config.ShowEquivalentBandwidth {
+		elapsed := periodElapsed(record.Month, config.now())
+		if elapsed > 0 {
+			rate := new(big.Float).Quo(new(big.Float).SetInt(total), big.NewFloat(elapsed.Seconds()))
+			embed.Fields = append(embed.Fields, notify.EmbedField{
+				Name:   tr("相当帯域"),
+				Value:  formatRate(rate) + averageLabel(config.Period),
+				Inline: false,
+			})
+		}
+	}
+
+	if config.BillingUnitBytes > 0 {
+		units := billingUnits(total, config.BillingUnitBytes)
+		embed.Fields = append(embed.Fields, notify.EmbedField{
+			Name:   tr("課金単位"),
+			Value:  fmt.Sprintf(tr("%s 単位（1単位 = %s）"), units.String(), formatBytes(big.NewInt(config.BillingUnitBytes))),
+			Inline: false,
+		})
+	}
+
+	return embed
+}
+
+func lastNotifiedFooter(stats *Stats) *notify.EmbedFooter {
+	if stats.LastNotified.IsZero() {
+		return nil
+	}
+	return &notify.EmbedFooter{Text: tr("前回通知: ") + stats.LastNotified.In(clock.Now().Location()).Format("1/2 15:04")}
+}
+
+// scopedRecord は1つのインターフェースのレポートと、その設定と統計。
+type scopedRecord struct {
+	config *Config
+	stats  *Stats
+	record *PeriodRecord
+}
+
+func recordAttachment(config *Config, stats *Stats, record *PeriodRecord, name string) (notify.Attachment, error) {
+	records := append([]PeriodRecord{}, stats.History...)
+	if len(records) == 0 || records[len(records)-1].Month != record.Month {
+		records = append(records, *record)
+	}
+	var buf bytes.Buffer
+	if err := exportRecords(&buf, config.AttachmentFormat, recordRows(records, false)); err != nil {
+		return notify.Attachment{}, err
+	}
+	return notify.Attachment{Name: name + "." + config.AttachmentFormat, Data: buf.Bytes()}, nil
+}
+
+func ethtoolFields(config *Config) []notify.EmbedField {
+	device, err := resolveInterface(config)
+	var values map[string]uint64
+	if err == nil {
+		values, err = readEthtoolStats(device.Interface, config.EthtoolStats)
+	}
+	if err != nil {
+		slog.Warn("ethtool統計の読み込みエラー", "interface", config.Interface, "error", err)
+	}
+	var fields []notify.EmbedField
+	for _, name := range config.EthtoolStats {
+		value, ok := values[name]
+		if !ok {
+			continue
+		}
+		fields = append(fields, notify.EmbedField{Name: name, Value: strconv.FormatUint(value, 10), Inline: true})
+	}
+	return fields
+}
+
+// sendRecords は1回の実行のレポートを1つのメッセージで送る。複数のインターフェースならそれぞれの埋め込みの後に合計の埋め込みを続ける。
+func sendRecords(config *Config, stats *Stats, records []scopedRecord, budget *RetryBudget) error {
+	var embeds []notify.Embed
+	var files []notify.Attachment
+	var previous []scopedRecord
+	for _, r := range records {
+		before := previousRecord(r.stats.History, r.record.Month)
+		if before == nil && config.ShowPeriodComparison {
+			before = previousUsageRecord(r.config, r.record.Month)
+		}
+		if before != nil {
+			previous = append(previous, scopedRecord{config: r.config, stats: r.stats, record: before})
+		}
+		record := r.record
+		if config.multiInterface() && record.Nftables != nil {
+			// nftables のカウンタはインターフェースごとではないので、合計にだけ表示する。
+			scoped := *record
+			scoped.Nftables = nil
+			record = &scoped
+		}
+		embed := recordEmbed(r.config, record, before)
+		if len(config.EthtoolStats) > 0 {
+			embed.Fields = append(embed.Fields, ethtoolFields(r.config)...)
+		}
+		if config.HistoryMonths > 0 {
+			if field, ok := usageHistoryField(r.config, r.record); ok {
+				embed.Fields = append(embed.Fields, field)
+			}
+		}
+		embeds = append(embeds, embed)
+
+		if config.AttachmentFormat != "" {
+			name := "usage"
+			if config.multiInterface() {
+				name += "-" + r.config.Interface
+			}
+			file, err := recordAttachment(config, r.stats, r.record, name)
+			if err != nil {
+				return err
+			}
+			files = append(files, file)
+		}
+	}
+
+	summary := records[0].record
+	if config.multiInterface() {
+		summary = totalRecord(records)
+		summary.TopInterface, summary.TopBytes = topTalker(config, summary.Interfaces)
+		var before *PeriodRecord
+		if len(previous) == len(records) {
+			before = totalRecord(previous)
+		}
+		embeds = append(embeds, recordEmbed(config, summary, before))
+	}
+
+	report := newReport(config, summary)
+	if config.multiInterface() {
+		for _, r := range records {
+			report.Breakdown = append(report.Breakdown, *newReport(r.config, r.record))
+		}
+	}
+
+	if config.ReportOutputFile != "" && !dryRun {
+		err := writeReportFile(config.ReportOutputFile, report)
+		if err != nil {
+			slog.Error("レポートファイルの書き込みエラー", "path", config.ReportOutputFile, "error", err)
+		}
+	}
+
+	last := &embeds[len(embeds)-1]
+	if len(config.NftablesCounters) > 0 {
+		values, err := readNftablesCounters(config.NftablesCounters)
+		if err != nil {
+			slog.Warn("nftablesカウンタの読み込みエラー", "error", err)
+		}
+		for _, name := range config.NftablesCounters {
+			value, ok := values[name]
+			if !ok {
+				continue
+			}
+			last.Fields = append(last.Fields, notify.EmbedField{Name: name, Value: formatBytes(value), Inline: true})
+		}
+	}
+	if config.ReportChart {
+		if chart, ok := usageChart(config, summary); ok {
+			files = append(files, notify.Attachment{Name: "usage.png", Data: chart})
+			last.Image = &notify.EmbedImage{URL: "attachment://usage.png"}
+		}
+	}
+	last.Footer = lastNotifiedFooter(stats)
+	report.embeds = embeds
+	report.files = files
+
+	return notifyAll(config, budget, func(notifier Notifier) error {
+		return notifier.Send(*report)
+	})
+}
+
+var statsMu sync.Mutex
+
+// periodOutcome は1つのインターフェースの統計を進めた結果。
+type periodOutcome struct {
+	// record は送るレポートで、期間を締める場合は completed を設定する。
+	record    *PeriodRecord
+	completed bool
+	// start は最初の実行で送るベースラインの通知。
+	start *notify.Embed
+}
+
+// advanceStats は1つのインターフェースのカウンタを読み取り、その統計を更新する。統計の保存と結果の送信は呼び出し側が行う。
+func advanceStats(scope interfaceScope, isFirstRun bool, now time.Time, budget *RetryBudget) (*periodOutcome, error) {
+	config, stats := scope.config, scope.stats
+	monthKey := periodKey(config.Period, now)
+
+	device, err := resolveInterface(config)
+	if err != nil {
+		return nil, err
+	}
+	currentRX, currentTX, perInterface, err := readCounters(device)
+	if err != nil {
+		return nil, err
+	}
+	slog.Debug("カウンタを読み取りました", "interface", config.Interface, "rx", currentRX.String(), "tx", currentTX.String(),
+		"baseline_rx", stats.RX.String(), "baseline_tx", stats.TX.String(), "month", stats.Month, "period", monthKey)
+
+	if simulateReset {
+		// ベースラインが0の方向はそれより小さくできないので0のままにする。
+		for _, counter := range []struct{ current, baseline *big.Int }{{&currentRX, &stats.RX}, {&currentTX, &stats.TX}} {
+			if counter.baseline.Sign() > 0 {
+				counter.current.Sub(counter.baseline, big.NewInt(1))
+			} else {
+				counter.current.SetInt64(0)
+			}
+		}
+		if stats.RX.Sign() == 0 && stats.TX.Sign() == 0 {
+			slog.Warn("[simulate-reset] ベースラインが0のため、カウンタのリセットを模擬できません")
+		}
+		slog.Info("[simulate-reset] カウンタがベースラインより小さく見えるように読み取り値を置き換えました", "rx", currentRX.String(), "tx", currentTX.String())
+	}
+
+	if !simulateReset {
+		followDefaultRoute(config, device.Interface, stats, &currentRX, &currentTX)
+	}
+	if !isFirstRun && !simulateReset {
+		if perInterface != nil {
+			adjustInterfaceWraps(config, stats, perInterface)
+		} else {
+			adjustWrap(config, stats, &stats.LastRX, &stats.LastTX, &currentRX, &currentTX)
+		}
+	}
+	if perInterface != nil && !isFirstRun {
+		reconcileInterfaces(stats, perInterface, stats.Month)
+	}
+
+	lastRX, lastTX, lastReadAt := stats.LastRX, stats.LastTX, stats.LastReadAt
+	if !isFirstRun && !lastReadAt.IsZero() && !simulateReset {
+		recordUsage(config, &lastRX, &lastTX, &currentRX, &currentTX, lastReadAt, now, perInterface != nil)
+		writeTimeseries(config, &lastRX, &lastTX, &currentRX, &currentTX, lastReadAt, now, perInterface != nil)
+	}
+	accumulating := config.accumulates() && !isFirstRun && stats.Month != ""
+	if !config.accumulates() {
+		stopAccumulating(stats)
+	}
+	if !accumulating && !isFirstRun && stats.Month != "" {
+		carryOverReset(config, stats, &currentRX, &currentTX, perInterface != nil)
+	}
+	if accumulating && stats.Month == monthKey {
+		accumulateRead(config, stats, &currentRX, &currentTX, perInterface != nil)
+	}
+	stats.LastInterfaces = perInterface
+	stats.LastRX = currentRX
+	stats.LastTX = currentTX
+	stats.LastReadAt = now
+
+	if stats.Month != monthKey {
+		packets := periodPackets(config, stats, true)
+		boundaryRX, boundaryTX := currentRX, currentTX
+		if config.BoundaryMode == "interpolate" {
+			boundary, _, _ := periodBounds(monthKey, now.Location())
+			rx, tx, ok := interpolateBoundary(&lastRX, &lastTX, lastReadAt, &currentRX, &currentTX, now, boundary)
+			if ok {
+				boundaryRX, boundaryTX = *rx, *tx
+				slog.Info("月境界の通信量を補間しました", "from", lastReadAt, "to", now)
+			}
+		}
+
+		var completed *PeriodRecord
+		if !isFirstRun && stats.Month != "" {
+			if accumulating {
+				stats.LastRX, stats.LastTX = lastRX, lastTX
+				accumulateRead(config, stats, &boundaryRX, &boundaryTX, perInterface != nil)
+				stats.LastRX, stats.LastTX = boundaryRX, boundaryTX
+			}
+			usedRX, usedTX := periodUsage(stats, &boundaryRX, &boundaryTX)
+			slog.Debug("締めた期間の通信量を計算しました", "interface", config.Interface, "month", stats.Month, "used_rx", usedRX.String(), "used_tx", usedTX.String())
+			if usedRX.Sign() >= 0 && usedTX.Sign() >= 0 {
+				completed = &PeriodRecord{Month: stats.Month, RX: *usedRX, TX: *usedTX, Packets: packets}
+				completed.Interfaces = report.InterfaceUsage(perInterface, stats.Interfaces)
+				addArchivedUsage(completed.Interfaces, stats.Archived, completed.Month)
+				completed.TopInterface, completed.TopBytes = topTalker(config, completed.Interfaces)
+				checkAnomaly(config, stats.History, completed, budget)
+				stats.History = appendHistory(config, stats.History, *completed)
+			} else {
+				slog.Warn("カウントリセットを検出したため、前の期間の集計を記録できませんでした", "interface", config.Interface)
+			}
+		}
+
+		stats.Month = monthKey
+		stats.RX = boundaryRX
+		stats.TX = boundaryTX
+		stats.Interfaces = perInterface
+		stats.Archived = nil
+		stats.Carried = nil
+		stats.Accumulated = nil
+		if config.accumulates() {
+			stats.Accumulated = &collector.InterfaceStats{}
+			if accumulating {
+				stats.Accumulated.RX.Sub(&currentRX, &boundaryRX)
+				stats.Accumulated.TX.Sub(&currentTX, &boundaryTX)
+			}
+		}
+		stats.LastRX = currentRX
+		stats.LastTX = currentTX
+		stats.AlertedThreshold = false
+		stats.RanQuotaHook = false
+		releaseQuotaActions(config, stats, budget)
+		setCurrentUsage(config, new(big.Int).Sub(&currentRX, &boundaryRX), new(big.Int).Sub(&currentTX, &boundaryTX))
+		slog.Info("新しい月の記録を開始しました", "interface", config.Interface)
+
+		if isFirstRun {
+			if !config.ReportOnFirstRun {
+				slog.Info("初回起動のためDiscord通知をスキップします", "interface", config.Interface)
+				return &periodOutcome{}, nil
+			}
+			start := startEmbed(config, &boundaryRX, &boundaryTX)
+			return &periodOutcome{start: &start}, nil
+		}
+		return &periodOutcome{record: completed, completed: true}, nil
+	}
+
+	usedRX, usedTX := periodUsage(stats, &currentRX, &currentTX)
+	slog.Debug("今期の通信量を計算しました", "interface", config.Interface, "used_rx", usedRX.String(), "used_tx", usedTX.String())
+
+	setCurrentUsage(config, usedRX, usedTX)
+	checkCaps(config, stats, monthKey, usedRX, usedTX, budget)
+	checkThreshold(config, stats, usedRX, usedTX, budget)
+	checkQuota(config, stats, monthKey, usedRX, usedTX, budget)
+	checkQuotaHook(config, stats, usedRX, usedTX)
+	checkQuotaActions(config, stats, monthKey, usedRX, usedTX, budget)
+
+	record := &PeriodRecord{Month: monthKey, RX: *usedRX, TX: *usedTX, Packets: periodPackets(config, stats, false)}
+	record.Interfaces = report.InterfaceUsage(perInterface, stats.Interfaces)
+	addArchivedUsage(record.Interfaces, stats.Archived, monthKey)
+	record.TopInterface, record.TopBytes = topTalker(config, record.Interfaces)
+	return &periodOutcome{record: record}, nil
+}
+
+// SendReport は1つのスケジュールのレポートのジョブ。config.Period の期間が終わっていれば締めてレポートを送り、
+// 失敗したらジョブ全体を再試行する。
+func SendReport(config *Config) {
+	retryReport(context.Background())(config)
+}
+
+// retryReport は SendReport を retryJob で再試行するタスクを返す。再試行の状態は発火ごとに作り直す。
+func retryReport(ctx context.Context) func(*Config) {
+	return func(config *Config) {
+		retryJob(ctx, "SendReport", reportJob())(config)
+	}
+}
+
+// reportJob は SendReport の1回の発火の中で再試行されるジョブを返す。期間を締めた後に通知が失敗した場合、
+// 締めた期間のレポートはもう unsent_reports にあるので、再試行ではそれを送り直すだけにする。
+// sendReport をもう一度実行すると、新しい期間の途中経過のレポートまで送ってしまう。
+func reportJob() func(*Config) error {
+	onlyUnsent := false
+	return func(config *Config) error {
+		if onlyUnsent {
+			return flushUnsent(config)
+		}
+		var err error
+		onlyUnsent, err = runReport(config)
+		return err
+	}
+}
+
+func sendReport(config *Config) error {
+	_, err := runReport(config)
+	return err
+}
+
+// flushUnsent は unsent_reports に残っているレポートだけを送り直す。
+func flushUnsent(config *Config) error {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+
+	unlock, err := lockStats(config)
+	if err != nil {
+		return fmt.Errorf("統計ファイルのロック取得エラー: %w", err)
+	}
+	defer unlock()
+
+	retryBudget, _ := time.ParseDuration(config.RetryBudget)
+	stats, _, err := config.store.Load()
+	if err != nil {
+		return fmt.Errorf("統計ファイルの読み込みエラー: %w", err)
+	}
+	unsentErr := resendUnsent(config, stats, newRetryBudget(retryBudget))
+	err = config.store.Save(stats)
+	if err != nil {
+		return fmt.Errorf("統計ファイルの保存エラー: %w", err)
+	}
+	return unsentErr
+}
+
+// runReport は sendReport の本体。失敗した場合、残りが unsent_reports の送り直しだけかどうかも返す。
+// 統計を保存した後に締めた期間や開始の通知を送れなかった場合と、前の実行で残したレポートだけを送れなかった場合がそう。
+func runReport(config *Config) (bool, error) {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+
+	unlock, err := lockStats(config)
+	if err != nil {
+		return false, fmt.Errorf("統計ファイルのロック取得エラー: %w", err)
+	}
+	defer unlock()
+
+	retryBudget, err := time.ParseDuration(config.RetryBudget)
+	if err != nil {
+		return false, fmt.Errorf("retry_budget が不正です（%q）: %w", config.RetryBudget, err)
+	}
+	budget := newRetryBudget(retryBudget)
+
+	now := config.now()
+
+	stats, isFirstRun, err := config.store.Load()
+	if err != nil {
+		return false, fmt.Errorf("統計ファイルの読み込みエラー: %w", err)
+	}
+
+	unsentErr := resendUnsent(config, stats, budget)
+
+	nftMonth, nftUsage, err := nftablesUsage(config, stats, periodKey(config.Period, now))
+	if err != nil {
+		slog.Warn("nftablesカウンタの読み込みエラー", "error", err)
+	}
+
+	var starts []notify.Embed
+	var records []scopedRecord
+	var completed []PeriodRecord
+	for _, scope := range interfaceScopes(config, stats) {
+		outcome, err := advanceStats(scope, isFirstRun || scope.created, now, budget)
+		if err != nil {
+			if !config.multiInterface() {
+				return false, fmt.Errorf("ネットワーク統計の読み込みエラー: %w", err)
+			}
+			// 1つのインターフェースが消えても、残りのインターフェースは報告する。
+			slog.Warn("ネットワーク統計を読み込めないため、このインターフェースをスキップします", "interface", scope.config.Interface, "error", err)
+			if scope.created {
+				delete(stats.PerInterface, scope.config.Interface)
+			}
+			continue
+		}
+
+		if outcome.start != nil {
+			starts = append(starts, *outcome.start)
+		}
+		if outcome.record == nil {
+			continue
+		}
+		if outcome.record.Month == nftMonth {
+			outcome.record.Nftables = nftUsage
+		}
+		if outcome.completed && config.DigestSchedule != "" {
+			pending := *outcome.record
+			if config.multiInterface() {
+				pending.Interface = scope.config.Interface
+			}
+			stats.PendingDigest = append(stats.PendingDigest, pending)
+			slog.Info("ダイジェスト送信まで前の期間のレポートを保留します", "interface", scope.config.Interface, "month", pending.Month)
+			continue
+		}
+		records = append(records, scopedRecord{config: scope.config, stats: scope.stats, record: outcome.record})
+		if outcome.completed {
+			unsent := *outcome.record
+			if config.multiInterface() {
+				unsent.Interface = scope.config.Interface
+			}
+			completed = append(completed, unsent)
+		}
+	}
+
+	err = config.store.Save(stats)
+	if err != nil {
+		return false, fmt.Errorf("統計ファイルの保存エラー: %w", err)
+	}
+
+	if len(starts) > 0 {
+		err = notifyAll(config, budget, func(notifier Notifier) error {
+			return notifier.SendEmbeds(notify.KindReport, starts)
+		})
+		if err != nil {
+			reportNotifyFailure(err)
+			// ベースラインはもう進めたので、開始の通知とこの実行で締めた期間の
+			// レポートは、再試行か次回の実行で unsent から送り直す。
+			queueUnsent(config, stats, starts, completed)
+			return true, err
+		}
+		markNotified(config, stats, now)
+	}
+	if len(records) == 0 {
+		return true, unsentErr
+	}
+
+	err = sendRecords(config, stats, records, budget)
+	if err != nil {
+		reportNotifyFailure(err)
+		// 締めた期間は、ジョブを再試行するときか次の実行で unsent_reports から送り直す。
+		if len(completed) > 0 {
+			queueUnsent(config, stats, nil, completed)
+		}
+		return len(completed) > 0, err
+	}
+	markNotified(config, stats, now)
+	return true, unsentErr
+}
+
+func markNotified(config *Config, stats *Stats, now time.Time) {
+	stats.LastNotified = now
+	err := config.store.Save(stats)
+	if err != nil {
+		slog.Error("統計ファイルの保存エラー", "error", err)
+	}
+}
+
+func interpolateBoundary(lastRX, lastTX *big.Int, lastAt time.Time, currentRX, currentTX *big.Int, now, boundary time.Time) (*big.Int, *big.Int, bool) {
+	if lastAt.IsZero() || !lastAt.Before(boundary) || now.Before(boundary) {
+		return nil, nil, false
+	}
+	if currentRX.Cmp(lastRX) < 0 || currentTX.Cmp(lastTX) < 0 {
+		return nil, nil, false
+	}
+
+	span := now.Sub(lastAt)
+	if span <= 0 {
+		return nil, nil, false
+	}
+	fraction := new(big.Float).Quo(big.NewFloat(boundary.Sub(lastAt).Seconds()), big.NewFloat(span.Seconds()))
+
+	interpolate := func(last, current *big.Int) *big.Int {
+		delta := new(big.Float).SetInt(new(big.Int).Sub(current, last))
+		delta.Mul(delta, fraction)
+		part, _ := delta.Int(nil)
+		return part.Add(part, last)
+	}
+
+	return interpolate(lastRX, currentRX), interpolate(lastTX, currentTX), true
+}
+
+// SendDigest は digest_schedule のジョブ。ダイジェストのために送らずにおいたレポートを送り、失敗したらジョブ全体を再試行する。
+func SendDigest(config *Config) {
+	retryJob(context.Background(), "SendDigest", sendDigest)(config)
+}
+
+func sendDigest(config *Config) error {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+
+	unlock, err := lockStats(config)
+	if err != nil {
+		return fmt.Errorf("統計ファイルのロック取得エラー: %w", err)
+	}
+	defer unlock()
+
+	stats, _, err := config.store.Load()
+	if err != nil {
+		return fmt.Errorf("統計ファイルの読み込みエラー: %w", err)
+	}
+
+	retryBudget, _ := time.ParseDuration(config.RetryBudget)
+	budget := newRetryBudget(retryBudget)
+
+	if len(stats.PendingDigest) == 0 {
+		slog.Info("ダイジェストに含めるレポートがありません")
+		return nil
+	}
+
+	const maxEmbeds = 10
+	var embeds []notify.Embed
+	for _, record := range stats.PendingDigest {
+		before := previousRecord(historyFor(stats, record.Interface), record.Month)
+		embeds = append(embeds, recordEmbed(scopeConfig(config, &record), &record, before))
+	}
+	embeds[len(embeds)-1].Footer = lastNotifiedFooter(stats)
+	for start := 0; start < len(embeds); start += maxEmbeds {
+		end := min(start+maxEmbeds, len(embeds))
+		err = notifyAll(config, budget, func(notifier Notifier) error {
+			return notifier.SendEmbeds(notify.KindReport, embeds[start:end])
+		})
+		if err != nil {
+			reportNotifyFailure(err)
+			return err
+		}
+		// 後のまとまりが失敗しても、送れたものを次の実行で送り直さないよう、その都度保留から外して保存する。
+		stats.PendingDigest = stats.PendingDigest[end-start:]
+		if end < len(embeds) {
+			if err := config.store.Save(stats); err != nil {
+				return fmt.Errorf("統計ファイルの保存エラー: %w", err)
+			}
+		}
+	}
+
+	stats.PendingDigest = nil
+	stats.LastNotified = clock.Now()
+	err = config.store.Save(stats)
+	if err != nil {
+		return fmt.Errorf("統計ファイルの保存エラー: %w", err)
+	}
+	slog.Info("ダイジェストを送信しました", "periods", len(embeds))
+	return nil
+}
+
+func recoverTask(name string, task func(*Config), config *Config) func() {
+	return func() {
+		defer func() {
+			if r := recover(); r != nil {
+				slog.Error("ジョブの実行中にパニックが発生しました", "job", name, "panic", r, "stack", string(debug.Stack()))
+			}
+		}()
+		task(config)
+	}
+}
+
+// missedReport は停止中にレポートのスケジュールの時刻が過ぎたかどうかを返す。過ぎていれば起動時にそのレポートを実行する。
+func missedReport(config *Config, loc *time.Location) bool {
+	schedule, err := cron.ParseStandard(config.Schedule)
+	if err != nil {
+		slog.Error("スケジュールの解析に失敗", "error", err)
+		return false
+	}
+
+	stats, _, err := config.store.Load()
+	if err != nil {
+		slog.Error("統計ファイルの読み込みエラー", "error", err)
+		return false
+	}
+	lastReadAt := latestReadAt(config, stats)
+	if lastReadAt.IsZero() {
+		return false
+	}
+
+	missed := schedule.Next(lastReadAt.In(loc))
+	if missed.After(clock.Now()) {
+		return false
+	}
+	slog.Info("停止中に実行されなかったレポートを実行します", "scheduled", missed)
+	return true
+}
+
+// Main はコマンドラインを解釈して linux-traffic-checker を実行する。終了コードは os.Exit で返す。
+func Main() {
+	flag.BoolVar(&dryRun, "dry-run", false, "通知や統計ファイルの書き込みを行わず、変更内容を表示して終了する")
+	flag.BoolVar(&simulateReset, "simulate-reset", false, "カウンタのリセットを模擬してリセット時の処理を1回実行して終了する（-dry-run を伴う）")
+	flag.DurationVar(&testClock, "test-clock", 0, "指定した長さを1か月とする加速した時計で動作する（例 60s）。動作確認用")
+	once := flag.Bool("once", false, "レポートを1回だけ実行して終了する。通知に失敗した場合は終了コード 2 を返す")
+	oneshot := flag.Bool("oneshot", false, "常駐せずに、前回の実行からスケジュールの時刻を過ぎたジョブを実行し、それ以外はカウンタを読み取って終了する。systemd のタイマーや cron から定期的に実行する")
+	printConfig := flag.Bool("print-config", false, "既定値を補った設定を秘密情報を伏せてJSONで表示して終了する")
+	defaultConfigPath := "config.json"
+	if path := os.Getenv("LTC_CONFIG"); path != "" {
+		defaultConfigPath = path
+	}
+	configPath := flag.String("config", defaultConfigPath, "設定ファイルのパス（環境変数 LTC_CONFIG でも指定できる）")
+	flag.Var(&configOverrides, "set", "設定を key=value の形式で上書きする（複数指定可）。環境変数 LTC_<KEY> より優先する")
+	validate := flag.Bool("validate", false, "通知や統計ファイルの書き込みを行わずに設定・インターフェース・通知先への接続を確認して終了する")
+	speed := flag.Bool("speed", false, "通知や統計ファイルを使わずに現在の通信速度を表示する。Ctrl+C で終了する")
+	speedInterval := flag.Duration("speed-interval", time.Second, "-speed で速度を測る間隔")
+	speedCount := flag.Int("speed-count", 0, "-speed で表示する回数（0 なら Ctrl+C まで）")
+	flag.StringVar(&vnstatInput, "from-vnstat", "", "import で取り込む vnstat --json の出力ファイル（- なら標準入力）")
+	flag.StringVar(&exportFormat, "format", "json", "export の形式（json, csv, vnstat-json）")
+	flag.StringVar(&exportFrom, "from", "", "export で出力する最初の月か日（例 2024-01、2024-01-15）")
+	flag.StringVar(&exportTo, "to", "", "export で出力する最後の月か日（例 2024-12）")
+	flag.StringVar(&exportGranularity, "granularity", "", "export で json, csv に出力する単位（daily, monthly）。未指定なら統計ファイルの締めた期間")
+	flag.StringVar(&exportOutput, "output", "", "export の出力先のファイル（未指定か - なら標準出力）")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "使い方: %s [report-now|status|reset|test-notify|import|export] [オプション]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	command, args := splitSubcommand(os.Args[1:])
+	flag.CommandLine.Parse(args)
+	// サブコマンドはオプションの後に書いてもよい。
+	rest := flag.Args()
+	if command == "" {
+		command, rest = splitSubcommand(rest)
+		flag.CommandLine.Parse(rest)
+		rest = flag.Args()
+	}
+	if len(rest) > 0 {
+		fmt.Fprintf(flag.CommandLine.Output(), "不明なサブコマンドです: %s\n", rest[0])
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if simulateReset {
+		dryRun = true
+	}
+
+	config, err := readConfig(*configPath)
+	if err != nil && (*validate || command == "doctor") {
+		fmt.Printf("NG  設定ファイル: %v\n", err)
+		os.Exit(1)
+	}
+	if err != nil {
+		slog.Error("設定ファイルの読み込みエラー", "path", *configPath, "error", err)
+		os.Exit(1)
+	}
+	config.apply()
+
+	if *validate {
+		if !runValidation(os.Stdout, config) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *speed {
+		if *speedInterval <= 0 {
+			slog.Error("-speed-interval は0より大きい値を指定してください", "value", *speedInterval)
+			os.Exit(1)
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		err = runSpeed(ctx, os.Stdout, config, *speedInterval, *speedCount)
+		if err != nil {
+			slog.Error("ネットワーク統計の読み込みエラー", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	slog.Debug("設定ファイルを読み込みました", "path", *configPath, "interface", interfaceDisplayName(config), "stats_file", config.StatsFile, "storage_backend", config.StorageBackend)
+
+	if command != "" {
+		os.Exit(subcommands[command](config))
+	}
+
+	if *printConfig {
+		redacted := redactedConfig(config)
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "    ")
+		err = encoder.Encode(&redacted)
+		if err != nil {
+			slog.Error("設定の出力に失敗", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if !dryRun && (*once || *oneshot || config.StatsFile == stdioStatsFile) {
+		err = installNftablesRules(config)
+		if err != nil {
+			slog.Error("nftablesの設定エラー", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	if *oneshot && !dryRun {
+		os.Exit(runOneshot(config))
+	}
+
+	if dryRun || *once {
+		for _, report := range config.reports {
+			SendReport(report)
+		}
+		os.Exit(oneShotExitCode())
+	}
+
+	if config.StatsFile == stdioStatsFile {
+		SendReport(config)
+		if stdoutStats != nil {
+			os.Stdout.Write(append(stdoutStats, '\n'))
+		}
+		os.Exit(oneShotExitCode())
+	}
+
+	if testClock != 0 {
+		clock = newScaledClock(testClock)
+		slog.Warn("テストモード: 加速した時計で動作します", "month", testClock, "stats_file", config.StatsFile)
+	}
+	d, err := startDaemon(config)
+	if err != nil {
+		slog.Error("常駐の開始に失敗", "error", err)
+		os.Exit(1)
+	}
+	sdNotify("READY=1")
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	received := <-signals
+	for received == syscall.SIGHUP {
+		slog.Info("SIGHUPを受信したため、設定ファイルを再読み込みします", "path", *configPath)
+		sdNotifyReloading()
+		d = d.reload(*configPath)
+		config = d.config
+		sdNotify("READY=1")
+		received = <-signals
+	}
+	slog.Info("終了シグナルを受信しました", "signal", received.String())
+	sdNotify("STOPPING=1")
+	// 終了処理が終わらない場合は、もう一度シグナルを送ると保存を待たずに終了する。
+	go func() {
+		received := <-signals
+		slog.Warn("終了処理中に再びシグナルを受信したため、保存を待たずに終了します", "signal", received.String())
+		os.Exit(1)
+	}()
+
+	d.stop()
+	// シャットダウンの前に最後の読み取りを積算し、再起動で失われる通信量を減らす。
+	if config.accumulates() {
+		PollNetStats(config)
+		slog.Info("終了前にカウンタを読み取りました")
+	}
+	// 実行中のジョブが統計を保存し終えるまで待つ。
+	statsMu.Lock()
+	slog.Info("終了しました")
+}
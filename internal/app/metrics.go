@@ -0,0 +1,191 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/rakku1234/linux-traffic-checker/collector"
+)
+
+func writeHistoryMetrics(w io.Writer, config *Config, stats *Stats) {
+	fmt.Fprintln(w, "# HELP linux_traffic_month_total_bytes Bytes transferred in a completed month.")
+	fmt.Fprintln(w, "# TYPE linux_traffic_month_total_bytes gauge")
+
+	for _, scope := range interfaceScopes(config, stats) {
+		history := scope.stats.History
+		if len(history) > config.MetricsHistoryMonths {
+			history = history[len(history)-config.MetricsHistoryMonths:]
+		}
+		name := scope.config.Interface
+		for _, record := range history {
+			fmt.Fprintf(w, "linux_traffic_month_total_bytes{interface=%q,month=%q,direction=\"rx\"} %s\n", name, record.Month, record.RX.String())
+			fmt.Fprintf(w, "linux_traffic_month_total_bytes{interface=%q,month=%q,direction=\"tx\"} %s\n", name, record.Month, record.TX.String())
+		}
+	}
+}
+
+// インターフェースごとの今月の通信量。カウンタを読み取るたびに更新する。
+// まだ読み取っていないインターフェースは0とする。
+var (
+	currentUsageMu sync.Mutex
+	currentUsage   = map[string]*collector.InterfaceStats{}
+
+	discordSendsSucceeded atomic.Int64
+	discordSendsFailed    atomic.Int64
+
+	// notificationResults は再試行した後の通知の結果を通知先と結果ごとに数える。
+	notificationResultsMu sync.Mutex
+	notificationResults   = map[[2]string]int64{}
+)
+
+func countNotification(notifier string, err error) {
+	recordNotification(notifier, err)
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	notificationResultsMu.Lock()
+	defer notificationResultsMu.Unlock()
+	notificationResults[[2]string{notifier, result}]++
+}
+
+func metricsInterface(config *Config) string {
+	if config.InterfacePattern != "" {
+		return config.InterfacePattern
+	}
+	if config.ContainerRuntime != "" {
+		return config.ContainerRuntime
+	}
+	return config.Interface
+}
+
+func setCurrentUsage(config *Config, rx, tx *big.Int) {
+	currentUsageMu.Lock()
+	defer currentUsageMu.Unlock()
+
+	counter := &collector.InterfaceStats{}
+	counter.RX.Set(rx)
+	counter.TX.Set(tx)
+	currentUsage[metricsInterface(config)] = counter
+}
+
+// storedUsage は統計ファイルに保存された最後の読み取りの時点の通信量で、
+// このプロセスがカウンタを読み取るまで使う。
+func storedUsage(stats *Stats) *collector.InterfaceStats {
+	counter := &collector.InterfaceStats{}
+	if stats.Month == "" || stats.LastRX.Cmp(&stats.RX) < 0 || stats.LastTX.Cmp(&stats.TX) < 0 {
+		return counter
+	}
+	rx, tx := periodUsage(stats, &stats.LastRX, &stats.LastTX)
+	counter.RX.Set(rx)
+	counter.TX.Set(tx)
+	return counter
+}
+
+func writeCurrentMetrics(w io.Writer, config *Config, stats *Stats) {
+	currentUsageMu.Lock()
+	defer currentUsageMu.Unlock()
+
+	gauges := []struct {
+		name, help string
+		value      func(counter *collector.InterfaceStats) *big.Int
+	}{
+		{"linux_traffic_current_rx_bytes", "Bytes received so far this month.", func(c *collector.InterfaceStats) *big.Int { return &c.RX }},
+		{"linux_traffic_current_tx_bytes", "Bytes sent so far this month.", func(c *collector.InterfaceStats) *big.Int { return &c.TX }},
+		{"linux_traffic_current_total_bytes", "Bytes counted so far this month.", func(c *collector.InterfaceStats) *big.Int { return countedTotal(config, &c.RX, &c.TX) }},
+	}
+	for _, gauge := range gauges {
+		fmt.Fprintf(w, "# HELP %s %s\n", gauge.name, gauge.help)
+		fmt.Fprintf(w, "# TYPE %s gauge\n", gauge.name)
+		for _, scope := range interfaceScopes(config, stats) {
+			name := metricsInterface(scope.config)
+			counter, ok := currentUsage[name]
+			if !ok {
+				counter = storedUsage(scope.stats)
+			}
+			fmt.Fprintf(w, "%s{interface=%q} %s\n", gauge.name, name, gauge.value(counter).String())
+		}
+	}
+
+	fmt.Fprintln(w, "# HELP linux_traffic_discord_sends_total Discord webhook requests by result.")
+	fmt.Fprintln(w, "# TYPE linux_traffic_discord_sends_total counter")
+	fmt.Fprintf(w, "linux_traffic_discord_sends_total{result=\"success\"} %d\n", discordSendsSucceeded.Load())
+	fmt.Fprintf(w, "linux_traffic_discord_sends_total{result=\"failure\"} %d\n", discordSendsFailed.Load())
+
+	notificationResultsMu.Lock()
+	defer notificationResultsMu.Unlock()
+	fmt.Fprintln(w, "# HELP linux_traffic_notifications_total Notifications by notifier and result, after retries.")
+	fmt.Fprintln(w, "# TYPE linux_traffic_notifications_total counter")
+	for _, notifier := range config.Notifiers {
+		for _, result := range []string{"success", "failure"} {
+			fmt.Fprintf(w, "linux_traffic_notifications_total{notifier=%q,result=%q} %d\n", notifier, result, notificationResults[[2]string{notifier, result}])
+		}
+	}
+}
+
+// writeCounterMetrics は最後の読み取りの時点のカウンタの値と、最後にレポートを送った時刻を出力する。
+func writeCounterMetrics(w io.Writer, config *Config, stats *Stats) {
+	counters := []struct {
+		name, help string
+		value      func(stats *Stats) *big.Int
+	}{
+		{"linux_traffic_counter_rx_bytes", "Interface receive counter at the last read.", func(s *Stats) *big.Int { return &s.LastRX }},
+		{"linux_traffic_counter_tx_bytes", "Interface transmit counter at the last read.", func(s *Stats) *big.Int { return &s.LastTX }},
+	}
+	for _, counter := range counters {
+		fmt.Fprintf(w, "# HELP %s %s\n", counter.name, counter.help)
+		fmt.Fprintf(w, "# TYPE %s counter\n", counter.name)
+		for _, scope := range interfaceScopes(config, stats) {
+			if scope.stats.LastReadAt.IsZero() {
+				continue
+			}
+			fmt.Fprintf(w, "%s{interface=%q} %s\n", counter.name, metricsInterface(scope.config), counter.value(scope.stats).String())
+		}
+	}
+
+	fmt.Fprintln(w, "# HELP linux_traffic_last_report_timestamp_seconds Unix time of the last report sent, 0 if none yet.")
+	fmt.Fprintln(w, "# TYPE linux_traffic_last_report_timestamp_seconds gauge")
+	var last int64
+	if !stats.LastNotified.IsZero() {
+		last = stats.LastNotified.Unix()
+	}
+	fmt.Fprintf(w, "linux_traffic_last_report_timestamp_seconds %d\n", last)
+}
+
+func metricsHandler(config *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		stats, _, err := config.store.Load()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		writeCurrentMetrics(w, config, stats)
+		writeCounterMetrics(w, config, stats)
+		writeHistoryMetrics(w, config, stats)
+	}
+}
+
+// startMetricsServer はバックグラウンドで /metrics と /healthz を提供する。返したサーバーは終了時に Main が止める。
+func startMetricsServer(config *Config) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", metricsHandler(config))
+	mux.HandleFunc("/healthz", healthHandler(config))
+	server := &http.Server{Addr: config.MetricsAddr, Handler: mux}
+
+	go func() {
+		slog.Info("メトリクスサーバーを起動しました", "addr", config.MetricsAddr)
+		err := server.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("メトリクスサーバーのエラー", "error", err)
+		}
+	}()
+	return server
+}
@@ -0,0 +1,201 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+const mqttTimeout = 10 * time.Second
+
+var (
+	mqttMu     sync.Mutex
+	mqttClient mqtt.Client
+	// mqttSamples は通信速度を計算するため、前回送った時点の通信量を持つ。
+	mqttSamples = map[string]CounterSample{}
+)
+
+// mqttState はインターフェースの state トピックに retain 付きで送る内容。
+// Home Assistant のセンサーは value_template でここから値を取り出す。
+type mqttState struct {
+	Period string   `json:"period"`
+	RX     *big.Int `json:"rx"`
+	TX     *big.Int `json:"tx"`
+	Total  *big.Int `json:"total"`
+	RXRate float64  `json:"rx_rate"`
+	TXRate float64  `json:"tx_rate"`
+}
+
+type mqttSensor struct {
+	key, name, unit, deviceClass, stateClass string
+}
+
+var mqttSensors = []mqttSensor{
+	{"rx", "受信", "B", "data_size", "total_increasing"},
+	{"tx", "送信", "B", "data_size", "total_increasing"},
+	{"total", "合計", "B", "data_size", "total_increasing"},
+	{"rx_rate", "受信速度", "bit/s", "data_rate", "measurement"},
+	{"tx_rate", "送信速度", "bit/s", "data_rate", "measurement"},
+}
+
+// mqttHost は Home Assistant のデバイスと、mqtt_topic_prefix の下のトピックに使う名前。
+func mqttHost() string {
+	host, err := os.Hostname()
+	if err != nil {
+		return "localhost"
+	}
+	return host
+}
+
+// mqttName は名前をトピックの1階層とオブジェクトIDに使える形にする。
+var mqttName = strings.NewReplacer("/", "_", "+", "_", "#", "_", " ", "_", ".", "_").Replace
+
+func mqttAvailabilityTopic(config *Config) string {
+	return fmt.Sprintf("%s/%s/status", config.MQTTTopicPrefix, mqttName(mqttHost()))
+}
+
+func mqttStateTopic(config *Config, interfaceName string) string {
+	return fmt.Sprintf("%s/%s/%s/state", config.MQTTTopicPrefix, mqttName(mqttHost()), mqttName(interfaceName))
+}
+
+func mqttWait(token mqtt.Token) error {
+	if !token.WaitTimeout(mqttTimeout) {
+		return errors.New("MQTTブローカーの応答がタイムアウトしました")
+	}
+	return token.Error()
+}
+
+// connectMQTT は一度だけ接続し、再接続はクライアントに任せる。
+// プロセスが異常終了した場合は、Last Will でセンサーを利用不可にする。
+func connectMQTT(config *Config) (mqtt.Client, error) {
+	if mqttClient != nil {
+		return mqttClient, nil
+	}
+	availability := mqttAvailabilityTopic(config)
+	options := mqtt.NewClientOptions().
+		AddBroker(config.MQTTBrokerURL).
+		SetClientID("linux-traffic-checker-"+mqttName(mqttHost())).
+		SetUsername(config.MQTTUsername).
+		SetPassword(config.MQTTPassword).
+		SetConnectTimeout(mqttTimeout).
+		SetAutoReconnect(true).
+		SetWill(availability, "offline", 1, true).
+		SetOnConnectHandler(func(client mqtt.Client) {
+			client.Publish(availability, 1, true, "online")
+		})
+	client := mqtt.NewClient(options)
+	if err := mqttWait(client.Connect()); err != nil {
+		return nil, fmt.Errorf("MQTTブローカー %s に接続できません: %w", config.MQTTBrokerURL, err)
+	}
+	mqttClient = client
+	return client, nil
+}
+
+// publishDiscovery はインターフェースのセンサーを Home Assistant に知らせる。
+// 設定は retain 付きなので、Home Assistant を再起動しても見つかる。
+func publishDiscovery(client mqtt.Client, config *Config, interfaceName string) error {
+	host := mqttHost()
+	for _, sensor := range mqttSensors {
+		objectID := fmt.Sprintf("%s_%s_%s", mqttName(host), mqttName(interfaceName), sensor.key)
+		payload, err := json.Marshal(map[string]any{
+			"name":                fmt.Sprintf("%s %s", interfaceName, sensor.name),
+			"unique_id":           "linux_traffic_" + objectID,
+			"state_topic":         mqttStateTopic(config, interfaceName),
+			"value_template":      fmt.Sprintf("{{ value_json.%s }}", sensor.key),
+			"unit_of_measurement": sensor.unit,
+			"device_class":        sensor.deviceClass,
+			"state_class":         sensor.stateClass,
+			"availability_topic":  mqttAvailabilityTopic(config),
+			"device": map[string]any{
+				"identifiers":  []string{"linux_traffic_" + mqttName(host)},
+				"name":         host,
+				"manufacturer": "linux-traffic-checker",
+			},
+		})
+		if err != nil {
+			return err
+		}
+		topic := fmt.Sprintf("%s/sensor/%s/config", config.MQTTDiscoveryPrefix, objectID)
+		if err := mqttWait(client.Publish(topic, 1, true, payload)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PublishMQTT は監視するすべてのインターフェースについて、今期のこれまでの通信量と前回送ってからの通信速度を送る。
+// インターフェースのセンサーは最初に送るときに知らせる。
+func PublishMQTT(config *Config) {
+	mqttMu.Lock()
+	defer mqttMu.Unlock()
+
+	status, err := buildStatus(config)
+	if err != nil {
+		slog.Error("統計ファイルの読み込みエラー", "error", err)
+		return
+	}
+	if dryRun {
+		slog.Info("[dry-run] MQTTへの送信をスキップします", "broker", config.MQTTBrokerURL)
+		return
+	}
+	client, err := connectMQTT(config)
+	if err != nil {
+		slog.Error("MQTTへの送信エラー", "error", err)
+		return
+	}
+
+	for _, item := range status.Interfaces {
+		before, known := mqttSamples[item.Interface]
+		if !known {
+			if err := publishDiscovery(client, config, item.Interface); err != nil {
+				slog.Error("MQTTへの送信エラー", "interface", item.Interface, "error", err)
+				continue
+			}
+		}
+		after := CounterSample{Elapsed: sinceStart()}
+		after.RX.Set(item.UsedRX)
+		after.TX.Set(item.UsedTX)
+		mqttSamples[item.Interface] = after
+
+		state := mqttState{Period: status.Period, RX: item.UsedRX, TX: item.UsedTX, Total: item.UsedTotal}
+		// 期間が変わると通信量は0から数え直すので、その間隔の通信速度はない。
+		if known && item.Live {
+			if rx, tx, err := sampleRate(&before, &after); err == nil {
+				state.RXRate, _ = new(big.Float).Mul(rx, big.NewFloat(8)).Float64()
+				state.TXRate, _ = new(big.Float).Mul(tx, big.NewFloat(8)).Float64()
+			}
+		}
+		payload, err := json.Marshal(state)
+		if err != nil {
+			slog.Error("MQTTへの送信エラー", "interface", item.Interface, "error", err)
+			continue
+		}
+		if err := mqttWait(client.Publish(mqttStateTopic(config, item.Interface), 1, true, payload)); err != nil {
+			slog.Error("MQTTへの送信エラー", "interface", item.Interface, "error", err)
+			continue
+		}
+		slog.Debug("MQTTに送信しました", "interface", item.Interface, "rx", state.RX.String(), "tx", state.TX.String())
+	}
+}
+
+// disconnectMQTT は正常に終了する前にセンサーを利用不可にする。
+// ブローカーが Last Will を送るのは接続が切れた場合だけなので。
+func disconnectMQTT(config *Config) {
+	mqttMu.Lock()
+	defer mqttMu.Unlock()
+
+	if mqttClient == nil {
+		return
+	}
+	_ = mqttWait(mqttClient.Publish(mqttAvailabilityTopic(config), 1, true, "offline"))
+	mqttClient.Disconnect(250)
+	mqttClient = nil
+}
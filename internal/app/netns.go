@@ -0,0 +1,61 @@
+//go:build linux
+
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"golang.org/x/sys/unix"
+)
+
+func readNetDevInNetns(name string) ([]byte, error) {
+	type result struct {
+		data []byte
+		err  error
+	}
+	done := make(chan result, 1)
+
+	go func() {
+		runtime.LockOSThread()
+
+		original, err := os.Open("/proc/thread-self/ns/net")
+		if err != nil {
+			runtime.UnlockOSThread()
+			done <- result{err: err}
+			return
+		}
+		defer original.Close()
+
+		target, err := os.Open(filepath.Join("/var/run/netns", name))
+		if err != nil {
+			runtime.UnlockOSThread()
+			done <- result{err: fmt.Errorf("ネットワーク名前空間 %s を開けません: %w", name, err)}
+			return
+		}
+		defer target.Close()
+
+		err = unix.Setns(int(target.Fd()), unix.CLONE_NEWNET)
+		if err != nil {
+			runtime.UnlockOSThread()
+			done <- result{err: fmt.Errorf("ネットワーク名前空間 %s に入れません: %w", name, err)}
+			return
+		}
+
+		data, readErr := os.ReadFile("/proc/thread-self/net/dev")
+
+		err = unix.Setns(int(original.Fd()), unix.CLONE_NEWNET)
+		if err != nil {
+			// 元の名前空間に戻せなかったスレッドは再利用させず、ロックしたまま終了させる。
+			done <- result{err: fmt.Errorf("元のネットワーク名前空間に戻れません: %w", err)}
+			return
+		}
+		runtime.UnlockOSThread()
+		done <- result{data: data, err: readErr}
+	}()
+
+	r := <-done
+	return r.data, r.err
+}
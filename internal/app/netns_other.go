@@ -0,0 +1,13 @@
+//go:build !linux
+
+package app
+
+import (
+	"errors"
+	"fmt"
+)
+
+// readNetDevInNetns には Linux にしかないネットワーク名前空間が必要。
+func readNetDevInNetns(name string) ([]byte, error) {
+	return nil, fmt.Errorf("ネットワーク名前空間 %s: netns_name はLinuxでのみ使えます: %w", name, errors.ErrUnsupported)
+}
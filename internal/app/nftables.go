@@ -0,0 +1,228 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/rakku1234/linux-traffic-checker/notify"
+)
+
+type nftCounter struct {
+	Family  string `json:"family"`
+	Table   string `json:"table"`
+	Name    string `json:"name"`
+	Packets uint64 `json:"packets"`
+	Bytes   uint64 `json:"bytes"`
+}
+
+type nftListOutput struct {
+	Nftables []struct {
+		Counter *nftCounter `json:"counter"`
+	} `json:"nftables"`
+}
+
+// nftablesTable は nftables_rules のカウンタを作るテーブル。
+const nftablesTable = "linux_traffic_checker"
+
+// NftablesRule は linux-traffic-checker が自分で作るカウンタ。
+type NftablesRule struct {
+	// カウンタの名前。英字で始まり、英数字と _ だけを使う。
+	Name string `json:"name"`
+	// 数えるパケットの条件（nft のルールの書式。例: "meta nfproto ipv6"、"tcp dport 443"）。
+	Match string `json:"match"`
+	// "in" は受信（prerouting）、"out" は送信（postrouting）のパケットを数える。
+	Direction string `json:"direction"`
+	// レポートに表示する名前。空なら name。
+	Label string `json:"label"`
+	// true の場合、同じ方向の通信量に対する割合も表示する。
+	Share bool `json:"share"`
+}
+
+var nftablesRuleName = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+
+func validateNftablesRules(rules []NftablesRule) error {
+	seen := map[string]bool{}
+	for _, rule := range rules {
+		if !nftablesRuleName.MatchString(rule.Name) {
+			return fmt.Errorf("nftables_rules の name が不正です: %q", rule.Name)
+		}
+		if seen[rule.Name] {
+			return fmt.Errorf("nftables_rules の name が重複しています: %q", rule.Name)
+		}
+		seen[rule.Name] = true
+		if strings.TrimSpace(rule.Match) == "" || strings.ContainsAny(rule.Match, ";\n#") {
+			return fmt.Errorf("nftables_rules の match が不正です: %q", rule.Match)
+		}
+		if rule.Direction != "in" && rule.Direction != "out" {
+			return fmt.Errorf("nftables_rules の direction は in, out のいずれかを指定してください: %q", rule.Direction)
+		}
+	}
+	return nil
+}
+
+// nftablesScript はテーブルとそのカウンタ・チェインを宣言し、ルールを置き換える。既にあるカウンタを
+// 宣言しても値はそのままなので、再起動や再読み込みでも数えた値は失われない。
+func nftablesScript(rules []NftablesRule) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "table inet %s {\n", nftablesTable)
+	for _, rule := range rules {
+		fmt.Fprintf(&b, "\tcounter %s {\n\t}\n", rule.Name)
+	}
+	b.WriteString("\tchain prerouting {\n\t\ttype filter hook prerouting priority -300; policy accept;\n\t}\n")
+	b.WriteString("\tchain postrouting {\n\t\ttype filter hook postrouting priority 300; policy accept;\n\t}\n")
+	b.WriteString("}\n")
+	for _, chain := range []string{"prerouting", "postrouting"} {
+		fmt.Fprintf(&b, "flush chain inet %s %s\n", nftablesTable, chain)
+	}
+	for _, rule := range rules {
+		chain := "prerouting"
+		if rule.Direction == "out" {
+			chain = "postrouting"
+		}
+		fmt.Fprintf(&b, "add rule inet %s %s %s counter name %q\n", nftablesTable, chain, rule.Match, rule.Name)
+	}
+	return b.String()
+}
+
+// installNftablesRules は nft -f で nftables_rules のカウンタを作る。
+func installNftablesRules(config *Config) error {
+	if len(config.NftablesRules) == 0 {
+		return nil
+	}
+	cmd := exec.Command("nft", "-f", "-")
+	cmd.Stdin = strings.NewReader(nftablesScript(config.NftablesRules))
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("nftablesのカウンタを設定できません: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+func listNftCounters(args ...string) ([]*nftCounter, error) {
+	output, err := exec.Command("nft", append([]string{"-j", "list", "counters"}, args...)...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("nft コマンドの実行に失敗: %w", err)
+	}
+
+	var list nftListOutput
+	err = json.Unmarshal(output, &list)
+	if err != nil {
+		return nil, fmt.Errorf("nft の出力を解析できません: %w", err)
+	}
+
+	var counters []*nftCounter
+	for _, item := range list.Nftables {
+		if item.Counter != nil {
+			counters = append(counters, item.Counter)
+		}
+	}
+	return counters, nil
+}
+
+func readNftablesCounters(names []string) (map[string]*big.Int, error) {
+	counters, err := listNftCounters()
+	if err != nil {
+		return nil, err
+	}
+
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		wanted[name] = true
+	}
+
+	values := make(map[string]*big.Int)
+	for _, counter := range counters {
+		if !wanted[counter.Name] {
+			continue
+		}
+		values[counter.Name] = new(big.Int).SetUint64(counter.Bytes)
+	}
+
+	return values, nil
+}
+
+// NftablesBaseline は期間の始めの nftables_rules のカウンタの値。
+type NftablesBaseline struct {
+	Month string              `json:"month"`
+	Bytes map[string]*big.Int `json:"bytes"`
+}
+
+func readNftablesRules(rules []NftablesRule) (map[string]*big.Int, error) {
+	counters, err := listNftCounters("table", "inet", nftablesTable)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]*big.Int)
+	for _, counter := range counters {
+		values[counter.Name] = new(big.Int).SetUint64(counter.Bytes)
+	}
+	for _, rule := range rules {
+		if values[rule.Name] == nil {
+			return nil, fmt.Errorf("nftablesのカウンタ %s がありません", rule.Name)
+		}
+	}
+	return values, nil
+}
+
+// nftablesUsage は nftables_rules のカウンタが期間中に数えた値と、その期間を返す。期間が変わった後の
+// 最初の読み取りでは終わったばかりの期間、それ以外は今の期間。ベースラインがなければ nil を返す。
+func nftablesUsage(config *Config, stats *Stats, monthKey string) (string, map[string]*big.Int, error) {
+	if len(config.NftablesRules) == 0 {
+		stats.NftablesBaseline = nil
+		return "", nil, nil
+	}
+	current, err := readNftablesRules(config.NftablesRules)
+	if err != nil {
+		return "", nil, err
+	}
+	baseline := stats.NftablesBaseline
+	if baseline == nil || baseline.Month != monthKey {
+		stats.NftablesBaseline = &NftablesBaseline{Month: monthKey, Bytes: current}
+	}
+	if baseline == nil {
+		return "", nil, nil
+	}
+
+	usage := make(map[string]*big.Int)
+	for _, rule := range config.NftablesRules {
+		used := new(big.Int).Set(current[rule.Name])
+		// ベースラインより小さいカウンタは作り直されていて、0から数えている。
+		if before := baseline.Bytes[rule.Name]; before != nil && used.Cmp(before) >= 0 {
+			used.Sub(used, before)
+		}
+		usage[rule.Name] = used
+	}
+	return baseline.Month, usage, nil
+}
+
+// nftablesFields はルールごとの通信量を表示する。share を指定したルールは同じ方向の通信量に対する割合も表示する。
+func nftablesFields(config *Config, record *PeriodRecord) []notify.EmbedField {
+	var fields []notify.EmbedField
+	for _, rule := range config.NftablesRules {
+		used := record.Nftables[rule.Name]
+		if used == nil {
+			continue
+		}
+		name := rule.Label
+		if name == "" {
+			name = rule.Name
+		}
+		value := formatBytes(used)
+		if rule.Share {
+			total := &record.RX
+			if rule.Direction == "out" {
+				total = &record.TX
+			}
+			if total.Sign() > 0 {
+				share, _ := new(big.Float).Quo(new(big.Float).SetInt(used), new(big.Float).SetInt(total)).Float64()
+				value += fmt.Sprintf(tr("（%.0f%%）"), share*100)
+			}
+		}
+		fields = append(fields, notify.EmbedField{Name: name, Value: value, Inline: true})
+	}
+	return fields
+}
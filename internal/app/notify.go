@@ -0,0 +1,243 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/rakku1234/linux-traffic-checker/notify"
+)
+
+// Notifier はレポートの表示も受け持つ notify.Notifier。Send はレポートをサービスの形式で表示し、
+// SendEmbeds はアラートなどのメッセージを届ける。Discord 以外の通知先はそれらを文章に変換する。
+type Notifier interface {
+	notify.Notifier
+	Send(report Report) error
+}
+
+func newNotifier(name string, config *Config) Notifier {
+	switch name {
+	case "slack":
+		return &SlackNotifier{config: config}
+	case "telegram":
+		return &TelegramNotifier{config: config}
+	case "webhook":
+		return &WebhookNotifier{config: config}
+	case "collector":
+		return &CollectorNotifier{config: config}
+	case "email":
+		return &EmailNotifier{config: config}
+	case "ntfy":
+		return &NtfyNotifier{config: config}
+	case "gotify":
+		return &GotifyNotifier{config: config}
+	default:
+		return &DiscordNotifier{config: config}
+	}
+}
+
+// notifyAll は config.Notifiers のそれぞれに対して send を並行して実行し、再試行も通知先ごとに行う。
+// 1つの通知先が壊れているせいで他の通知先に再び送ることのないよう、すべての通知先が失敗した場合だけ失敗とする。
+func notifyAll(config *Config, budget *RetryBudget, send func(notifier Notifier) error) error {
+	sends := make([]func() error, len(config.Notifiers))
+	for i, name := range config.Notifiers {
+		notifier := newNotifier(name, config)
+		sends[i] = func() error {
+			err := withRetry(budget, name, func() error { return send(notifier) })
+			countNotification(name, err)
+			return err
+		}
+	}
+	errs := fanOut(config.NotifyConcurrency, sends)
+	if !slices.Contains(errs, nil) {
+		return errors.Join(errs...)
+	}
+	for i, err := range errs {
+		if err != nil {
+			slog.Warn("一部の通知先への送信に失敗しました", "notifier", config.Notifiers[i], "error", err)
+		}
+	}
+	return nil
+}
+
+type DiscordNotifier struct {
+	config *Config
+}
+
+// Send はレポートのために用意した埋め込みを、Discord の上限の1メッセージ10個ずつに分けて送り、
+// 添付ファイルは最後のメッセージに付ける。
+func (n *DiscordNotifier) Send(report Report) error {
+	embeds := report.embeds
+	if len(embeds) == 0 {
+		embeds = []notify.Embed{buildEmbed(n.config, report.PeriodLabel, report.RXText, report.TXText, report.TotalText)}
+	}
+
+	const maxEmbeds = 10
+	for start := 0; start < len(embeds); start += maxEmbeds {
+		end := min(start+maxEmbeds, len(embeds))
+		var files []notify.Attachment
+		if end == len(embeds) {
+			files = report.files
+		}
+		err := sendToDiscord(n.config, notify.KindReport, embeds[start:end], files...)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *DiscordNotifier) SendEmbeds(kind notify.Kind, embeds []notify.Embed) error {
+	return sendToDiscord(n.config, kind, embeds)
+}
+
+// reportLines は文章で送る通知先が共通で使う本文。
+func reportLines(config *Config, report *Report) []string {
+	lines := []string{
+		fmt.Sprintf("%s: %s", fieldLabel(config, "rx"), report.RXText),
+		fmt.Sprintf("%s: %s", fieldLabel(config, "tx"), report.TXText),
+		fmt.Sprintf("%s: %s", fieldLabel(config, "total"), report.TotalText),
+	}
+	for _, item := range report.Breakdown {
+		lines = append(lines, fmt.Sprintf("%s: %s", item.Interface, item.TotalText))
+	}
+	return lines
+}
+
+func reportTitle(config *Config, report *Report) string {
+	return testModeTitle(formatReportTitle(config, report.Interface, report.PeriodLabel))
+}
+
+func embedTitle(embed notify.Embed) string {
+	return testModeTitle(embed.Title)
+}
+
+// testModeTitle は、テストモードのときにタイトルの先頭に印を付ける。送信する
+// タイトルはすべてここを通し、埋め込みそのものには書き込まない。
+func testModeTitle(title string) string {
+	if testClock != 0 {
+		return tr("[テストモード] ") + title
+	}
+	return title
+}
+
+func embedLines(embed notify.Embed) []string {
+	var lines []string
+	for _, field := range embed.Fields {
+		lines = append(lines, fmt.Sprintf("%s: %s", field.Name, field.Value))
+	}
+	return lines
+}
+
+type SlackNotifier struct {
+	config *Config
+}
+
+func (n *SlackNotifier) Send(report Report) error {
+	style := messageStyle(n.config, "slack", notify.KindReport)
+	return n.post(style.title(reportTitle(n.config, &report)), reportLines(n.config, &report), style)
+}
+
+func (n *SlackNotifier) SendEmbeds(kind notify.Kind, embeds []notify.Embed) error {
+	style := messageStyle(n.config, "slack", kind)
+	for _, embed := range embeds {
+		err := n.post(style.title(embedTitle(embed)), embedLines(embed), style)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *SlackNotifier) post(title string, lines []string, style MessageStyle) error {
+	if n.config.SlackWebhookURL == "" {
+		slog.Warn("Slack Webhook URLが未設定のためSlack通知をスキップします")
+		return nil
+	}
+	// Slack が表示するのはブロックなので、ブロックにする前の行で省略する。
+	title, lines = limitLines(n.config, "slack", title, lines)
+	if dryRun {
+		slog.Info("[dry-run] Slackへの送信をスキップします", "title", title)
+		return nil
+	}
+	slack := notify.Slack{WebhookURL: n.config.SlackWebhookURL, Client: httpClient}
+	return slack.Post(title, lines, style.Mention)
+}
+
+type TelegramNotifier struct {
+	config *Config
+}
+
+func (n *TelegramNotifier) Send(report Report) error {
+	style := messageStyle(n.config, "telegram", notify.KindReport)
+	return n.post(style.title(reportTitle(n.config, &report)), reportLines(n.config, &report), style)
+}
+
+func (n *TelegramNotifier) SendEmbeds(kind notify.Kind, embeds []notify.Embed) error {
+	style := messageStyle(n.config, "telegram", kind)
+	for _, embed := range embeds {
+		err := n.post(style.title(embedTitle(embed)), embedLines(embed), style)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *TelegramNotifier) post(title string, lines []string, style MessageStyle) error {
+	if n.config.TelegramBotToken == "" || n.config.TelegramChatID == "" {
+		slog.Warn("TelegramのボットトークンまたはチャットIDが未設定のためTelegram通知をスキップします")
+		return nil
+	}
+	title, lines = limitLines(n.config, "telegram", title, lines)
+	if dryRun {
+		slog.Info("[dry-run] Telegramへの送信をスキップします", "title", title)
+		return nil
+	}
+	telegram := notify.Telegram{BotToken: n.config.TelegramBotToken, ChatID: n.config.TelegramChatID, Client: httpClient}
+	return telegram.Post(title, lines, style.Mention)
+}
+
+// WebhookNotifier は表示を自分で組み立てる受け手のために、レポートとアラートをJSONで generic_webhook_url に送る。
+type WebhookNotifier struct {
+	config *Config
+}
+
+func (n *WebhookNotifier) Send(report Report) error {
+	style := messageStyle(n.config, "webhook", notify.KindReport)
+	return n.post(notify.WebhookMessage{
+		Kind:    notify.KindReport,
+		Title:   style.title(reportTitle(n.config, &report)),
+		Mention: style.Mention,
+		Report:  &report,
+	})
+}
+
+func (n *WebhookNotifier) SendEmbeds(kind notify.Kind, embeds []notify.Embed) error {
+	style := messageStyle(n.config, "webhook", kind)
+	for _, embed := range embeds {
+		message := notify.WebhookMessage{Kind: kind, Title: style.title(embedTitle(embed)), Mention: style.Mention}
+		for _, field := range embed.Fields {
+			message.Fields = append(message.Fields, notify.WebhookField{Name: field.Name, Value: field.Value})
+		}
+		err := n.post(message)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *WebhookNotifier) post(message notify.WebhookMessage) error {
+	if n.config.GenericWebhookURL == "" {
+		slog.Warn("generic_webhook_url が未設定のためWebhook通知をスキップします")
+		return nil
+	}
+	if dryRun {
+		slog.Info("[dry-run] Webhookへの送信をスキップします", "title", message.Title)
+		return nil
+	}
+	webhook := notify.Webhook{URL: n.config.GenericWebhookURL, Client: httpClient}
+	return webhook.Post(message)
+}
@@ -0,0 +1,68 @@
+package app
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// scheduleDue は since から now までの間に cron のスケジュールの時刻があったかどうかを返す。
+// since が0ならまだ何も実行していないので、常に実行する。
+func scheduleDue(spec string, since time.Time, loc *time.Location) bool {
+	if since.IsZero() {
+		return true
+	}
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		slog.Error("スケジュールの解析に失敗", "schedule", spec, "error", err)
+		return false
+	}
+	return !schedule.Next(since.In(loc)).After(clock.Now())
+}
+
+// lastRun は config の統計を最後に読み取った時刻で、最初の実行の前は0。
+func lastRun(config *Config) time.Time {
+	stats, isFirstRun, err := config.store.Load()
+	if err != nil || isFirstRun {
+		return time.Time{}
+	}
+	return latestReadAt(config, stats)
+}
+
+// runOneshot は systemd のタイマーや cron などの外部のスケジューラーのために、常駐時のジョブを1回分実行する。
+// 前回の実行からスケジュールの時刻を過ぎたジョブはすぐに実行し、それ以外はアラートや history_db が
+// 最新になるよう、読み取りのジョブと同じくカウンタを読み取るだけ。実行の間の状態はすべて統計に残す。
+func runOneshot(config *Config) int {
+	ApplyScheduledReset(config)
+	if config.ResetTriggerFile != "" {
+		CheckResetTrigger(config)
+	}
+
+	since := lastRun(config)
+	for _, report := range config.reports {
+		job := SendReport
+		if report.cycleStartOnly {
+			job = onCycleStart(SendReport)
+		}
+		if scheduleDue(report.Schedule, lastRun(report), config.location) {
+			job(report)
+		} else {
+			PollNetStats(report)
+		}
+	}
+	if config.DigestSchedule != "" && !since.IsZero() && scheduleDue(config.DigestSchedule, since, config.location) {
+		SendDigest(config)
+	}
+	if config.CollectorAddr != "" && !since.IsZero() && scheduleDue(config.CollectorSchedule, since, config.location) {
+		SendFleetReport(config)
+	}
+	if config.NotifyOnInterfaceDown {
+		CheckInterfaceState(config)
+	}
+	if config.MQTTBrokerURL != "" {
+		PublishMQTT(config)
+		disconnectMQTT(config)
+	}
+	return oneShotExitCode()
+}
@@ -0,0 +1,97 @@
+package app
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/rakku1234/linux-traffic-checker/collector"
+	"github.com/rakku1234/linux-traffic-checker/notify"
+)
+
+// sumPackets は監視するインターフェースのカウンタを合計する。1つだけなら config.Interface のカウンタを返す。
+func sumPackets(config *Config, counts map[string]*collector.PacketCounts) (*collector.PacketCounts, error) {
+	if !config.aggregate() {
+		count, ok := counts[config.Interface]
+		if !ok {
+			return nil, fmt.Errorf("インターフェース %s が見つかりません", config.Interface)
+		}
+		return count, nil
+	}
+	total := &collector.PacketCounts{}
+	for name, count := range counts {
+		if selectedInterface(config, name) {
+			total.Add(count)
+		}
+	}
+	return total, nil
+}
+
+// readPacketCounts は監視するインターフェースの今のパケット数のカウンタを、
+// ネットワーク名前空間か collector.Default から読み取る。
+func readPacketCounts(config *Config) (*collector.PacketCounts, error) {
+	config, err := resolveInterface(config)
+	if err != nil {
+		return nil, err
+	}
+	if config.NetnsName != "" {
+		data, err := readNetDevInNetns(config.NetnsName)
+		if err != nil {
+			return nil, err
+		}
+		counts, err := collector.ParseNetDevPackets(string(data))
+		if err != nil {
+			return nil, err
+		}
+		return sumPackets(config, counts)
+	}
+	if !config.aggregate() {
+		return collector.Default.InterfacePackets(config.Interface)
+	}
+	counts, err := collector.Default.Packets()
+	if err != nil {
+		return nil, err
+	}
+	return sumPackets(config, counts)
+}
+
+func (config *Config) packetStats() bool {
+	return config.ShowPackets || config.DropWarningPercent > 0
+}
+
+// periodPackets は期間の始めからのパケット数を返す。レポートしない場合や読み取れない場合は nil を返す。
+// 期間を締める場合は、今のカウンタを次の期間のベースラインにする。
+func periodPackets(config *Config, stats *Stats, closing bool) *collector.PacketCounts {
+	if !config.packetStats() {
+		stats.PacketBaseline = nil
+		return nil
+	}
+	current, err := readPacketCounts(config)
+	if err != nil {
+		slog.Warn("パケット数の読み込みエラー", "interface", config.Interface, "error", err)
+		return nil
+	}
+	baseline := stats.PacketBaseline
+	if baseline == nil || closing {
+		stats.PacketBaseline = current
+	}
+	if baseline == nil {
+		return nil
+	}
+	return collector.PacketDelta(current, baseline)
+}
+
+// packetFields は show_packets なら期間のパケット数を、エラーと破棄は常に表示する。
+func packetFields(config *Config, counts *collector.PacketCounts) []notify.EmbedField {
+	pair := func(rx, tx uint64) string {
+		return fmt.Sprintf("%s %d / %s %d", fieldLabel(config, "rx"), rx, fieldLabel(config, "tx"), tx)
+	}
+	var fields []notify.EmbedField
+	if config.ShowPackets {
+		fields = append(fields, notify.EmbedField{Name: tr("パケット"), Value: pair(counts.RXPackets, counts.TXPackets), Inline: false})
+	}
+	fields = append(fields,
+		notify.EmbedField{Name: tr("エラー"), Value: pair(counts.RXErrors, counts.TXErrors), Inline: true},
+		notify.EmbedField{Name: tr("破棄"), Value: fmt.Sprintf(tr("%s（%.2f%%）"), pair(counts.RXDropped, counts.TXDropped), counts.DroppedPercent()), Inline: true},
+	)
+	return fields
+}
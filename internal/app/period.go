@@ -0,0 +1,106 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// billingDay は設定の billing_day から決まる、月ごとの期間が始まる日。Config.apply で設定する。
+var billingDay = 1
+
+// cycleStart は month に始まる月ごとの期間の始まりを返す。billingDay の日がない月は末日に始まる。
+func cycleStart(year int, month time.Month, loc *time.Location) time.Time {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, loc)
+	days := first.AddDate(0, 1, -1).Day()
+	return first.AddDate(0, 0, min(billingDay, days)-1)
+}
+
+// 期間のキーはベースラインがどの期間のものかを表す。period を変えても保存したキーを読めるよう、
+// 形式で期間の単位を区別する。monthly は "2006-01"、weekly は ISO 週の "2006-W01"、daily は "2006-01-02"。
+// monthly のキーは期間が始まる月を表す。
+func periodKey(period string, t time.Time) string {
+	switch period {
+	case "weekly":
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%04d-W%02d", year, week)
+	case "daily":
+		return t.Format("2006-01-02")
+	default:
+		month := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+		if t.Before(cycleStart(t.Year(), t.Month(), t.Location())) {
+			month = month.AddDate(0, -1, 0)
+		}
+		return month.Format("2006-01")
+	}
+}
+
+// periodBounds は key の期間の始まりと次の期間の始まりを loc で返す。
+func periodBounds(key string, loc *time.Location) (time.Time, time.Time, error) {
+	if yearText, weekText, ok := strings.Cut(key, "-W"); ok {
+		var year, week int
+		if _, err := fmt.Sscanf(yearText+" "+weekText, "%d %d", &year, &week); err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("週のキーを解析できません: %q", key)
+		}
+		// ISO 週の第1週は1月4日を含む週。
+		jan4 := time.Date(year, time.January, 4, 0, 0, 0, 0, loc)
+		monday := jan4.AddDate(0, 0, -((int(jan4.Weekday())+6)%7)+(week-1)*7)
+		return monday, monday.AddDate(0, 0, 7), nil
+	}
+	if start, err := time.ParseInLocation("2006-01-02", key, loc); err == nil {
+		return start, start.AddDate(0, 0, 1), nil
+	}
+	month, err := time.ParseInLocation("2006-01", key, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	next := month.AddDate(0, 1, 0)
+	return cycleStart(month.Year(), month.Month(), loc), cycleStart(next.Year(), next.Month(), loc), nil
+}
+
+// onCycleStart は月ごとの期間が始まる日だけ job を実行する。cron では末日を指定できないので、
+// billing_day が29日以降の既定のスケジュールは月末の数日に毎日発火する。
+func onCycleStart(job func(config *Config)) func(config *Config) {
+	return func(config *Config) {
+		now := config.now()
+		start, _, _ := periodBounds(periodKey("monthly", now), now.Location())
+		if now.Format("2006-01-02") != start.Format("2006-01-02") {
+			return
+		}
+		job(config)
+	}
+}
+
+// averageLabel は期間の平均の通信速度に付ける、period に応じた表記。
+func averageLabel(period string) string {
+	switch period {
+	case "weekly":
+		return tr("（週間平均）")
+	case "daily":
+		return tr("（1日の平均）")
+	default:
+		return tr("（月間平均）")
+	}
+}
+
+func periodLabel(key string) string {
+	start, end, err := periodBounds(key, time.UTC)
+	if err != nil {
+		return key
+	}
+	day := tr("2006年1月2日")
+	switch {
+	case strings.Contains(key, "-W"):
+		return fmt.Sprintf(tr("%sの週"), start.Format(day))
+	case len(key) == len("2006-01-02"):
+		return start.Format(day)
+	case billingDay != 1:
+		last := end.AddDate(0, 0, -1)
+		if last.Year() != start.Year() {
+			return start.Format(day) + tr("〜") + last.Format(day)
+		}
+		return start.Format(day) + tr("〜") + last.Format(tr("1月2日"))
+	default:
+		return start.Format(tr("2006年1月"))
+	}
+}
@@ -0,0 +1,173 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestPeriodKey(t *testing.T) {
+	tests := []struct {
+		period string
+		t      time.Time
+		want   string
+	}{
+		{"monthly", date(2026, time.October, 1), "2026-10"},
+		{"monthly", time.Date(2026, time.October, 31, 23, 59, 59, 0, time.UTC), "2026-10"},
+		{"", date(2026, time.October, 14), "2026-10"},
+		{"daily", time.Date(2026, time.October, 14, 23, 59, 0, 0, time.UTC), "2026-10-14"},
+		{"weekly", date(2026, time.October, 14), "2026-W42"},
+		// ISO 週の年は暦の年と年末年始でずれる。
+		{"weekly", date(2024, time.December, 30), "2025-W01"},
+		{"weekly", date(2021, time.January, 3), "2020-W53"},
+		{"weekly", date(2026, time.January, 1), "2026-W01"},
+		{"weekly", date(2027, time.January, 3), "2026-W53"},
+		{"weekly", date(2027, time.January, 4), "2027-W01"},
+	}
+	for _, tt := range tests {
+		if got := periodKey(tt.period, tt.t); got != tt.want {
+			t.Errorf("periodKey(%q, %s) = %q, want %q", tt.period, tt.t.Format(time.DateTime), got, tt.want)
+		}
+	}
+}
+
+func TestPeriodBounds(t *testing.T) {
+	tests := []struct {
+		key        string
+		start, end time.Time
+	}{
+		{"2026-10", date(2026, time.October, 1), date(2026, time.November, 1)},
+		{"2026-12", date(2026, time.December, 1), date(2027, time.January, 1)},
+		{"2026-10-14", date(2026, time.October, 14), date(2026, time.October, 15)},
+		{"2026-W42", date(2026, time.October, 12), date(2026, time.October, 19)},
+		{"2025-W01", date(2024, time.December, 30), date(2025, time.January, 6)},
+		{"2020-W53", date(2020, time.December, 28), date(2021, time.January, 4)},
+		{"2026-W53", date(2026, time.December, 28), date(2027, time.January, 4)},
+	}
+	for _, tt := range tests {
+		start, end, err := periodBounds(tt.key, time.UTC)
+		if err != nil {
+			t.Errorf("periodBounds(%q): %v", tt.key, err)
+			continue
+		}
+		if !start.Equal(tt.start) || !end.Equal(tt.end) {
+			t.Errorf("periodBounds(%q) = %s, %s; want %s, %s", tt.key, start.Format(time.DateOnly), end.Format(time.DateOnly), tt.start.Format(time.DateOnly), tt.end.Format(time.DateOnly))
+		}
+	}
+
+	for _, key := range []string{"2026-Wxx", "2026/10", "october"} {
+		if _, _, err := periodBounds(key, time.UTC); err == nil {
+			t.Errorf("periodBounds(%q) succeeded", key)
+		}
+	}
+}
+
+// 年をまたぐ2年分の毎日について、その日のキーの期間がその日を含むことを確かめる。
+func TestPeriodKeyWithinBounds(t *testing.T) {
+	for _, period := range []string{"monthly", "weekly", "daily"} {
+		for day := date(2025, time.December, 1); day.Before(date(2027, time.February, 1)); day = day.AddDate(0, 0, 1) {
+			key := periodKey(period, day)
+			start, end, err := periodBounds(key, time.UTC)
+			if err != nil {
+				t.Fatalf("periodBounds(%q): %v", key, err)
+			}
+			if day.Before(start) || !day.Before(end) {
+				t.Errorf("%s: %s is outside %q (%s - %s)", period, day.Format(time.DateOnly), key, start.Format(time.DateOnly), end.Format(time.DateOnly))
+			}
+		}
+	}
+}
+
+// withBillingDay は billingDay をテストの間だけ差し替える。
+func withBillingDay(t *testing.T, day int) {
+	t.Helper()
+	saved := billingDay
+	billingDay = day
+	t.Cleanup(func() { billingDay = saved })
+}
+
+func TestBillingDay(t *testing.T) {
+	tests := []struct {
+		billingDay int
+		t          time.Time
+		key        string
+		start, end time.Time
+	}{
+		{20, date(2026, time.October, 19), "2026-09", date(2026, time.September, 20), date(2026, time.October, 20)},
+		{20, date(2026, time.October, 20), "2026-10", date(2026, time.October, 20), date(2026, time.November, 20)},
+		{20, date(2026, time.January, 5), "2025-12", date(2025, time.December, 20), date(2026, time.January, 20)},
+		// 31日始まりは、31日のない月ではその月の末日に始まる。
+		{31, date(2026, time.February, 27), "2026-01", date(2026, time.January, 31), date(2026, time.February, 28)},
+		{31, date(2026, time.February, 28), "2026-02", date(2026, time.February, 28), date(2026, time.March, 31)},
+		{31, date(2026, time.March, 30), "2026-02", date(2026, time.February, 28), date(2026, time.March, 31)},
+		{31, date(2028, time.February, 28), "2028-01", date(2028, time.January, 31), date(2028, time.February, 29)},
+		{31, date(2028, time.February, 29), "2028-02", date(2028, time.February, 29), date(2028, time.March, 31)},
+		{31, date(2026, time.April, 30), "2026-04", date(2026, time.April, 30), date(2026, time.May, 31)},
+		{30, date(2026, time.February, 28), "2026-02", date(2026, time.February, 28), date(2026, time.March, 30)},
+	}
+	for _, tt := range tests {
+		withBillingDay(t, tt.billingDay)
+		key := periodKey("monthly", tt.t)
+		if key != tt.key {
+			t.Errorf("billing_day %d: periodKey(%s) = %q, want %q", tt.billingDay, tt.t.Format(time.DateOnly), key, tt.key)
+			continue
+		}
+		start, end, err := periodBounds(key, time.UTC)
+		if err != nil || !start.Equal(tt.start) || !end.Equal(tt.end) {
+			t.Errorf("billing_day %d: periodBounds(%q) = %s, %s, %v; want %s, %s", tt.billingDay, key, start.Format(time.DateOnly), end.Format(time.DateOnly), err, tt.start.Format(time.DateOnly), tt.end.Format(time.DateOnly))
+		}
+	}
+}
+
+func TestOnCycleStartInFebruary(t *testing.T) {
+	withBillingDay(t, 31)
+	saved := clock
+	t.Cleanup(func() { clock = saved })
+
+	// 29日以降の既定のスケジュールは月末の数日に発火するので、実行するのは期間の始まる日だけ。
+	tests := []struct {
+		day  time.Time
+		want bool
+	}{
+		{date(2026, time.January, 29), false},
+		{date(2026, time.January, 31), true},
+		{date(2026, time.February, 28), true},
+		{date(2026, time.March, 29), false},
+		{date(2026, time.March, 31), true},
+		{date(2028, time.February, 28), false},
+		{date(2028, time.February, 29), true},
+	}
+	for _, tt := range tests {
+		clock = clockwork.NewFakeClockAt(tt.day.Add(9 * time.Hour))
+		ran := false
+		onCycleStart(func(*Config) { ran = true })(&Config{location: time.UTC})
+		if ran != tt.want {
+			t.Errorf("onCycleStart on %s ran = %v, want %v", tt.day.Format(time.DateOnly), ran, tt.want)
+		}
+	}
+}
+
+func TestAverageLabel(t *testing.T) {
+	saved := language
+	language = "en"
+	t.Cleanup(func() { language = saved })
+
+	tests := []struct {
+		period string
+		want   string
+	}{
+		{"monthly", " (monthly average)"},
+		{"weekly", " (weekly average)"},
+		{"daily", " (daily average)"},
+	}
+	for _, tt := range tests {
+		if got := averageLabel(tt.period); got != tt.want {
+			t.Errorf("averageLabel(%q) = %q, want %q", tt.period, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package app
+
+import (
+	"log/slog"
+	"math/big"
+	"time"
+
+	"github.com/rakku1234/linux-traffic-checker/collector"
+)
+
+func PollNetStats(config *Config) {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+
+	unlock, err := lockStats(config)
+	if err != nil {
+		slog.Error("統計ファイルのロック取得エラー", "error", err)
+		return
+	}
+	defer unlock()
+
+	stats, isFirstRun, err := config.store.Load()
+	if err != nil {
+		slog.Error("統計ファイルの読み込みエラー", "error", err)
+		return
+	}
+	if isFirstRun {
+		return
+	}
+
+	now := config.now()
+	monthKey := periodKey(config.Period, now)
+	retryBudget, _ := time.ParseDuration(config.RetryBudget)
+	budget := newRetryBudget(retryBudget)
+	resendUnsent(config, stats, budget)
+	for _, scope := range interfaceScopes(config, stats) {
+		if scope.created {
+			continue
+		}
+		device, err := resolveInterface(scope.config)
+		var currentRX, currentTX big.Int
+		var perInterface map[string]*collector.InterfaceStats
+		if err == nil {
+			currentRX, currentTX, perInterface, err = readCounters(device)
+		}
+		if err != nil {
+			slog.Error("ネットワーク統計の読み込みエラー", "interface", scope.config.Interface, "error", err)
+			continue
+		}
+
+		slog.Debug("カウンタを読み取りました", "interface", scope.config.Interface, "rx", currentRX.String(), "tx", currentTX.String())
+
+		scoped := scope.stats
+		followDefaultRoute(scope.config, device.Interface, scoped, &currentRX, &currentTX)
+		if perInterface != nil {
+			adjustInterfaceWraps(scope.config, scoped, perInterface)
+			// 消えたインターフェースの最後の読み取りは、レポート時にそれまでの通信量を記録するため残す。
+			if scoped.LastInterfaces == nil {
+				scoped.LastInterfaces = map[string]*collector.InterfaceStats{}
+			}
+			for name, counter := range perInterface {
+				scoped.LastInterfaces[name] = counter
+			}
+		} else {
+			adjustWrap(scope.config, scoped, &scoped.LastRX, &scoped.LastTX, &currentRX, &currentTX)
+		}
+		if !scoped.LastReadAt.IsZero() {
+			recordUsage(scope.config, &scoped.LastRX, &scoped.LastTX, &currentRX, &currentTX, scoped.LastReadAt, now, scope.config.aggregate())
+			writeTimeseries(scope.config, &scoped.LastRX, &scoped.LastTX, &currentRX, &currentTX, scoped.LastReadAt, now, scope.config.aggregate())
+		}
+		// 期間が終わってからレポートが締めるまでの読み取りは、boundary_mode が "job" の場合と同じく終わる期間に数える。
+		if scope.config.accumulates() {
+			accumulateRead(scope.config, scoped, &currentRX, &currentTX, scope.config.aggregate())
+		}
+		scoped.LastRX = currentRX
+		scoped.LastTX = currentTX
+		scoped.LastReadAt = now
+
+		if scoped.Month == monthKey {
+			usedRX, usedTX := periodUsage(scoped, &currentRX, &currentTX)
+			if usedRX.Sign() >= 0 && usedTX.Sign() >= 0 {
+				setCurrentUsage(scope.config, usedRX, usedTX)
+				checkCaps(scope.config, scoped, monthKey, usedRX, usedTX, budget)
+				checkThreshold(scope.config, scoped, usedRX, usedTX, budget)
+				checkQuota(scope.config, scoped, monthKey, usedRX, usedTX, budget)
+				checkQuotaHook(scope.config, scoped, usedRX, usedTX)
+				checkQuotaActions(scope.config, scoped, monthKey, usedRX, usedTX, budget)
+			}
+		}
+	}
+
+	err = config.store.Save(stats)
+	if err != nil {
+		slog.Error("統計ファイルの保存エラー", "error", err)
+	}
+}
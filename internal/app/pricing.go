@@ -0,0 +1,82 @@
+package app
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/rakku1234/linux-traffic-checker/notify"
+)
+
+// Pricing は無料分を超えた分に GB ごとに課金するプラン。
+type Pricing struct {
+	// 通貨記号（例 "¥"、"$"）。
+	Currency string `json:"currency"`
+	// 料金なしで使える通信量（GB。1 GB = 10^9 バイト）。
+	IncludedGB float64 `json:"included_gb"`
+	// 超過した1 GBあたりの料金。
+	PricePerGB float64 `json:"price_per_gb"`
+	// 料金の小数点以下の桁数（既定 0）。
+	Decimals int `json:"decimals"`
+}
+
+const bytesPerGB = 1e9
+
+func validatePricing(pricing *Pricing) error {
+	if pricing == nil {
+		return nil
+	}
+	if pricing.PricePerGB <= 0 {
+		return fmt.Errorf("pricing.price_per_gb は0より大きい値を指定してください: %v", pricing.PricePerGB)
+	}
+	if pricing.IncludedGB < 0 {
+		return fmt.Errorf("pricing.included_gb は0以上を指定してください: %v", pricing.IncludedGB)
+	}
+	if pricing.Decimals < 0 || pricing.Decimals > 4 {
+		return fmt.Errorf("pricing.decimals は0から4の範囲で指定してください: %d", pricing.Decimals)
+	}
+	return nil
+}
+
+// overageCost は used のうち無料分を超えた分の料金。
+func (pricing *Pricing) overageCost(used *big.Int) float64 {
+	gb, _ := new(big.Float).Quo(new(big.Float).SetInt(used), big.NewFloat(bytesPerGB)).Float64()
+	return math.Max(0, gb-pricing.IncludedGB) * pricing.PricePerGB
+}
+
+// format は amount を通貨記号と3桁ごとの区切りを付けて書く（例 "¥1,240"）。
+func (pricing *Pricing) format(amount float64) string {
+	text := strconv.FormatFloat(amount, 'f', pricing.Decimals, 64)
+	whole, fraction, _ := strings.Cut(text, ".")
+	var b strings.Builder
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(digit)
+	}
+	if fraction != "" {
+		b.WriteString("." + fraction)
+	}
+	return pricing.Currency + b.String()
+}
+
+// pricingField はこれまでの通信量の超過料金を表示する。show_forecast を指定した集計中の期間なら、
+// 期間の終わりまでの予測の料金も併せて表示する。
+func pricingField(config *Config, record *PeriodRecord) notify.EmbedField {
+	pricing := config.Pricing
+	current := pricing.format(pricing.overageCost(countedTotal(config, &record.RX, &record.TX)))
+	_, end, err := periodBounds(record.Month, config.location)
+	if err != nil || !config.now().Before(end) {
+		return notify.EmbedField{Name: tr("超過料金"), Value: current, Inline: false}
+	}
+	if config.ShowForecast {
+		if projected, ok := forecastUsage(config, record, config.now()); ok {
+			value := fmt.Sprintf(tr("%s（現時点 %s）"), pricing.format(pricing.overageCost(projected)), current)
+			return notify.EmbedField{Name: tr("超過料金見込み"), Value: value, Inline: false}
+		}
+	}
+	return notify.EmbedField{Name: tr("超過料金（現時点）"), Value: current, Inline: false}
+}
@@ -0,0 +1,114 @@
+package app
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/rakku1234/linux-traffic-checker/notify"
+)
+
+// NtfyNotifier は通知先 "ntfy" で、レポートとアラートを ntfy のトピックに送り、ntfy のアプリがプッシュ通知で表示する。
+type NtfyNotifier struct {
+	config *Config
+}
+
+func (n *NtfyNotifier) Send(report Report) error {
+	style := messageStyle(n.config, "ntfy", notify.KindReport)
+	return n.post(notify.KindReport, style.title(reportTitle(n.config, &report)), reportLines(n.config, &report))
+}
+
+func (n *NtfyNotifier) SendEmbeds(kind notify.Kind, embeds []notify.Embed) error {
+	style := messageStyle(n.config, "ntfy", kind)
+	for _, embed := range embeds {
+		err := n.post(kind, style.title(embedTitle(embed)), embedLines(embed))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *NtfyNotifier) post(kind notify.Kind, title string, lines []string) error {
+	if n.config.NtfyTopic == "" {
+		slog.Warn("ntfy_topic が未設定のためntfy通知をスキップします")
+		return nil
+	}
+	ntfy := notify.Ntfy{
+		URL:           n.config.NtfyURL,
+		Topic:         n.config.NtfyTopic,
+		Token:         n.config.NtfyToken,
+		Priority:      n.config.NtfyPriority,
+		AlertPriority: n.config.NtfyAlertPriority,
+		Client:        httpClient,
+	}
+	if dryRun {
+		slog.Info("[dry-run] ntfyへの送信をスキップします", "title", title, "priority", ntfy.KindPriority(kind))
+		return nil
+	}
+	return ntfy.Post(kind, title, limitText(n.config, "ntfy", strings.Join(lines, "\n")))
+}
+
+// GotifyNotifier は通知先 "gotify" で、レポートとアラートをトークンが gotify_token の Gotify のアプリケーションのメッセージとして送る。
+type GotifyNotifier struct {
+	config *Config
+}
+
+func (n *GotifyNotifier) Send(report Report) error {
+	style := messageStyle(n.config, "gotify", notify.KindReport)
+	return n.post(notify.KindReport, style.title(reportTitle(n.config, &report)), reportLines(n.config, &report))
+}
+
+func (n *GotifyNotifier) SendEmbeds(kind notify.Kind, embeds []notify.Embed) error {
+	style := messageStyle(n.config, "gotify", kind)
+	for _, embed := range embeds {
+		err := n.post(kind, style.title(embedTitle(embed)), embedLines(embed))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *GotifyNotifier) post(kind notify.Kind, title string, lines []string) error {
+	if n.config.GotifyURL == "" || n.config.GotifyToken == "" {
+		slog.Warn("gotify_url または gotify_token が未設定のためGotify通知をスキップします")
+		return nil
+	}
+	gotify := notify.Gotify{
+		URL:           n.config.GotifyURL,
+		Token:         n.config.GotifyToken,
+		Priority:      n.config.GotifyPriority,
+		AlertPriority: n.config.GotifyAlertPriority,
+		Client:        httpClient,
+	}
+	if dryRun {
+		slog.Info("[dry-run] Gotifyへの送信をスキップします", "title", title, "priority", gotify.KindPriority(kind))
+		return nil
+	}
+	return gotify.Post(kind, title, limitText(n.config, "gotify", strings.Join(lines, "\n")))
+}
+
+func validatePush(config *Config) error {
+	if config.usesNotifier("ntfy") && config.NtfyTopic == "" {
+		return fmt.Errorf("notifier ntfy を使うには ntfy_topic を指定してください")
+	}
+	if config.usesNotifier("gotify") && (config.GotifyURL == "" || config.GotifyToken == "") {
+		return fmt.Errorf("notifier gotify を使うには gotify_url と gotify_token を指定してください")
+	}
+	for _, priority := range []struct {
+		name     string
+		value    int
+		min, max int
+	}{
+		{"ntfy_priority", config.NtfyPriority, 1, 5},
+		{"ntfy_alert_priority", config.NtfyAlertPriority, 1, 5},
+		{"gotify_priority", config.GotifyPriority, 1, 10},
+		{"gotify_alert_priority", config.GotifyAlertPriority, 1, 10},
+	} {
+		if priority.value < priority.min || priority.value > priority.max {
+			return fmt.Errorf("%s は%dから%dの間で指定してください: %d", priority.name, priority.min, priority.max, priority.value)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package app
+
+import (
+	"fmt"
+	"log/slog"
+	"math/big"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/rakku1234/linux-traffic-checker/notify"
+)
+
+var defaultAlertThresholds = []float64{50, 80, 95}
+
+func quotaPercent(used *big.Int, quota int64) float64 {
+	percent, _ := new(big.Float).Quo(new(big.Float).SetInt(used), big.NewFloat(float64(quota))).Float64()
+	return percent * 100
+}
+
+func quotaKey(threshold float64) string {
+	return strconv.FormatFloat(threshold, 'f', -1, 64)
+}
+
+func quotaAlertEmbed(config *Config, used *big.Int, threshold float64) notify.Embed {
+	quota := big.NewInt(config.QuotaBytes)
+	remaining := new(big.Int).Sub(quota, used)
+	if remaining.Sign() < 0 {
+		remaining.SetInt64(0)
+	}
+	return notify.Embed{
+		Title:     fmt.Sprintf(tr("%s の通信量が上限の %s%% に達しました"), interfaceDisplayName(config), quotaKey(threshold)),
+		Color:     0xffa500,
+		Timestamp: clock.Now().UTC().Format(time.RFC3339),
+		Fields: []notify.EmbedField{
+			{Name: tr("今月の使用量"), Value: fmt.Sprintf(tr("%s / %s（%.1f%%）"), formatBytes(used), formatBytes(quota), quotaPercent(used, config.QuotaBytes)), Inline: false},
+			{Name: tr("残り"), Value: formatBytes(remaining), Inline: true},
+		},
+	}
+}
+
+// checkQuota は集計した合計が quota_bytes の alert_thresholds パーセントのそれぞれに期間中初めて届いたときに知らせる。
+// 1回の読み取りで複数のしきい値を超えた場合は最も高いものだけを送り、すべてを通知済みにする。
+func checkQuota(config *Config, stats *Stats, monthKey string, usedRX, usedTX *big.Int, budget *RetryBudget) {
+	if config.QuotaBytes <= 0 {
+		return
+	}
+	used := countedTotal(config, usedRX, usedTX)
+	percent := quotaPercent(used, config.QuotaBytes)
+
+	var crossed []float64
+	for _, threshold := range config.AlertThresholds {
+		if percent >= threshold && stats.QuotaAlerts[quotaKey(threshold)] != monthKey {
+			crossed = append(crossed, threshold)
+		}
+	}
+	if len(crossed) == 0 {
+		return
+	}
+
+	highest := slices.Max(crossed)
+	err := notifyAll(config, budget, func(notifier Notifier) error {
+		return notifier.SendEmbeds(notify.KindAlert, []notify.Embed{quotaAlertEmbed(config, used, highest)})
+	})
+	if err != nil {
+		slog.Error("通知の送信エラー", "error", err)
+		return
+	}
+
+	if stats.QuotaAlerts == nil {
+		stats.QuotaAlerts = map[string]string{}
+	}
+	for _, threshold := range crossed {
+		stats.QuotaAlerts[quotaKey(threshold)] = monthKey
+	}
+	slog.Warn("通信量が上限の割合に達したことを通知しました", "interface", config.Interface, "threshold_percent", highest, "used", used.String())
+}
@@ -0,0 +1,111 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"time"
+)
+
+var errCounterWentBackwards = errors.New("サンプル間でカウンタが減少しました")
+
+// processStart はサンプルの経過時間の基準。
+var processStart = time.Now()
+
+// sinceStart は processStart からの経過時間を返す。time.Since はモノトニック時刻で
+// 計算するので、壁時計がNTPや手動の変更で動いても値は前後しない。
+func sinceStart() time.Duration {
+	return time.Since(processStart)
+}
+
+// CounterSample はある時点で読み取った受信・送信のカウンタ。
+// Elapsed は読み取った時点の sinceStart の値で、サンプル間の間隔は壁時計の差ではなく
+// この差で計算する。同じプロセスの中でしか比べられないため、サンプルは保存しないこと。
+// Interface は interface が "auto" の場合に読み取ったインターフェース。
+type CounterSample struct {
+	RX        big.Int
+	TX        big.Int
+	Elapsed   time.Duration
+	Interface string
+}
+
+func takeSample(config *Config) (CounterSample, error) {
+	device, err := resolveInterface(config)
+	if err != nil {
+		return CounterSample{}, err
+	}
+	rx, tx, _, err := readCountersOnce(device)
+	if err != nil {
+		return CounterSample{}, err
+	}
+	return CounterSample{RX: rx, TX: tx, Elapsed: sinceStart(), Interface: device.Interface}, nil
+}
+
+func sampleRate(before, after *CounterSample) (*big.Float, *big.Float, error) {
+	elapsed := after.Elapsed - before.Elapsed
+	if elapsed <= 0 {
+		return nil, nil, errors.New("サンプル間隔が0以下です")
+	}
+
+	deltaRX := new(big.Int).Sub(&after.RX, &before.RX)
+	deltaTX := new(big.Int).Sub(&after.TX, &before.TX)
+	// デフォルトルートが別のインターフェースに移った間隔も、カウンタが減った場合と同じく数えない。
+	if deltaRX.Sign() < 0 || deltaTX.Sign() < 0 || before.Interface != after.Interface {
+		return nil, nil, errCounterWentBackwards
+	}
+
+	seconds := big.NewFloat(elapsed.Seconds())
+	rxRate := new(big.Float).Quo(new(big.Float).SetInt(deltaRX), seconds)
+	txRate := new(big.Float).Quo(new(big.Float).SetInt(deltaTX), seconds)
+	return rxRate, txRate, nil
+}
+
+func formatSpeed(bytesPerSecond *big.Float) string {
+	bytes, _ := bytesPerSecond.Int(nil)
+	return fmt.Sprintf("%s/s（%s）", formatBytes(bytes), formatRate(bytesPerSecond))
+}
+
+// runSpeed は監視するインターフェースごとの通信速度を interval ごとに count 回（0なら ctx が終わるまで）表示する。
+// カウンタが減った間隔は、負の速度を表示せずに飛ばす。
+func runSpeed(ctx context.Context, w io.Writer, config *Config, interval time.Duration, count int) error {
+	scopes := interfaceScopes(config, &Stats{})
+	before := make([]CounterSample, len(scopes))
+	for i, scope := range scopes {
+		sample, err := takeSample(scope.config)
+		if err != nil {
+			return err
+		}
+		before[i] = sample
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for n := 0; count == 0 || n < count; n++ {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		for i, scope := range scopes {
+			after, err := takeSample(scope.config)
+			if err != nil {
+				return err
+			}
+			name := interfaceDisplayName(scope.config)
+			rx, tx, err := sampleRate(&before[i], &after)
+			before[i] = after
+			if errors.Is(err, errCounterWentBackwards) {
+				fmt.Fprintf(w, "%s  カウンタが減少したため、この区間をスキップします\n", name)
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "%s  %s %s  %s %s\n", name, fieldLabel(config, "rx"), formatSpeed(rx), fieldLabel(config, "tx"), formatSpeed(tx))
+		}
+	}
+	return nil
+}
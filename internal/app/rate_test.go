@@ -0,0 +1,64 @@
+package app
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func sample(rx, tx int64, elapsed time.Duration, name string) *CounterSample {
+	s := &CounterSample{Elapsed: elapsed, Interface: name}
+	s.RX.SetInt64(rx)
+	s.TX.SetInt64(tx)
+	return s
+}
+
+func TestSampleRate(t *testing.T) {
+	tests := []struct {
+		name           string
+		before, after  *CounterSample
+		wantRX, wantTX float64
+		wantErr        error
+	}{
+		{"1秒", sample(1000, 2000, time.Second, "eth0"), sample(126_000, 4000, 2*time.Second, "eth0"), 125_000, 2000, nil},
+		{"10秒", sample(0, 0, 0, "eth0"), sample(10_000, 5, 10*time.Second, "eth0"), 1000, 0.5, nil},
+		{"500ミリ秒", sample(0, 0, time.Second, "eth0"), sample(500, 100, 1500*time.Millisecond, "eth0"), 1000, 200, nil},
+		{"変化なし", sample(7, 7, time.Second, "eth0"), sample(7, 7, 3*time.Second, "eth0"), 0, 0, nil},
+		// 64ビットを超えても big で計算する。
+		{"大きな値", sample(0, 0, 0, "eth0"), &CounterSample{RX: *new(big.Int).Lsh(big.NewInt(1), 70), Elapsed: 1024 * time.Second, Interface: "eth0"}, 1 << 60, 0, nil},
+		{"受信の減少", sample(1000, 0, 0, "eth0"), sample(10, 50, time.Second, "eth0"), 0, 0, errCounterWentBackwards},
+		{"送信の減少", sample(0, 1000, 0, "eth0"), sample(50, 10, time.Second, "eth0"), 0, 0, errCounterWentBackwards},
+		{"インターフェースの切り替え", sample(0, 0, 0, "eth0"), sample(50, 10, time.Second, "wlan0"), 0, 0, errCounterWentBackwards},
+	}
+	for _, tt := range tests {
+		rx, tx, err := sampleRate(tt.before, tt.after)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		gotRX, _ := rx.Float64()
+		gotTX, _ := tx.Float64()
+		if gotRX != tt.wantRX || gotTX != tt.wantTX {
+			t.Errorf("%s: sampleRate = %v/%v B/s, want %v/%v", tt.name, gotRX, gotTX, tt.wantRX, tt.wantTX)
+		}
+	}
+}
+
+func TestSampleRateInterval(t *testing.T) {
+	// 間隔は Elapsed の差だけで決まるので、同じ時点や逆順のサンプルは誤りにする。
+	for _, elapsed := range []time.Duration{time.Second, 500 * time.Millisecond} {
+		if _, _, err := sampleRate(sample(0, 0, time.Second, "eth0"), sample(100, 100, elapsed, "eth0")); err == nil || errors.Is(err, errCounterWentBackwards) {
+			t.Errorf("elapsed %s: err = %v, want an interval error", elapsed, err)
+		}
+	}
+
+	first := sinceStart()
+	time.Sleep(time.Millisecond)
+	if second := sinceStart(); second <= first {
+		t.Errorf("sinceStart went from %s to %s", first, second)
+	}
+}
@@ -0,0 +1,128 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/rakku1234/linux-traffic-checker/notify"
+)
+
+// rateWatch は1つのインターフェースの通信速度の確認の状態。メモリにしか持たないので、再起動後は最初から確認する。
+type rateWatch struct {
+	before CounterSample
+	// highSince と lastAlert は CounterSample.Elapsed の値で、0 ならまだない。
+	highSince time.Duration
+	lastAlert time.Duration
+	alerted   bool
+}
+
+var (
+	rateMu      sync.Mutex
+	rateWatches = map[string]*rateWatch{}
+)
+
+// countedRate はサンプルの間隔の count_direction で数える方向のバイト毎秒を返す。
+func countedRate(config *Config, rx, tx *big.Float) *big.Float {
+	total := new(big.Float)
+	switch config.CountDirection {
+	case "rx":
+		total.Set(rx)
+	case "tx":
+		total.Set(tx)
+	default:
+		total.Add(rx, tx)
+	}
+	return total
+}
+
+// countedMbps は countedRate をメガビット毎秒に換算する。
+func countedMbps(config *Config, rx, tx *big.Float) float64 {
+	mbps, _ := new(big.Float).Mul(countedRate(config, rx, tx), big.NewFloat(8.0/1e6)).Float64()
+	return mbps
+}
+
+func rateAlertEmbed(config *Config, name string, rx, tx *big.Float, high time.Duration) notify.Embed {
+	return notify.Embed{
+		Title:     fmt.Sprintf(tr("%s の通信速度が %g Mbps を超えています"), name, config.RateAlertMbps),
+		Color:     0xff0000,
+		Timestamp: clock.Now().UTC().Format(time.RFC3339),
+		Fields: []notify.EmbedField{
+			{Name: tr("現在の速度"), Value: formatRate(countedRate(config, rx, tx)), Inline: true},
+			{Name: tr("継続時間"), Value: high.Truncate(time.Second).String(), Inline: true},
+			{Name: fieldLabel(config, "rx"), Value: formatSpeed(rx), Inline: false},
+			{Name: fieldLabel(config, "tx"), Value: formatSpeed(tx), Inline: false},
+		},
+	}
+}
+
+// CheckRate は rate_sample_interval ごとにカウンタを読み取り、通信速度が rate_alert_duration の間
+// rate_alert_mbps 以上のままならアラートを送る。高いままの間は rate_alert_cooldown に1回まで繰り返す。
+func CheckRate(config *Config) {
+	rateMu.Lock()
+	defer rateMu.Unlock()
+
+	duration, _ := time.ParseDuration(config.RateAlertDuration)
+	cooldown, _ := time.ParseDuration(config.RateAlertCooldown)
+
+	var embeds []notify.Embed
+	for _, scope := range interfaceScopes(config, &Stats{}) {
+		name := interfaceDisplayName(scope.config)
+		after, err := takeSample(scope.config)
+		if err != nil {
+			slog.Error("ネットワーク統計の読み込みエラー", "interface", name, "error", err)
+			continue
+		}
+		watch, ok := rateWatches[name]
+		if !ok {
+			rateWatches[name] = &rateWatch{before: after}
+			continue
+		}
+
+		rx, tx, err := sampleRate(&watch.before, &after)
+		watch.before = after
+		if errors.Is(err, errCounterWentBackwards) {
+			watch.highSince = 0
+			continue
+		}
+		if err != nil {
+			slog.Error("通信速度の計算エラー", "interface", name, "error", err)
+			continue
+		}
+
+		mbps := countedMbps(config, rx, tx)
+		if mbps < config.RateAlertMbps {
+			if watch.alerted {
+				slog.Info("通信速度がしきい値を下回りました", "interface", name, "mbps", mbps)
+			}
+			watch.highSince = 0
+			watch.alerted = false
+			continue
+		}
+		if watch.highSince == 0 {
+			watch.highSince = after.Elapsed
+		}
+		high := after.Elapsed - watch.highSince
+		if high < duration || (watch.lastAlert != 0 && after.Elapsed-watch.lastAlert < cooldown) {
+			continue
+		}
+		slog.Warn("通信速度の超過が続いています", "interface", name, "mbps", mbps, "duration", high)
+		embeds = append(embeds, rateAlertEmbed(config, name, rx, tx, high))
+		watch.lastAlert = after.Elapsed
+		watch.alerted = true
+	}
+	if len(embeds) == 0 {
+		return
+	}
+
+	retryBudget, _ := time.ParseDuration(config.RetryBudget)
+	err := notifyAll(config, newRetryBudget(retryBudget), func(notifier Notifier) error {
+		return notifier.SendEmbeds(notify.KindAlert, embeds)
+	})
+	if err != nil {
+		slog.Error("通知の送信エラー", "error", err)
+	}
+}
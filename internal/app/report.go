@@ -0,0 +1,59 @@
+package app
+
+import (
+	"encoding/json"
+	"math/big"
+	"time"
+
+	"github.com/rakku1234/linux-traffic-checker/notify"
+	"github.com/rakku1234/linux-traffic-checker/store"
+)
+
+type Report struct {
+	Interface   string    `json:"interface"`
+	Period      string    `json:"period"`
+	PeriodLabel string    `json:"period_label"`
+	RX          *big.Int  `json:"rx_bytes"`
+	TX          *big.Int  `json:"tx_bytes"`
+	Total       *big.Int  `json:"total_bytes"`
+	RXText      string    `json:"rx"`
+	TXText      string    `json:"tx"`
+	TotalText   string    `json:"total"`
+	GeneratedAt time.Time `json:"generated_at"`
+	// GeneratedAtUnix はエポック秒を使うツールのための、GeneratedAt の Unix 時刻（秒）。
+	GeneratedAtUnix int64 `json:"generated_at_unix"`
+	// Breakdown は複数のインターフェースを監視する場合のインターフェースごとのレポートで、
+	// そのときレポート自体はそれらの合計になる。
+	Breakdown []Report `json:"interfaces,omitempty"`
+
+	// embeds と files は Discord 向けに表示したレポートで、上限に対する使用量など、
+	// 他の通知先では表示しない詳細も含む。
+	embeds []notify.Embed
+	files  []notify.Attachment
+}
+
+func newReport(config *Config, record *PeriodRecord) *Report {
+	total := countedTotal(config, &record.RX, &record.TX)
+	now := clock.Now()
+	return &Report{
+		Interface:       interfaceDisplayName(config),
+		Period:          record.Month,
+		PeriodLabel:     periodLabel(record.Month),
+		RX:              &record.RX,
+		TX:              &record.TX,
+		Total:           total,
+		RXText:          formatBytes(&record.RX),
+		TXText:          formatBytes(&record.TX),
+		TotalText:       formatBytes(total),
+		GeneratedAt:     now,
+		GeneratedAtUnix: now.Unix(),
+	}
+}
+
+func writeReportFile(path string, report *Report) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return store.WriteFileAtomic(path, append(data, '\n'), 0644)
+}
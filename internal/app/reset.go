@@ -0,0 +1,250 @@
+package app
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rakku1234/linux-traffic-checker/collector"
+)
+
+// bootTime は /proc/stat からシステムの起動時刻を読み取る。
+func bootTime() (time.Time, error) {
+	file, err := os.Open("/proc/stat")
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		value, ok := strings.CutPrefix(scanner.Text(), "btime ")
+		if !ok {
+			continue
+		}
+		seconds, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("/proc/stat の btime を解析できません: %q", value)
+		}
+		return time.Unix(seconds, 0), nil
+	}
+	if err := scanner.Err(); err != nil {
+		return time.Time{}, err
+	}
+	return time.Time{}, fmt.Errorf("/proc/stat に btime がありません")
+}
+
+// rebootedSince は t の後にシステムが起動したかどうかを返す。counter_command のカウンタは別のホストのもの
+// かもしれないので、このホストの再起動のせいにはしない。SNMP で読んだカウンタは機器の sysUpTime で判断する。
+func rebootedSince(config *Config, t time.Time) bool {
+	if t.IsZero() || config.CounterCommand != "" {
+		return false
+	}
+	if config.SNMPHost != "" {
+		booted, ok := snmpBootTime(config.SNMPHost)
+		return ok && booted.After(t)
+	}
+	booted, err := bootTime()
+	if err != nil {
+		slog.Debug("起動時刻を読み込めません", "error", err)
+		return false
+	}
+	return booted.After(t)
+}
+
+// carryOverReset は前回の読み取りの後にリセットされたカウンタを扱う。カウンタがベースラインを下回った場合、
+// 1つのインターフェースのカウンタが前回の読み取りから減った場合（32ビットの桁あふれはこれより前に補正する）、
+// または前回の読み取りの後にシステムが再起動した場合をリセットとする。期間の合計がリセットで失われないよう、
+// 前回の読み取りまでの通信量を Carried に加える。再起動後はカウンタが0から始まるので新しいベースラインは0、
+// それ以外は今読み取った値にする。
+func carryOverReset(config *Config, stats *Stats, currentRX, currentTX *big.Int, aggregate bool) bool {
+	rebooted := rebootedSince(config, stats.LastReadAt)
+	backwards := currentRX.Cmp(&stats.RX) < 0 || currentTX.Cmp(&stats.TX) < 0
+	if !aggregate && !stats.LastReadAt.IsZero() {
+		backwards = backwards || currentRX.Cmp(&stats.LastRX) < 0 || currentTX.Cmp(&stats.LastTX) < 0
+	}
+	if !backwards && !rebooted {
+		return false
+	}
+
+	if stats.Carried == nil {
+		stats.Carried = &collector.InterfaceStats{}
+	}
+	if !stats.LastReadAt.IsZero() {
+		for _, counter := range []struct{ carried, last, baseline *big.Int }{
+			{&stats.Carried.RX, &stats.LastRX, &stats.RX},
+			{&stats.Carried.TX, &stats.LastTX, &stats.TX},
+		} {
+			if used := new(big.Int).Sub(counter.last, counter.baseline); used.Sign() > 0 {
+				counter.carried.Add(counter.carried, used)
+			}
+		}
+	}
+
+	if rebooted {
+		stats.RX.SetInt64(0)
+		stats.TX.SetInt64(0)
+	} else {
+		stats.RX.Set(currentRX)
+		stats.TX.Set(currentTX)
+	}
+	slog.Warn("カウンタのリセットを検出したため、それまでの通信量を引き継いで集計を続けます", "interface", config.Interface, "rebooted", rebooted,
+		"carried_rx", stats.Carried.RX.String(), "carried_tx", stats.Carried.TX.String())
+	return true
+}
+
+// periodUsage は今期の通信量で、ベースラインからのカウンタの増分にリセット前から引き継いだ分を加えたもの。
+// 積算している場合は、積算した合計に前回の読み取りからの増分を加えたもの。
+func periodUsage(stats *Stats, currentRX, currentTX *big.Int) (*big.Int, *big.Int) {
+	if stats.Accumulated != nil {
+		usedRX := new(big.Int).Set(&stats.Accumulated.RX)
+		usedTX := new(big.Int).Set(&stats.Accumulated.TX)
+		if pending := new(big.Int).Sub(currentRX, &stats.LastRX); pending.Sign() > 0 {
+			usedRX.Add(usedRX, pending)
+		}
+		if pending := new(big.Int).Sub(currentTX, &stats.LastTX); pending.Sign() > 0 {
+			usedTX.Add(usedTX, pending)
+		}
+		return usedRX, usedTX
+	}
+	usedRX := new(big.Int).Sub(currentRX, &stats.RX)
+	usedTX := new(big.Int).Sub(currentTX, &stats.TX)
+	if stats.Carried != nil {
+		usedRX.Add(usedRX, &stats.Carried.RX)
+		usedTX.Add(usedTX, &stats.Carried.TX)
+	}
+	return usedRX, usedTX
+}
+
+func resetBaseline(config *Config, stats *Stats) error {
+	// nftables のカウンタはインターフェースごとではないので、統計全体で1つのベースラインを取り直す。
+	stats.NftablesBaseline = nil
+	if _, _, err := nftablesUsage(config, stats, periodKey(config.Period, config.now())); err != nil {
+		slog.Warn("nftablesカウンタの読み込みエラー", "error", err)
+	}
+	for _, scope := range interfaceScopes(config, stats) {
+		err := resetInterfaceBaseline(scope.config, scope.stats)
+		if err != nil {
+			if !config.multiInterface() {
+				return err
+			}
+			slog.Warn("ネットワーク統計を読み込めないため、このインターフェースのリセットをスキップします", "interface", scope.config.Interface, "error", err)
+		}
+	}
+	return nil
+}
+
+func resetInterfaceBaseline(config *Config, stats *Stats) error {
+	device, err := resolveInterface(config)
+	if err != nil {
+		return err
+	}
+	currentRX, currentTX, perInterface, err := readCounters(device)
+	if err != nil {
+		return err
+	}
+	if config.Interface == autoInterface {
+		stats.AutoInterface = device.Interface
+	}
+
+	now := config.now()
+	stats.Month = periodKey(config.Period, now)
+	stats.RX = currentRX
+	stats.TX = currentTX
+	stats.Carried = nil
+	stats.Accumulated = nil
+	if config.accumulates() {
+		stats.Accumulated = &collector.InterfaceStats{}
+	}
+	stats.Interfaces = perInterface
+	stats.Archived = nil
+	// 前回の読み取りも今に揃え、次の読み取りの増分にリセット前の通信量が入らないようにする。
+	stats.LastRX.Set(&currentRX)
+	stats.LastTX.Set(&currentTX)
+	stats.LastReadAt = now
+	stats.LastInterfaces = perInterface
+	stats.PacketBaseline = nil
+	periodPackets(config, stats, true)
+	return nil
+}
+
+func CheckResetTrigger(config *Config) {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+
+	if _, err := os.Stat(config.ResetTriggerFile); err != nil {
+		return
+	}
+
+	unlock, err := lockStats(config)
+	if err != nil {
+		slog.Error("統計ファイルのロック取得エラー", "error", err)
+		return
+	}
+	defer unlock()
+
+	stats, _, err := config.store.Load()
+	if err != nil {
+		slog.Error("統計ファイルの読み込みエラー", "error", err)
+		return
+	}
+
+	err = resetBaseline(config, stats)
+	if err != nil {
+		slog.Error("ネットワーク統計の読み込みエラー", "error", err)
+		return
+	}
+	err = config.store.Save(stats)
+	if err != nil {
+		slog.Error("統計ファイルの保存エラー", "error", err)
+		return
+	}
+
+	err = os.Remove(config.ResetTriggerFile)
+	if err != nil {
+		slog.Error("リセット用トリガーファイルの削除に失敗", "path", config.ResetTriggerFile, "error", err)
+	}
+	slog.Info("トリガーファイルを検出したため、今月の集計をリセットしました", "path", config.ResetTriggerFile)
+}
+
+func ApplyScheduledReset(config *Config) {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	if config.NextResetAt == nil || clock.Now().Before(*config.NextResetAt) {
+		return
+	}
+
+	unlock, err := lockStats(config)
+	if err != nil {
+		slog.Error("統計ファイルのロック取得エラー", "error", err)
+		return
+	}
+	defer unlock()
+
+	stats, _, err := config.store.Load()
+	if err != nil {
+		slog.Error("統計ファイルの読み込みエラー", "error", err)
+		return
+	}
+	if stats.AppliedResetAt.Equal(*config.NextResetAt) {
+		return
+	}
+
+	err = resetBaseline(config, stats)
+	if err != nil {
+		slog.Error("ネットワーク統計の読み込みエラー", "error", err)
+		return
+	}
+	stats.AppliedResetAt = *config.NextResetAt
+	err = config.store.Save(stats)
+	if err != nil {
+		slog.Error("統計ファイルの保存エラー", "error", err)
+		return
+	}
+	slog.Info("指定日時になったため、今月の集計をリセットしました", "next_reset_at", config.NextResetAt.Format(time.RFC3339))
+}
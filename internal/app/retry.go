@@ -0,0 +1,107 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/rakku1234/linux-traffic-checker/notify"
+)
+
+const maxAttempts = 3
+
+type RetryBudget struct {
+	mu        sync.Mutex
+	remaining time.Duration
+}
+
+func newRetryBudget(total time.Duration) *RetryBudget {
+	return &RetryBudget{remaining: total}
+}
+
+// spend は試行にかかった時間を予算から差し引き、まだ残っているかどうかを返す。
+func (b *RetryBudget) spend(elapsed time.Duration) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.remaining = max(b.remaining-elapsed, 0)
+	return b.remaining > 0
+}
+
+func (b *RetryBudget) take(wait time.Duration) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.remaining < wait {
+		b.remaining = 0
+		return false
+	}
+	b.remaining -= wait
+	return true
+}
+
+// PermanentError は再試行しても直らない送信の失敗。
+type PermanentError struct {
+	Target string
+	Err    error
+}
+
+func (e *PermanentError) Error() string {
+	return fmt.Sprintf("%s: 再試行できないエラーです: %v", e.Target, e.Err)
+}
+
+func (e *PermanentError) Unwrap() error { return e.Err }
+
+// RetriesExhaustedError はすべての試行が再試行できるエラーで失敗したか、次の試行の前にリトライ予算を使い切ったこと。
+type RetriesExhaustedError struct {
+	Target   string
+	Attempts int
+	Err      error
+}
+
+func (e *RetriesExhaustedError) Error() string {
+	return fmt.Sprintf("%s: %d回試行しても送信できませんでした: %v", e.Target, e.Attempts, e.Err)
+}
+
+func (e *RetriesExhaustedError) Unwrap() error { return e.Err }
+
+// withRetry は fn を最大 maxAttempts 回呼び、その間はおよそ1秒、2秒…、またはサーバーの Retry-After の分だけ待つ。
+// 再試行できないHTTPのエラーでは、すぐに PermanentError で止める。待ち時間だけでなく試行にかかった時間も
+// リトライ予算から差し引くので、応答の遅い送信先でも予算を大きく超えて続けることはない。
+func withRetry(budget *RetryBudget, name string, fn func() error) error {
+	backoff := time.Second
+	for attempt := 1; ; attempt++ {
+		start := clock.Now()
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		remains := budget.spend(clock.Since(start))
+
+		var statusErr *notify.HTTPStatusError
+		if errors.As(err, &statusErr) && !statusErr.Retryable() {
+			return &PermanentError{Target: name, Err: err}
+		}
+		if attempt == maxAttempts {
+			return &RetriesExhaustedError{Target: name, Attempts: attempt, Err: err}
+		}
+		if !remains {
+			return &RetriesExhaustedError{Target: name, Attempts: attempt, Err: fmt.Errorf("リトライ予算を使い切りました: %w", err)}
+		}
+
+		// 同時に失敗したインスタンスが揃って再試行しないよう、最大4分の1のゆらぎを加える。
+		wait := backoff + rand.N(backoff/4)
+		if statusErr != nil && statusErr.RetryAfter > 0 {
+			wait = statusErr.RetryAfter
+		}
+		if !budget.take(wait) {
+			return &RetriesExhaustedError{Target: name, Attempts: attempt, Err: fmt.Errorf("リトライ予算を使い切りました: %w", err)}
+		}
+		slog.Warn("送信に失敗したため再試行します", "target", name, "attempt", attempt, "wait", wait, "error", err)
+		<-clock.After(wait)
+		backoff *= 2
+	}
+}
@@ -0,0 +1,157 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/rakku1234/linux-traffic-checker/notify"
+)
+
+// statusServer は呼ばれるたびに statuses を順に返すサーバーを立てる。使い切った後は最後の値を返し続ける。
+func statusServer(t *testing.T, retryAfter string, statuses ...int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(calls.Add(1))
+		status := statuses[min(n, len(statuses))-1]
+		if retryAfter != "" && status != http.StatusNoContent {
+			w.Header().Set("Retry-After", retryAfter)
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func postWithRetry(url string, budget *RetryBudget) error {
+	return withRetry(budget, "discord", func() error {
+		return postToDiscord(url, "application/json", []byte(`{"content":"test"}`))
+	})
+}
+
+func TestPostToDiscordRetriesRateLimit(t *testing.T) {
+	server, calls := statusServer(t, "0.01", http.StatusTooManyRequests, http.StatusTooManyRequests, http.StatusNoContent)
+
+	start := time.Now()
+	if err := postWithRetry(server.URL, newRetryBudget(time.Minute)); err != nil {
+		t.Fatalf("send failed: %v", err)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("sent %d times, want 3", calls.Load())
+	}
+	// Retry-After に従えば、既定の 1 秒・2 秒の待ちは入らない。
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("took %s, want the Retry-After wait", elapsed)
+	}
+}
+
+func TestPostToDiscordFailsFastOnClientError(t *testing.T) {
+	for _, status := range []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound} {
+		server, calls := statusServer(t, "", status)
+
+		err := postWithRetry(server.URL, newRetryBudget(time.Minute))
+		var permanent *PermanentError
+		if !errors.As(err, &permanent) {
+			t.Errorf("%d: error %v, want a PermanentError", status, err)
+			continue
+		}
+		var statusErr *notify.HTTPStatusError
+		if !errors.As(err, &statusErr) || statusErr.StatusCode != status {
+			t.Errorf("%d: error %v does not wrap the HTTP status", status, err)
+		}
+		if calls.Load() != 1 {
+			t.Errorf("%d: sent %d times, want 1", status, calls.Load())
+		}
+	}
+}
+
+func TestPostToDiscordGivesUp(t *testing.T) {
+	server, calls := statusServer(t, "0.01", http.StatusInternalServerError)
+
+	err := postWithRetry(server.URL, newRetryBudget(time.Minute))
+	var exhausted *RetriesExhaustedError
+	if !errors.As(err, &exhausted) {
+		t.Fatalf("error %v, want a RetriesExhaustedError", err)
+	}
+	if exhausted.Attempts != maxAttempts || calls.Load() != maxAttempts {
+		t.Errorf("gave up after %d attempts and %d sends, want %d", exhausted.Attempts, calls.Load(), maxAttempts)
+	}
+	var permanent *PermanentError
+	if errors.As(err, &permanent) {
+		t.Error("a server error was reported as permanent")
+	}
+}
+
+func TestPostToDiscordRetryBudget(t *testing.T) {
+	// Retry-After が予算を超えるなら、待たずに諦める。
+	server, calls := statusServer(t, "60", http.StatusTooManyRequests, http.StatusNoContent)
+
+	err := postWithRetry(server.URL, newRetryBudget(time.Second))
+	var exhausted *RetriesExhaustedError
+	if !errors.As(err, &exhausted) || exhausted.Attempts != 1 {
+		t.Fatalf("error %v, want a RetriesExhaustedError after 1 attempt", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("sent %d times, want 1", calls.Load())
+	}
+}
+
+func TestRetryBudgetCountsAttempts(t *testing.T) {
+	// 1回の試行が予算より長くかかれば、待ち時間が短くても次は試さない。
+	var calls atomic.Int32
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		time.Sleep(300 * time.Millisecond)
+		w.Header().Set("Retry-After", "0.01")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(slow.Close)
+
+	err := postWithRetry(slow.URL, newRetryBudget(200*time.Millisecond))
+	var exhausted *RetriesExhaustedError
+	if !errors.As(err, &exhausted) || exhausted.Attempts != 1 {
+		t.Fatalf("error %v, want a RetriesExhaustedError after 1 attempt", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("sent %d times, want 1", calls.Load())
+	}
+}
+
+func TestWithRetryWaitsOnClock(t *testing.T) {
+	fake := clockwork.NewFakeClock()
+	saved := clock
+	clock = fake
+	t.Cleanup(func() { clock = saved })
+
+	attempts := 0
+	done := make(chan error, 1)
+	go func() {
+		done <- withRetry(newRetryBudget(time.Hour), "discord", func() error {
+			attempts++
+			if attempts == 1 {
+				return &notify.HTTPStatusError{StatusCode: http.StatusTooManyRequests, RetryAfter: time.Minute}
+			}
+			return nil
+		})
+	}()
+
+	// 待ちは注入した時計で数えるので、時計を進めるまで次の試行はない。
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := fake.BlockUntilContext(ctx, 1); err != nil {
+		t.Fatal("withRetry did not wait on the clock")
+	}
+	fake.Advance(time.Minute)
+	if err := <-done; err != nil {
+		t.Errorf("withRetry = %v after the wait", err)
+	}
+	if attempts != 2 {
+		t.Errorf("tried %d times, want 2", attempts)
+	}
+}
@@ -0,0 +1,60 @@
+package app
+
+import (
+	"log/slog"
+	"math/big"
+
+	"github.com/rakku1234/linux-traffic-checker/collector"
+)
+
+// autoInterface はデフォルトルートのインターフェースに追従する。
+const autoInterface = "auto"
+
+// resolveInterface は config をそのまま返す。interface が "auto" なら、Interface を
+// 今のデフォルトルートのインターフェースにした複製を返す。
+func resolveInterface(config *Config) (*Config, error) {
+	if config.Interface != autoInterface {
+		return config, nil
+	}
+	name, err := defaultRouteInterface()
+	if err != nil {
+		return nil, err
+	}
+	resolved := *config
+	resolved.Interface = name
+	return &resolved, nil
+}
+
+// followDefaultRoute は前回の読み取りからデフォルトルートが別のインターフェースに移った場合にカウンタを置き直す。
+// 前のインターフェースで最後の読み取りまでに数えた通信量は残し、2つのインターフェースのカウンタの差を
+// 通信量として数えないよう、新しいインターフェースのカウンタをベースラインと最後の読み取りにする。
+func followDefaultRoute(config *Config, device string, stats *Stats, currentRX, currentTX *big.Int) {
+	if config.Interface != autoInterface {
+		return
+	}
+	previous := stats.AutoInterface
+	stats.AutoInterface = device
+	if previous == "" || previous == device || stats.Month == "" {
+		return
+	}
+
+	if stats.Accumulated == nil && !stats.LastReadAt.IsZero() {
+		if stats.Carried == nil {
+			stats.Carried = &collector.InterfaceStats{}
+		}
+		for _, counter := range []struct{ carried, last, baseline *big.Int }{
+			{&stats.Carried.RX, &stats.LastRX, &stats.RX},
+			{&stats.Carried.TX, &stats.LastTX, &stats.TX},
+		} {
+			if used := new(big.Int).Sub(counter.last, counter.baseline); used.Sign() > 0 {
+				counter.carried.Add(counter.carried, used)
+			}
+		}
+	}
+	stats.RX.Set(currentRX)
+	stats.TX.Set(currentTX)
+	stats.LastRX.Set(currentRX)
+	stats.LastTX.Set(currentTX)
+	stats.PacketBaseline = nil
+	slog.Warn("デフォルトルートのインターフェースが変わったため、新しいインターフェースで集計を続けます", "from", previous, "to", device)
+}
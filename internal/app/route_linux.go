@@ -0,0 +1,94 @@
+//go:build linux
+
+package app
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// defaultRoute はメインのルーティングテーブルのデフォルトルート。
+type defaultRoute struct {
+	index  int
+	metric uint32
+}
+
+// defaultRoutes は1つのアドレスファミリーのルーティングテーブルを rtnetlink で取得し、使えるデフォルトルートを返す。
+// リンクが落ちているルートもカーネルはテーブルに残すが、ここでは飛ばす。
+func defaultRoutes(family int) ([]defaultRoute, error) {
+	data, err := syscall.NetlinkRIB(unix.RTM_GETROUTE, family)
+	if err != nil {
+		return nil, fmt.Errorf("netlinkで経路を取得できません: %w", err)
+	}
+	messages, err := syscall.ParseNetlinkMessage(data)
+	if err != nil {
+		return nil, fmt.Errorf("netlinkの応答を解析できません: %w", err)
+	}
+
+	var routes []defaultRoute
+	for i := range messages {
+		if messages[i].Header.Type != unix.RTM_NEWROUTE || len(messages[i].Data) < unix.SizeofRtMsg {
+			continue
+		}
+		// struct rtmsg の family・dst_len・src_len・tos・table・protocol・scope・type・flags
+		msg := messages[i].Data
+		if msg[1] != 0 || msg[7] != unix.RTN_UNICAST || binary.NativeEndian.Uint32(msg[8:])&unix.RTNH_F_LINKDOWN != 0 {
+			continue
+		}
+		attrs, err := syscall.ParseNetlinkRouteAttr(&messages[i])
+		if err != nil {
+			return nil, fmt.Errorf("netlinkの応答を解析できません: %w", err)
+		}
+
+		table := uint32(msg[4])
+		route := defaultRoute{}
+		for _, attr := range attrs {
+			if len(attr.Value) < 4 {
+				continue
+			}
+			value := binary.NativeEndian.Uint32(attr.Value)
+			switch attr.Attr.Type {
+			case unix.RTA_OIF:
+				route.index = int(value)
+			case unix.RTA_PRIORITY:
+				route.metric = value
+			case unix.RTA_TABLE:
+				table = value
+			}
+		}
+		if table == unix.RT_TABLE_MAIN && route.index > 0 {
+			routes = append(routes, route)
+		}
+	}
+	return routes, nil
+}
+
+// defaultRouteInterface はメトリックが最も小さいデフォルトルートのインターフェース名を返す。
+// IPv4 を優先し、なければ IPv6 を使う。
+func defaultRouteInterface() (string, error) {
+	for _, family := range []int{unix.AF_INET, unix.AF_INET6} {
+		routes, err := defaultRoutes(family)
+		if err != nil {
+			return "", err
+		}
+		if len(routes) == 0 {
+			continue
+		}
+		best := routes[0]
+		for _, route := range routes[1:] {
+			if route.metric < best.metric {
+				best = route
+			}
+		}
+		iface, err := net.InterfaceByIndex(best.index)
+		if err != nil {
+			return "", fmt.Errorf("デフォルトルートのインターフェース（index %d）が見つかりません: %w", best.index, err)
+		}
+		return iface.Name, nil
+	}
+	return "", fmt.Errorf("デフォルトルートがありません")
+}
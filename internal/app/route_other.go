@@ -0,0 +1,13 @@
+//go:build !linux
+
+package app
+
+import (
+	"errors"
+	"fmt"
+)
+
+// defaultRouteInterface には rtnetlink が必要なので、interface の "auto" は Linux でしか使えない。
+func defaultRouteInterface() (string, error) {
+	return "", fmt.Errorf("interface \"auto\" はLinuxでのみ使えます: %w", errors.ErrUnsupported)
+}
@@ -0,0 +1,79 @@
+package app
+
+import (
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/rakku1234/linux-traffic-checker/collector"
+	"github.com/rakku1234/linux-traffic-checker/report"
+)
+
+// readCounters は統計のためにカウンタを読み取り、読み取れたことを /healthz のために記録する。
+func readCounters(config *Config) (big.Int, big.Int, map[string]*collector.InterfaceStats, error) {
+	rx, tx, counters, err := sampleCounters(config)
+	if err == nil {
+		recordSample()
+	}
+	return rx, tx, counters, err
+}
+
+// sampleCounters はカウンタを config.ReadSamples 回読み取ってまとめる。
+// カウンタの更新と重なった読み取りで結果がずれないようにする。
+func sampleCounters(config *Config) (big.Int, big.Int, map[string]*collector.InterfaceStats, error) {
+	if config.ReadSamples <= 1 {
+		return readCountersOnce(config)
+	}
+
+	interval, _ := time.ParseDuration(config.ReadSampleInterval)
+	var rxs, txs []*big.Int
+	perInterface := map[string][]*collector.InterfaceStats{}
+	for i := range config.ReadSamples {
+		if i > 0 {
+			time.Sleep(interval)
+		}
+		rx, tx, counters, err := readCountersOnce(config)
+		if err != nil {
+			return big.Int{}, big.Int{}, nil, err
+		}
+		rxs = append(rxs, &rx)
+		txs = append(txs, &tx)
+		for name, counter := range counters {
+			perInterface[name] = append(perInterface[name], counter)
+		}
+	}
+
+	var counters map[string]*collector.InterfaceStats
+	if len(perInterface) > 0 {
+		counters = make(map[string]*collector.InterfaceStats, len(perInterface))
+		for name, samples := range perInterface {
+			var rx, tx []*big.Int
+			for _, sample := range samples {
+				rx = append(rx, &sample.RX)
+				tx = append(tx, &sample.TX)
+			}
+			counter := &collector.InterfaceStats{}
+			counter.RX.Set(combineSamples(config.ReadSampleMode, rx))
+			counter.TX.Set(combineSamples(config.ReadSampleMode, tx))
+			counters[name] = counter
+		}
+		// 合計は元になったインターフェースごとの値と食い違わないようにする。
+		rx, tx := report.SumCounters(counters)
+		return rx, tx, counters, nil
+	}
+
+	var rx, tx big.Int
+	rx.Set(combineSamples(config.ReadSampleMode, rxs))
+	tx.Set(combineSamples(config.ReadSampleMode, txs))
+	return rx, tx, counters, nil
+}
+
+// combineSamples はサンプルの中央値（偶数個なら小さい方）か最大値を返す。
+func combineSamples(mode string, samples []*big.Int) *big.Int {
+	sorted := append([]*big.Int(nil), samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Cmp(sorted[j]) < 0 })
+	if mode == "max" {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[(len(sorted)-1)/2]
+}
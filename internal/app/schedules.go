@@ -0,0 +1,89 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/robfig/cron/v3"
+)
+
+// ReportSchedule は "schedules" の1つで、レポートする時刻とレポートの対象の期間。
+type ReportSchedule struct {
+	Cron   string `json:"cron"`
+	Period string `json:"period"`
+}
+
+// applyScheduleDefaults は最初のレポートが設定した統計ファイルを使い続けるよう、
+// schedules の最初の項目から schedule と period を取る。
+func applyScheduleDefaults(config *Config) {
+	for i := range config.Schedules {
+		if config.Schedules[i].Period == "" {
+			config.Schedules[i].Period = "monthly"
+		}
+	}
+	if len(config.Schedules) > 0 && config.Schedule == "" && config.Period == "" {
+		config.Schedule = config.Schedules[0].Cron
+		config.Period = config.Schedules[0].Period
+	}
+}
+
+func validateSchedules(config *Config) error {
+	if len(config.Schedules) == 0 {
+		return nil
+	}
+	if config.Schedule != config.Schedules[0].Cron || config.Period != config.Schedules[0].Period {
+		return fmt.Errorf("schedules を使う場合は schedule と period を指定しないでください")
+	}
+	if len(config.Schedules) > 1 && config.StatsFile == stdioStatsFile {
+		return fmt.Errorf("stats_file が \"-\" の場合は schedules に複数のレポートを指定できません")
+	}
+
+	seen := map[string]bool{}
+	for _, schedule := range config.Schedules {
+		if _, err := cron.ParseStandard(schedule.Cron); err != nil {
+			return fmt.Errorf("schedules のcron式が正しくありません（%q）: %w", schedule.Cron, err)
+		}
+		switch schedule.Period {
+		case "monthly", "weekly", "daily":
+		default:
+			return fmt.Errorf("schedules の period は monthly, weekly, daily のいずれかを指定してください: %q", schedule.Period)
+		}
+		if seen[schedule.Period] {
+			return fmt.Errorf("schedules に同じ period が複数あります: %q", schedule.Period)
+		}
+		seen[schedule.Period] = true
+	}
+	return nil
+}
+
+// reportConfigs はレポートごとの設定を返す。最初は config そのもので、それ以降のスケジュールは期間ごとに
+// ベースラインを持てるよう、スケジュール・期間・統計（stats_file と redis_key の末尾に期間を付ける）を
+// 変えた複製。アラート・使用量のアラート・on_quota_exceeded・ダイジェストと history_db・timeseries_url への
+// 書き込みは、期間の数だけ繰り返さないよう最初のレポートでだけ行う。
+func reportConfigs(config *Config) ([]*Config, error) {
+	configs := []*Config{config}
+	for _, schedule := range config.Schedules[min(1, len(config.Schedules)):] {
+		report := *config
+		report.Schedule = schedule.Cron
+		report.cycleStartOnly = false
+		report.Period = schedule.Period
+		report.StatsFile += "." + schedule.Period
+		report.RedisKey += ":" + schedule.Period
+		report.CapBytes, report.RXCapBytes, report.TXCapBytes = 0, 0, 0
+		report.QuotaBytes = 0
+		report.AlertThresholds = nil
+		report.threshold = nil
+		report.OnQuotaExceeded = nil
+		report.QuotaActions = nil
+		report.DigestSchedule = ""
+		report.historyReadOnly = true
+		report.TimeseriesURL = ""
+
+		statsStore, err := openStore(&report)
+		if err != nil {
+			return nil, err
+		}
+		report.store = migratingStore{Store: statsStore, config: &report}
+		configs = append(configs, &report)
+	}
+	return configs, nil
+}
@@ -0,0 +1,268 @@
+package app
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+// IF-MIB と SNMPv2-MIB の OID。64ビットの ifHC* のカウンタを優先し、32ビットの ifInOctets・ifOutOctets
+// しかない機器はいつもの桁あふれの検出を通して読む。
+const (
+	oidSysUpTime     = ".1.3.6.1.2.1.1.3.0"
+	oidIfDescr       = ".1.3.6.1.2.1.2.2.1.2"
+	oidIfOperStatus  = ".1.3.6.1.2.1.2.2.1.8"
+	oidIfInOctets    = ".1.3.6.1.2.1.2.2.1.10"
+	oidIfOutOctets   = ".1.3.6.1.2.1.2.2.1.16"
+	oidIfName        = ".1.3.6.1.2.1.31.1.1.1.1"
+	oidIfHCInOctets  = ".1.3.6.1.2.1.31.1.1.1.6"
+	oidIfHCOutOctets = ".1.3.6.1.2.1.31.1.1.1.10"
+)
+
+var snmpAuthProtocols = map[string]gosnmp.SnmpV3AuthProtocol{
+	"MD5": gosnmp.MD5, "SHA": gosnmp.SHA, "SHA224": gosnmp.SHA224,
+	"SHA256": gosnmp.SHA256, "SHA384": gosnmp.SHA384, "SHA512": gosnmp.SHA512,
+}
+
+var snmpPrivProtocols = map[string]gosnmp.SnmpV3PrivProtocol{
+	"DES": gosnmp.DES, "AES": gosnmp.AES, "AES192": gosnmp.AES192,
+	"AES256": gosnmp.AES256, "AES192C": gosnmp.AES192C, "AES256C": gosnmp.AES256C,
+}
+
+// ifOperStatus の値を /sys/class/net/*/operstate の言葉で表したもの。
+var snmpOperStates = map[int]string{
+	1: "up", 2: "down", 3: "testing", 4: "unknown", 5: "dormant", 6: "notpresent", 7: "lowerlayerdown",
+}
+
+func validateSNMP(config *Config) error {
+	if config.SNMPHost == "" {
+		return nil
+	}
+	if config.CounterCommand != "" || config.ContainerRuntime != "" || config.NetnsName != "" || config.aggregate() || config.Interface == autoInterface {
+		return fmt.Errorf("snmp_host は counter_command、container_runtime、netns_name、interface: \"all\"・\"auto\"、interface_pattern と同時に指定できません")
+	}
+	if config.SNMPIfIndex < 0 {
+		return fmt.Errorf("snmp_if_index は1以上を指定してください: %d", config.SNMPIfIndex)
+	}
+	if config.SNMPIfIndex > 0 && config.multiInterface() {
+		return fmt.Errorf("snmp_if_index は interfaces と同時に指定できません（interfaces には ifName を指定してください）")
+	}
+	if config.SNMPIfIndex == 0 && config.Interface == "" && !config.multiInterface() {
+		return fmt.Errorf("snmp_host には、読み取るインターフェースの ifName を interface に指定するか、snmp_if_index を指定してください")
+	}
+	if config.packetStats() || len(config.EthtoolStats) > 0 {
+		return fmt.Errorf("snmp_host は show_packets、drop_warning_percent、ethtool_stats と併用できません")
+	}
+	if _, err := time.ParseDuration(config.SNMPTimeout); err != nil {
+		return fmt.Errorf("snmp_timeout が不正です（%q）: %w", config.SNMPTimeout, err)
+	}
+	switch config.SNMPVersion {
+	case "1", "2c":
+	case "3":
+		if config.SNMPUser == "" {
+			return fmt.Errorf("snmp_version が 3 の場合は snmp_user を指定してください")
+		}
+		if _, ok := snmpAuthProtocols[strings.ToUpper(config.SNMPAuthProtocol)]; config.SNMPAuthProtocol != "" && !ok {
+			return fmt.Errorf("snmp_auth_protocol は MD5, SHA, SHA224, SHA256, SHA384, SHA512 のいずれかを指定してください: %q", config.SNMPAuthProtocol)
+		}
+		if _, ok := snmpPrivProtocols[strings.ToUpper(config.SNMPPrivProtocol)]; config.SNMPPrivProtocol != "" && !ok {
+			return fmt.Errorf("snmp_priv_protocol は DES, AES, AES192, AES256, AES192C, AES256C のいずれかを指定してください: %q", config.SNMPPrivProtocol)
+		}
+		if config.SNMPPrivProtocol != "" && config.SNMPAuthProtocol == "" {
+			return fmt.Errorf("snmp_priv_protocol を指定する場合は snmp_auth_protocol も指定してください")
+		}
+	default:
+		return fmt.Errorf("snmp_version は 1, 2c, 3 のいずれかを指定してください: %q", config.SNMPVersion)
+	}
+	return nil
+}
+
+// snmpClient は設定したバージョンと認証情報で snmp_host に接続する。
+func snmpClient(config *Config) (*gosnmp.GoSNMP, error) {
+	host, port := config.SNMPHost, uint16(161)
+	if h, p, err := net.SplitHostPort(config.SNMPHost); err == nil {
+		n, err := strconv.ParseUint(p, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("snmp_host のポートが不正です: %q", config.SNMPHost)
+		}
+		host, port = h, uint16(n)
+	}
+	timeout, _ := time.ParseDuration(config.SNMPTimeout)
+	client := &gosnmp.GoSNMP{
+		Target:             host,
+		Port:               port,
+		Community:          config.SNMPCommunity,
+		Timeout:            timeout,
+		Retries:            1,
+		ExponentialTimeout: true,
+		MaxOids:            gosnmp.MaxOids,
+	}
+	switch config.SNMPVersion {
+	case "1":
+		client.Version = gosnmp.Version1
+	case "2c":
+		client.Version = gosnmp.Version2c
+	case "3":
+		client.Version = gosnmp.Version3
+		client.SecurityModel = gosnmp.UserSecurityModel
+		params := &gosnmp.UsmSecurityParameters{UserName: config.SNMPUser}
+		client.MsgFlags = gosnmp.NoAuthNoPriv
+		if config.SNMPAuthProtocol != "" {
+			client.MsgFlags = gosnmp.AuthNoPriv
+			params.AuthenticationProtocol = snmpAuthProtocols[strings.ToUpper(config.SNMPAuthProtocol)]
+			params.AuthenticationPassphrase = config.SNMPAuthPassword
+		}
+		if config.SNMPPrivProtocol != "" {
+			client.MsgFlags = gosnmp.AuthPriv
+			params.PrivacyProtocol = snmpPrivProtocols[strings.ToUpper(config.SNMPPrivProtocol)]
+			params.PrivacyPassphrase = config.SNMPPrivPassword
+		}
+		client.SecurityParameters = params
+	}
+	if err := client.Connect(); err != nil {
+		return nil, fmt.Errorf("SNMPの接続に失敗（%s）: %w", config.SNMPHost, err)
+	}
+	return client, nil
+}
+
+// snmpIfIndex は snmp_if_index を返す。指定がなければ ifName（IF-MIB の ifXTable のない機器では ifDescr）が
+// config.Interface の ifIndex を探す。
+func snmpIfIndex(client *gosnmp.GoSNMP, config *Config) (int, error) {
+	if config.SNMPIfIndex > 0 {
+		return config.SNMPIfIndex, nil
+	}
+	for _, root := range []string{oidIfName, oidIfDescr} {
+		var pdus []gosnmp.SnmpPDU
+		var err error
+		if client.Version == gosnmp.Version1 {
+			pdus, err = client.WalkAll(root)
+		} else {
+			pdus, err = client.BulkWalkAll(root)
+		}
+		if err != nil {
+			return 0, fmt.Errorf("SNMPでインターフェースの一覧を取得できません: %w", err)
+		}
+		for _, pdu := range pdus {
+			name, ok := pdu.Value.([]byte)
+			if !ok || string(name) != config.Interface {
+				continue
+			}
+			index, err := strconv.Atoi(pdu.Name[strings.LastIndex(pdu.Name, ".")+1:])
+			if err == nil {
+				return index, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("SNMPでインターフェース %s が見つかりません", config.Interface)
+}
+
+// snmpBootTimes はカウンタと一緒に読んだ sysUpTime から、機器ごとの最後の起動時刻を覚えておく。
+// rebootedSince が機器の再起動とカウンタの桁あふれを区別できるようにする。
+var (
+	snmpBootTimes   = map[string]time.Time{}
+	snmpBootTimesMu sync.Mutex
+)
+
+// snmpCounter32 は ifHC* のカウンタがなく、32ビットの ifInOctets・ifOutOctets を読んだ機器。
+var (
+	snmpCounter32   = map[string]bool{}
+	snmpCounter32Mu sync.Mutex
+)
+
+func snmpBootTime(host string) (time.Time, bool) {
+	snmpBootTimesMu.Lock()
+	defer snmpBootTimesMu.Unlock()
+	booted, ok := snmpBootTimes[host]
+	return booted, ok
+}
+
+// readSNMPCounters は snmp_host の機器からインターフェースの受信・送信バイト数を読み取る。
+func readSNMPCounters(config *Config) (big.Int, big.Int, error) {
+	client, err := snmpClient(config)
+	if err != nil {
+		return big.Int{}, big.Int{}, err
+	}
+	defer client.Conn.Close()
+
+	index, err := snmpIfIndex(client, config)
+	if err != nil {
+		return big.Int{}, big.Int{}, err
+	}
+
+	var values []*big.Int
+	var uptime *big.Int
+	var counter32 bool
+	for _, oids := range [][]string{{oidIfHCInOctets, oidIfHCOutOctets}, {oidIfInOctets, oidIfOutOctets}} {
+		if client.Version == gosnmp.Version1 && oids[0] == oidIfHCInOctets {
+			// SNMPv1 には Counter64 がない。
+			continue
+		}
+		packet, err := client.Get([]string{fmt.Sprintf("%s.%d", oids[0], index), fmt.Sprintf("%s.%d", oids[1], index), oidSysUpTime})
+		if err != nil {
+			return big.Int{}, big.Int{}, fmt.Errorf("SNMPでカウンタを取得できません: %w", err)
+		}
+		values = values[:0]
+		counter32 = false
+		for _, pdu := range packet.Variables[:2] {
+			if pdu.Type != gosnmp.Counter64 && pdu.Type != gosnmp.Counter32 {
+				break
+			}
+			counter32 = counter32 || pdu.Type == gosnmp.Counter32
+			values = append(values, gosnmp.ToBigInt(pdu.Value))
+		}
+		if pdu := packet.Variables[2]; pdu.Type == gosnmp.TimeTicks {
+			uptime = gosnmp.ToBigInt(pdu.Value)
+		}
+		if len(values) == 2 {
+			break
+		}
+	}
+	if len(values) != 2 {
+		return big.Int{}, big.Int{}, fmt.Errorf("SNMPでインターフェース %s（ifIndex %d）のカウンタがありません", config.Interface, index)
+	}
+
+	if uptime != nil {
+		// sysUpTime は1/100秒単位。
+		booted := time.Now().Add(-time.Duration(uptime.Int64()) * 10 * time.Millisecond).Truncate(time.Minute)
+		snmpBootTimesMu.Lock()
+		snmpBootTimes[config.SNMPHost] = booted
+		snmpBootTimesMu.Unlock()
+	}
+	snmpCounter32Mu.Lock()
+	snmpCounter32[config.SNMPHost] = counter32
+	snmpCounter32Mu.Unlock()
+
+	var rx, tx big.Int
+	rx.Set(values[0])
+	tx.Set(values[1])
+	return rx, tx, nil
+}
+
+// readSNMPOperState はインターフェースの ifOperStatus を、このホストの operstate と同じ名前で読み取る。
+func readSNMPOperState(config *Config) (string, error) {
+	client, err := snmpClient(config)
+	if err != nil {
+		return "", err
+	}
+	defer client.Conn.Close()
+
+	index, err := snmpIfIndex(client, config)
+	if err != nil {
+		return "", err
+	}
+	packet, err := client.Get([]string{fmt.Sprintf("%s.%d", oidIfOperStatus, index)})
+	if err != nil {
+		return "", fmt.Errorf("SNMPでリンク状態を取得できません: %w", err)
+	}
+	state, ok := snmpOperStates[int(gosnmp.ToBigInt(packet.Variables[0].Value).Int64())]
+	if !ok {
+		return "unknown", nil
+	}
+	return state, nil
+}
@@ -0,0 +1,160 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"time"
+)
+
+type InterfaceStatus struct {
+	Interface  string   `json:"interface"`
+	Period     string   `json:"period"`
+	BaselineRX *big.Int `json:"baseline_rx"`
+	BaselineTX *big.Int `json:"baseline_tx"`
+	UsedRX     *big.Int `json:"used_rx"`
+	UsedTX     *big.Int `json:"used_tx"`
+	UsedTotal  *big.Int `json:"used_total"`
+	// UsedRXText・UsedTXText・UsedTotalText は通知と同じ単位で表した通信量。
+	UsedRXText    string `json:"used_rx_text"`
+	UsedTXText    string `json:"used_tx_text"`
+	UsedTotalText string `json:"used_total_text"`
+	// Live は今カウンタを読み取れず、通信量が最後の読み取りで保存したものの場合は false。
+	Live      bool       `json:"live"`
+	LastRead  *time.Time `json:"last_read_at,omitempty"`
+	ReadError string     `json:"read_error,omitempty"`
+}
+
+type Status struct {
+	Period         string            `json:"period"`
+	GeneratedAt    time.Time         `json:"generated_at"`
+	ThresholdBytes *big.Int          `json:"threshold_bytes"`
+	LastNotified   *time.Time        `json:"last_notified"`
+	Interfaces     []InterfaceStatus `json:"interfaces"`
+}
+
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// interfaceStatus は今読み取ったカウンタと保存したベースラインを合わせる。
+func interfaceStatus(config *Config, stats *Stats) InterfaceStatus {
+	status := InterfaceStatus{
+		Interface:  interfaceDisplayName(config),
+		Period:     stats.Month,
+		BaselineRX: new(big.Int).Set(&stats.RX),
+		BaselineTX: new(big.Int).Set(&stats.TX),
+		LastRead:   optionalTime(stats.LastReadAt),
+	}
+
+	usage := storedUsage(stats)
+	device, err := resolveInterface(config)
+	var rx, tx big.Int
+	if err == nil {
+		rx, tx, _, err = readCountersOnce(device)
+	}
+	switch {
+	case err != nil:
+		status.ReadError = err.Error()
+	case stats.Month == "":
+		status.ReadError = "まだベースラインが記録されていません"
+	case config.Interface == autoInterface && device.Interface != stats.AutoInterface:
+		status.ReadError = "デフォルトルートのインターフェースが変わったため、次の読み取りまでは保存済みの通信量です"
+	case rx.Cmp(&stats.RX) < 0 || tx.Cmp(&stats.TX) < 0:
+		status.ReadError = "カウンタがベースラインより小さくなっています"
+	default:
+		usedRX, usedTX := periodUsage(stats, &rx, &tx)
+		usage.RX.Set(usedRX)
+		usage.TX.Set(usedTX)
+		status.Live = true
+	}
+	status.UsedRX = &usage.RX
+	status.UsedTX = &usage.TX
+	status.UsedTotal = countedTotal(config, &usage.RX, &usage.TX)
+	status.UsedRXText = formatBytes(status.UsedRX)
+	status.UsedTXText = formatBytes(status.UsedTX)
+	status.UsedTotalText = formatBytes(status.UsedTotal)
+	return status
+}
+
+// allowGet は GET と HEAD 以外のメソッドを拒否する。
+func allowGet(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return false
+	}
+	return true
+}
+
+func buildStatus(config *Config) (*Status, error) {
+	stats, _, err := config.store.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	status := &Status{
+		Period:         periodKey(config.Period, config.now()),
+		GeneratedAt:    clock.Now().UTC(),
+		ThresholdBytes: config.threshold,
+		LastNotified:   optionalTime(stats.LastNotified),
+		Interfaces:     []InterfaceStatus{},
+	}
+	for _, scope := range interfaceScopes(config, stats) {
+		status.Interfaces = append(status.Interfaces, interfaceStatus(scope.config, scope.stats))
+	}
+	return status, nil
+}
+
+func writeJSON(w http.ResponseWriter, value any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	err := encoder.Encode(value)
+	if err != nil {
+		slog.Error("レスポンスの出力に失敗", "error", err)
+	}
+}
+
+func statusHandler(config *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allowGet(w, r) {
+			return
+		}
+
+		status, err := buildStatus(config)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("統計ファイルを読み込めません: %v", err), http.StatusServiceUnavailable)
+			return
+		}
+		writeJSON(w, status)
+	}
+}
+
+// startStatusServer はバックグラウンドで /status・通信量のAPI・/healthz・ステータスページを提供する。
+// 返したサーバーは終了時に Main が止める。
+func startStatusServer(config *Config) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", statusHandler(config))
+	mux.HandleFunc("/api/v1/usage/current", statusHandler(config))
+	mux.HandleFunc("/api/v1/usage/history", historyHandler(config))
+	mux.HandleFunc("/healthz", healthHandler(config))
+	mux.HandleFunc("/{$}", statusPageHandler(config))
+	server := &http.Server{Addr: config.StatusAddr, Handler: mux}
+
+	go func() {
+		slog.Info("ステータスサーバーを起動しました", "addr", config.StatusAddr)
+		err := server.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("ステータスサーバーのエラー", "error", err)
+		}
+	}()
+	return server
+}
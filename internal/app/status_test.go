@@ -0,0 +1,147 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+)
+
+// statusConfig は stats を保存した統計ファイルと、rx=6000 tx=4000 を出力する counter_command の設定を読み込む。
+func statusConfig(t *testing.T, stats string) *Config {
+	t.Helper()
+	saved := clock
+	clock = clockwork.NewFakeClockAt(time.Date(2026, time.October, 14, 12, 0, 0, 0, time.UTC))
+	t.Cleanup(func() { clock = saved })
+
+	dir := t.TempDir()
+	statsFile := filepath.Join(dir, "stats.json")
+	if err := os.WriteFile(statsFile, []byte(stats), 0644); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(map[string]any{
+		"discord_webhook_url": "https://discord.com/api/webhooks/1/a",
+		"interface":           "eth0",
+		"stats_file":          statsFile,
+		"threshold_bytes":     1 << 30,
+		"counter_command":     "echo rx=6000 tx=4000",
+		"counter_regex":       `rx=(?P<rx>\d+) tx=(?P<tx>\d+)`,
+	})
+	path := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := readConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return config
+}
+
+func TestStatusHandler(t *testing.T) {
+	config := statusConfig(t, `{"month": "2026-10", "rx": 1000, "tx": 2000, "last_read_at": "2026-10-14T11:00:00Z", "last_notified": "2026-10-01T00:00:00Z"}`)
+
+	recorder := httptest.NewRecorder()
+	statusHandler(config)(recorder, httptest.NewRequest(http.MethodGet, "/status", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", recorder.Code, recorder.Body)
+	}
+	if contentType := recorder.Header().Get("Content-Type"); contentType != "application/json; charset=utf-8" {
+		t.Errorf("Content-Type = %q", contentType)
+	}
+
+	// ダッシュボードが頼りにするキーがそろっていること。
+	var shape map[string]json.RawMessage
+	if err := json.Unmarshal(recorder.Body.Bytes(), &shape); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"period", "generated_at", "threshold_bytes", "last_notified", "interfaces"} {
+		if _, ok := shape[key]; !ok {
+			t.Errorf("no %q in %s", key, recorder.Body)
+		}
+	}
+	var interfaces []map[string]json.RawMessage
+	if err := json.Unmarshal(shape["interfaces"], &interfaces); err != nil || len(interfaces) != 1 {
+		t.Fatalf("interfaces = %s, %v", shape["interfaces"], err)
+	}
+	keys := []string{"interface", "period", "baseline_rx", "baseline_tx", "used_rx", "used_tx", "used_total", "used_rx_text", "used_tx_text", "used_total_text", "live", "last_read_at"}
+	for key := range interfaces[0] {
+		if !slices.Contains(keys, key) {
+			t.Errorf("unexpected interface key %q", key)
+		}
+	}
+	for _, key := range keys {
+		if _, ok := interfaces[0][key]; !ok {
+			t.Errorf("no interface key %q", key)
+		}
+	}
+
+	var status Status
+	if err := json.Unmarshal(recorder.Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+	if status.Period != "2026-10" || status.ThresholdBytes.Int64() != 1<<30 {
+		t.Errorf("period %q, threshold %s", status.Period, status.ThresholdBytes)
+	}
+	if status.LastNotified == nil || !status.LastNotified.Equal(time.Date(2026, time.October, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("last_notified = %v", status.LastNotified)
+	}
+	got := status.Interfaces[0]
+	if got.Interface != "eth0" || !got.Live || got.ReadError != "" {
+		t.Errorf("interface %q, live %v, read error %q", got.Interface, got.Live, got.ReadError)
+	}
+	// 今読んだカウンタからベースラインを引いた通信量。
+	if got.BaselineRX.Int64() != 1000 || got.BaselineTX.Int64() != 2000 || got.UsedRX.Int64() != 5000 || got.UsedTX.Int64() != 2000 || got.UsedTotal.Int64() != 7000 {
+		t.Errorf("baseline %s/%s, used %s/%s/%s", got.BaselineRX, got.BaselineTX, got.UsedRX, got.UsedTX, got.UsedTotal)
+	}
+	if got.UsedTotalText != formatBytes(got.UsedTotal) {
+		t.Errorf("used_total_text = %q", got.UsedTotalText)
+	}
+}
+
+func TestStatusHandlerErrors(t *testing.T) {
+	config := statusConfig(t, `{"month": `)
+
+	recorder := httptest.NewRecorder()
+	statusHandler(config)(recorder, httptest.NewRequest(http.MethodGet, "/status", nil))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("corrupted stats: status %d, want 503", recorder.Code)
+	}
+
+	recorder = httptest.NewRecorder()
+	statusHandler(config)(recorder, httptest.NewRequest(http.MethodPost, "/status", nil))
+	if recorder.Code != http.StatusMethodNotAllowed || recorder.Header().Get("Allow") != "GET, HEAD" {
+		t.Errorf("POST: status %d, Allow %q", recorder.Code, recorder.Header().Get("Allow"))
+	}
+}
+
+func TestStatusPageLanguage(t *testing.T) {
+	config := statusConfig(t, `{"month": "2026-10", "rx": 1000, "tx": 2000}`)
+	saved := language
+	language = "en"
+	t.Cleanup(func() { language = saved })
+
+	recorder := httptest.NewRecorder()
+	statusPageHandler(config)(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", recorder.Code, recorder.Body)
+	}
+	body := recorder.Body.String()
+	for _, want := range []string{`lang="en"`, "Interface", "Last read", "Now", "As of 2026-10-14 12:00:00"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("no %q in %s", want, body)
+		}
+	}
+	for _, text := range []string{"インターフェース", "最終読み取り", "現在", "時点"} {
+		if strings.Contains(body, text) {
+			t.Errorf("untranslated %q in %s", text, body)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package app
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/rakku1234/linux-traffic-checker/store"
+)
+
+type fileStore struct {
+	path string
+}
+
+func (s fileStore) Load() (*Stats, bool, error) {
+	return loadStats(s.path)
+}
+
+func (s fileStore) Save(stats *Stats) error {
+	return saveStats(s.path, stats)
+}
+
+// Writable は統計ファイルと同じディレクトリにファイルを作って消す。
+// 保存は同じディレクトリの新しいファイルで統計ファイルを置き換えるため。
+func (s fileStore) Writable() error {
+	if s.path == stdioStatsFile {
+		return nil
+	}
+	file, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	file.Close()
+	return os.Remove(file.Name())
+}
+
+// redisStore は -dry-run のとき Redis に保存せず、保存されている統計との差分を表示する。
+type redisStore struct {
+	*store.RedisStore[Stats]
+}
+
+func (s redisStore) Save(stats *Stats) error {
+	if !dryRun {
+		return s.RedisStore.Save(stats)
+	}
+	data, err := json.Marshal(stats)
+	if err != nil {
+		return err
+	}
+	old, err := s.Stored()
+	if err != nil {
+		slog.Warn("Redisから統計を読み込めないため、空の統計と比較します", "key", s.Key(), "error", err)
+	}
+	return printStatsDiff(os.Stdout, "redis:"+s.Key(), old, data)
+}
+
+func openStore(config *Config) (store.Store[Stats], error) {
+	switch config.StorageBackend {
+	case "redis":
+		redis, err := store.NewRedisStore[Stats](config.RedisURL, config.RedisKey)
+		if err != nil {
+			return nil, err
+		}
+		return redisStore{redis}, nil
+	default:
+		return fileStore{path: config.StatsFile}, nil
+	}
+}
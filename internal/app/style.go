@@ -0,0 +1,86 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/rakku1234/linux-traffic-checker/notify"
+)
+
+type MessageStyle struct {
+	// タイトルのテンプレート。{title} は元のタイトルに置き換わる。空なら元のタイトルのまま。
+	Title string `json:"title"`
+	// 埋め込みの色。10進数または "0xff0000" のような16進数。未指定なら通知ごとの既定色。
+	Color *EmbedColor `json:"color"`
+	// メッセージ本文に付けるメンション（例: "<@&123456>"）。
+	Mention string `json:"mention"`
+}
+
+func messageStyle(config *Config, notifier string, kind notify.Kind) MessageStyle {
+	style := config.MessageStyles[notifier][string(kind)]
+	if notifier == "discord" && kind == notify.KindAlert && style.Mention == "" && config.AlertRoleID != "" {
+		style.Mention = "<@&" + config.AlertRoleID + ">"
+	}
+	return style
+}
+
+func (style MessageStyle) title(title string) string {
+	if style.Title == "" {
+		return title
+	}
+	return strings.ReplaceAll(style.Title, "{title}", title)
+}
+
+// styleEmbeds は embeds の複製にスタイルを適用する。embeds は他の通知先や
+// 再試行でも使うので変更しない。
+func styleEmbeds(style MessageStyle, embeds []notify.Embed) []notify.Embed {
+	styled := make([]notify.Embed, len(embeds))
+	for i, embed := range embeds {
+		embed.Fields = slices.Clone(embed.Fields)
+		embed.Title = style.title(embedTitle(embed))
+		if style.Color != nil {
+			embed.Color = int(*style.Color)
+		}
+		styled[i] = embed
+	}
+	return styled
+}
+
+// EmbedColor は埋め込みの色で、設定ではJSONの数値か、10進数または 0x で始まる16進数の文字列で書く。
+type EmbedColor int
+
+func (c *EmbedColor) UnmarshalJSON(data []byte) error {
+	text := string(data)
+	if unquoted, err := strconv.Unquote(text); err == nil {
+		text = unquoted
+	}
+	var value int64
+	var err error
+	if hex, ok := strings.CutPrefix(strings.ToLower(text), "0x"); ok {
+		value, err = strconv.ParseInt(hex, 16, 64)
+	} else {
+		value, err = strconv.ParseInt(text, 10, 64)
+	}
+	if err != nil || value < 0 || value > 0xffffff {
+		return fmt.Errorf("色は 0〜16777215 の10進数か 0x000000〜0xffffff の16進数で指定してください: %s", data)
+	}
+	*c = EmbedColor(value)
+	return nil
+}
+
+func (c EmbedColor) MarshalJSON() ([]byte, error) {
+	return json.Marshal(fmt.Sprintf("0x%06x", int(c)))
+}
+
+const defaultReportTitle = "{interface} の通信量（{period}）"
+
+func formatReportTitle(config *Config, interfaceName, period string) string {
+	title := config.ReportTitle
+	if title == "" {
+		title = tr(defaultReportTitle)
+	}
+	return expandMessage("report_title", title, messageData{Interface: interfaceName, Period: period})
+}
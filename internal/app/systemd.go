@@ -0,0 +1,82 @@
+package app
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+)
+
+// sdNotify は systemd の Type=notify のサービスとして動いている場合に、READY=1 などの状態をサービスマネージャーに送る。
+// NOTIFY_SOCKET がなければ何もしない。
+func sdNotify(state string) {
+	path := os.Getenv("NOTIFY_SOCKET")
+	if path == "" {
+		return
+	}
+	if path[0] == '@' {
+		// 抽象名前空間のソケット
+		path = "\x00" + path[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err == nil {
+		_, err = conn.Write([]byte(state))
+		conn.Close()
+	}
+	if err != nil {
+		slog.Debug("systemdへの通知に失敗", "state", state, "error", err)
+	}
+}
+
+// sdNotifyReloading は設定を再読み込みしていることを systemd に知らせる。Type=notify-reload にはモノトニック時刻が必要。
+func sdNotifyReloading() {
+	now, err := monotonicNow()
+	if err != nil {
+		sdNotify("RELOADING=1")
+		return
+	}
+	sdNotify(fmt.Sprintf("RELOADING=1\nMONOTONIC_USEC=%d", now.Microseconds()))
+}
+
+// watchdogInterval はサービスの WatchdogSec=。このプロセスでウォッチドッグが有効でなければ0。
+func watchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// watchdogHeartbeat は統計のロックが空くと WATCHDOG=1 を送る。ロックを持ったまま止まったジョブ（読み取りや通知の
+// 停止）があれば通知が途絶え、systemd がプロセスを再起動する。
+func watchdogHeartbeat() {
+	statsMu.Lock()
+	statsMu.Unlock()
+	sdNotify("WATCHDOG=1")
+}
+
+// registerWatchdog は sd_watchdog_enabled(3) の勧めに従い、ウォッチドッグの間隔の半分ごとに通知するジョブを加える。
+func (d *daemon) registerWatchdog() error {
+	interval := watchdogInterval()
+	if interval == 0 {
+		return nil
+	}
+	_, err := d.scheduler.NewJob(
+		gocron.DurationJob(interval/2),
+		gocron.NewTask(watchdogHeartbeat),
+		gocron.WithStartAt(gocron.WithStartImmediately()),
+		gocron.WithSingletonMode(gocron.LimitModeReschedule),
+	)
+	if err != nil {
+		return fmt.Errorf("watchdogジョブの登録に失敗: %w", err)
+	}
+	slog.Debug("systemdのwatchdogを有効にしました", "interval", interval)
+	return nil
+}
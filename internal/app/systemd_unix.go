@@ -0,0 +1,18 @@
+//go:build unix
+
+package app
+
+import (
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// monotonicNow は CLOCK_MONOTONIC の現在値を返す。
+func monotonicNow() (time.Duration, error) {
+	var now unix.Timespec
+	if err := unix.ClockGettime(unix.CLOCK_MONOTONIC, &now); err != nil {
+		return 0, err
+	}
+	return time.Duration(now.Nano()), nil
+}
@@ -0,0 +1,11 @@
+package app
+
+import (
+	"errors"
+	"time"
+)
+
+// monotonicNow はsystemdのないWindowsでは使わない。
+func monotonicNow() (time.Duration, error) {
+	return 0, errors.ErrUnsupported
+}
@@ -0,0 +1,244 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/rakku1234/linux-traffic-checker/notify"
+)
+
+// QuotaAction は quota_actions の1つで、期間の通信量が上限に届いたときに実行するコマンドか適用する tc の帯域制限。
+// 次の期間が始まると元に戻す。
+type QuotaAction struct {
+	// "command" はコマンドを実行し、"tc" は tc で帯域を制限する。
+	Type string `json:"type"`
+	// type "command" で上限に達したときに実行するコマンドと、次の期間の始めに実行するコマンド（省略可）。
+	Command     []string `json:"command"`
+	UndoCommand []string `json:"undo_command"`
+	// type "tc" で制限する速度（tc の表記、例 "1mbit"）と方向（"tx"（既定）、"rx"、"both"）。
+	Rate      string `json:"rate"`
+	Direction string `json:"direction"`
+	// type "tc" で制限するインターフェース。省略すると監視するインターフェース。
+	Interface string `json:"interface"`
+}
+
+// capReached は期間の通信量が quota_actions の対象とする上限（cap_basis の上限のいずれかか quota_bytes）に
+// 届いたかどうかを返す。
+func capReached(config *Config, usedRX, usedTX *big.Int) bool {
+	for _, check := range capChecks(config, usedRX, usedTX) {
+		if check.limit > 0 && check.used.Cmp(big.NewInt(check.limit)) >= 0 {
+			return true
+		}
+	}
+	return config.QuotaBytes > 0 && countedTotal(config, usedRX, usedTX).Cmp(big.NewInt(config.QuotaBytes)) >= 0
+}
+
+// checkQuotaActions は上限に届いたときに期間ごとに1回 quota_actions を適用し、インターフェースを制限したことを知らせる。
+// 読み取りのたびに再試行しないよう、実行に失敗しても Throttled を設定する。
+func checkQuotaActions(config *Config, stats *Stats, monthKey string, usedRX, usedTX *big.Int, budget *RetryBudget) {
+	if len(config.QuotaActions) == 0 || stats.Throttled == monthKey || !capReached(config, usedRX, usedTX) {
+		return
+	}
+	if dryRun {
+		slog.Info("[dry-run] quota_actions の実行をスキップします", "interface", config.Interface, "actions", len(config.QuotaActions))
+		return
+	}
+
+	stats.Throttled = monthKey
+	slog.Warn("通信量が上限に達したため quota_actions を実行します", "interface", config.Interface, "period", monthKey)
+	var fields []notify.EmbedField
+	for _, action := range config.QuotaActions {
+		err := applyQuotaAction(config, &action, monthKey, usedRX, usedTX)
+		value := tr("実行しました")
+		if err != nil {
+			slog.Error("quota_actions の実行に失敗しました", "interface", config.Interface, "action", action.describe(config), "error", err)
+			value = fmt.Sprintf(tr("失敗しました: %s"), err)
+		}
+		fields = append(fields, notify.EmbedField{Name: action.describe(config), Value: value, Inline: false})
+	}
+
+	embed := notify.Embed{
+		Title:     fmt.Sprintf(tr("%s の通信量が上限に達したため制限しました"), interfaceDisplayName(config)),
+		Color:     0xff0000,
+		Timestamp: clock.Now().UTC().Format(time.RFC3339),
+		Fields:    fields,
+		Interface: embedInterface(config),
+	}
+	err := notifyAll(config, budget, func(notifier Notifier) error {
+		return notifier.SendEmbeds(notify.KindAlert, []notify.Embed{embed})
+	})
+	if err != nil {
+		slog.Error("通知の送信エラー", "error", err)
+	}
+}
+
+// releaseQuotaActions は制限した期間の後に新しい期間が始まると quota_actions を取り消し、制限を解除したことを知らせる。
+func releaseQuotaActions(config *Config, stats *Stats, budget *RetryBudget) {
+	if stats.Throttled == "" {
+		return
+	}
+	if dryRun {
+		slog.Info("[dry-run] quota_actions の解除をスキップします", "interface", config.Interface)
+		return
+	}
+
+	period := stats.Throttled
+	stats.Throttled = ""
+	slog.Info("新しい期間が始まったため quota_actions を解除します", "interface", config.Interface, "period", period)
+	for _, action := range config.QuotaActions {
+		if err := undoQuotaAction(config, &action, period); err != nil {
+			slog.Error("quota_actions の解除に失敗しました", "interface", config.Interface, "action", action.describe(config), "error", err)
+		}
+	}
+
+	embed := notify.Embed{
+		Title:     fmt.Sprintf(tr("%s の新しい期間が始まったため制限を解除しました"), interfaceDisplayName(config)),
+		Color:     embedColor(config),
+		Timestamp: clock.Now().UTC().Format(time.RFC3339),
+		Interface: embedInterface(config),
+	}
+	err := notifyAll(config, budget, func(notifier Notifier) error {
+		return notifier.SendEmbeds(notify.KindReport, []notify.Embed{embed})
+	})
+	if err != nil {
+		slog.Error("通知の送信エラー", "error", err)
+	}
+}
+
+func (action *QuotaAction) device(config *Config) string {
+	if action.Interface != "" {
+		return action.Interface
+	}
+	if resolved, err := resolveInterface(config); err == nil {
+		return resolved.Interface
+	}
+	return config.Interface
+}
+
+// describe はログと通知に使う処理の名前を返す。
+func (action *QuotaAction) describe(config *Config) string {
+	if action.Type == "tc" {
+		return fmt.Sprintf(tr("%s の帯域を %s に制限（%s）"), action.device(config), action.Rate, action.Direction)
+	}
+	return strings.Join(action.Command, " ")
+}
+
+// tcCommands は帯域制限を適用する tc のコマンドで、送信側にはトークンバケット、受信側の qdisc には
+// 制限を超えて届いたパケットを破棄するポリサーを設定する。
+func (action *QuotaAction) tcCommands(device string) [][]string {
+	var commands [][]string
+	if action.Direction != "rx" {
+		commands = append(commands, []string{"qdisc", "replace", "dev", device, "root", "tbf", "rate", action.Rate, "burst", "32kbit", "latency", "400ms"})
+	}
+	if action.Direction != "tx" {
+		commands = append(commands,
+			[]string{"qdisc", "replace", "dev", device, "handle", "ffff:", "ingress"},
+			[]string{"filter", "replace", "dev", device, "parent", "ffff:", "protocol", "all", "prio", "1", "handle", "1", "matchall", "action", "police", "rate", action.Rate, "burst", "32k", "drop"},
+		)
+	}
+	return commands
+}
+
+func applyQuotaAction(config *Config, action *QuotaAction, period string, usedRX, usedTX *big.Int) error {
+	if action.Type == "tc" {
+		for _, args := range action.tcCommands(action.device(config)) {
+			if err := runActionCommand(config, append([]string{"tc"}, args...), nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return runActionCommand(config, action.Command, []string{
+		"LTC_INTERFACE=" + interfaceDisplayName(config),
+		"LTC_PERIOD=" + period,
+		"LTC_USED_BYTES=" + countedTotal(config, usedRX, usedTX).String(),
+	})
+}
+
+func undoQuotaAction(config *Config, action *QuotaAction, period string) error {
+	if action.Type == "tc" {
+		device := action.device(config)
+		var errs []error
+		if action.Direction != "rx" {
+			errs = append(errs, runActionCommand(config, []string{"tc", "qdisc", "del", "dev", device, "root"}, nil))
+		}
+		if action.Direction != "tx" {
+			errs = append(errs, runActionCommand(config, []string{"tc", "qdisc", "del", "dev", device, "ingress"}, nil))
+		}
+		return errors.Join(errs...)
+	}
+	if len(action.UndoCommand) == 0 {
+		return nil
+	}
+	return runActionCommand(config, action.UndoCommand, []string{
+		"LTC_INTERFACE=" + interfaceDisplayName(config),
+		"LTC_PERIOD=" + period,
+	})
+}
+
+// runActionCommand は quota_actions のコマンドをシェルを通さずに、on_quota_exceeded_timeout を上限に実行する。
+func runActionCommand(config *Config, command, env []string) error {
+	timeout, _ := time.ParseDuration(config.OnQuotaExceededTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Env = append(os.Environ(), env...)
+	output, err := cmd.CombinedOutput()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%s がタイムアウトしました（%s）", command[0], timeout)
+	}
+	if err != nil {
+		if text := strings.TrimSpace(string(output)); text != "" {
+			return fmt.Errorf("%w: %s", err, text)
+		}
+		return err
+	}
+	slog.Info("quota_actions のコマンドを実行しました", "command", strings.Join(command, " "), "output", strings.TrimSpace(string(output)))
+	return nil
+}
+
+func validateQuotaActions(config *Config) error {
+	if len(config.QuotaActions) == 0 {
+		return nil
+	}
+	if config.CapBytes <= 0 && config.RXCapBytes <= 0 && config.TXCapBytes <= 0 && config.QuotaBytes <= 0 {
+		return fmt.Errorf("quota_actions を使う場合は cap_bytes・rx_cap_bytes・tx_cap_bytes・quota_bytes のいずれかを指定してください")
+	}
+	if timeout, err := time.ParseDuration(config.OnQuotaExceededTimeout); err != nil || timeout <= 0 {
+		return fmt.Errorf("on_quota_exceeded_timeout が不正です: %q", config.OnQuotaExceededTimeout)
+	}
+	for i, action := range config.QuotaActions {
+		switch action.Type {
+		case "command":
+			if len(action.Command) == 0 || action.Command[0] == "" {
+				return fmt.Errorf("quota_actions[%d] の command には実行するコマンドを指定してください", i)
+			}
+			if len(action.UndoCommand) > 0 && action.UndoCommand[0] == "" {
+				return fmt.Errorf("quota_actions[%d] の undo_command の先頭には実行するコマンドを指定してください", i)
+			}
+		case "tc":
+			if action.Rate == "" {
+				return fmt.Errorf("quota_actions[%d] の rate に制限する速度（例 \"1mbit\"）を指定してください", i)
+			}
+			switch action.Direction {
+			case "tx", "rx", "both":
+			default:
+				return fmt.Errorf("quota_actions[%d] の direction は tx, rx, both のいずれかを指定してください: %q", i, action.Direction)
+			}
+			if action.Interface == "" && (config.aggregate() || config.SNMPHost != "") {
+				return fmt.Errorf("quota_actions[%d] の interface に制限するインターフェースを指定してください", i)
+			}
+		default:
+			return fmt.Errorf("quota_actions[%d] の type は command, tc のいずれかを指定してください: %q", i, action.Type)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rakku1234/linux-traffic-checker/notify"
+)
+
+const timeseriesTimeout = 10 * time.Second
+
+// lineTagEscaper は InfluxDB のラインプロトコルのタグのキーと値をエスケープする。
+var lineTagEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+
+// timeseriesLine は1回の読み取りを、前回の読み取りからの増分を整数のフィールドにした InfluxDB のラインプロトコルの行にする。
+func timeseriesLine(config *Config, host string, deltaRX, deltaTX *big.Int, elapsed time.Duration, now time.Time) string {
+	return fmt.Sprintf("%s,host=%s,interface=%s rx_bytes=%si,tx_bytes=%si,interval_seconds=%g %d\n",
+		lineTagEscaper.Replace(config.TimeseriesMeasurement),
+		lineTagEscaper.Replace(host),
+		lineTagEscaper.Replace(interfaceDisplayName(config)),
+		deltaRX.String(), deltaTX.String(), elapsed.Seconds(), now.Unix())
+}
+
+// writeTimeseries は前回から今回の読み取りまでの通信量を timeseries_url に送る。InfluxDB v2 と VictoriaMetrics は
+// どちらも /api/v2/write でラインプロトコルを受け付け、VictoriaMetrics は org と bucket を無視する。
+// 次の読み取りは新しいカウンタから続けるので、書き込みの失敗はログに残すだけ。
+func writeTimeseries(config *Config, lastRX, lastTX, currentRX, currentTX *big.Int, lastReadAt, now time.Time, aggregate bool) {
+	if config.TimeseriesURL == "" {
+		return
+	}
+	deltaRX, _ := readDelta(lastRX, currentRX, aggregate)
+	deltaTX, _ := readDelta(lastTX, currentTX, aggregate)
+	host, err := os.Hostname()
+	if err != nil {
+		host = "localhost"
+	}
+	line := timeseriesLine(config, host, deltaRX, deltaTX, now.Sub(lastReadAt), now)
+	if dryRun {
+		slog.Info("[dry-run] 時系列データベースへの書き込みをスキップします", "line", strings.TrimSpace(line))
+		return
+	}
+
+	err = postTimeseries(config, line)
+	if err != nil {
+		slog.Error("時系列データベースへの書き込みエラー", "interface", interfaceDisplayName(config), "error", err)
+	}
+}
+
+func postTimeseries(config *Config, line string) error {
+	query := url.Values{"precision": {"s"}}
+	if config.TimeseriesOrg != "" {
+		query.Set("org", config.TimeseriesOrg)
+	}
+	if config.TimeseriesBucket != "" {
+		query.Set("bucket", config.TimeseriesBucket)
+	}
+	endpoint := strings.TrimSuffix(config.TimeseriesURL, "/") + "/api/v2/write?" + query.Encode()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeseriesTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader([]byte(line)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if config.TimeseriesToken != "" {
+		req.Header.Set("Authorization", "Token "+config.TimeseriesToken)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(resp.Body)
+		return notify.NewHTTPStatusError("timeseries", resp, body)
+	}
+	return nil
+}
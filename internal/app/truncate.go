@@ -0,0 +1,136 @@
+package app
+
+import (
+	"log/slog"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rakku1234/linux-traffic-checker/notify"
+)
+
+func embedLength(embed *notify.Embed) int {
+	length := utf8.RuneCountInString(embed.Title)
+	for _, field := range embed.Fields {
+		length += utf8.RuneCountInString(field.Name) + utf8.RuneCountInString(field.Value)
+	}
+	if embed.Footer != nil {
+		length += utf8.RuneCountInString(embed.Footer.Text)
+	}
+	return length
+}
+
+func fieldPriority(config *Config, field *notify.EmbedField) int {
+	switch field.Name {
+	case fieldLabel(config, "total"):
+		return 0
+	case fieldLabel(config, "rx"), fieldLabel(config, "tx"):
+		return 1
+	default:
+		return 2
+	}
+}
+
+// Discord が受け付ける埋め込みのタイトルの長さと、1つのメッセージの埋め込みの合計の長さ。
+const (
+	discordTitleLimit   = 256
+	discordMessageLimit = 6000
+)
+
+// limitDiscordEmbeds はスタイルとテストモードの印を付けた後の1つのメッセージの埋め込みを、
+// max_message_length と Discord の上限に収める。
+func limitDiscordEmbeds(config *Config, embeds []notify.Embed) []notify.Embed {
+	limit := discordMessageLimit
+	if configured := config.MaxMessageLength["discord"]; configured > 0 {
+		limit = min(limit, configured)
+	}
+	limited := limitEmbeds(config, "discord", limit, embeds)
+	for i := range limited {
+		limited[i].Title = notify.Truncate(limited[i].Title, discordTitleLimit)
+	}
+	return limited
+}
+
+// limitEmbeds は埋め込みの長さの合計が limit に収まるよう省略した新しいスライスを返す。フッター、優先度の低い項目の順に
+// 省き、それでも長ければタイトルを切り詰める。合計の埋め込みは最後にあるので、同じ優先度なら前の埋め込みから省く。
+// embeds は他の通知先と共有しているので変更しない。
+func limitEmbeds(config *Config, notifier string, limit int, embeds []notify.Embed) []notify.Embed {
+	limited := make([]notify.Embed, len(embeds))
+	total := 0
+	for i, embed := range embeds {
+		embed.Fields = slices.Clone(embed.Fields)
+		limited[i] = embed
+		total += embedLength(&embed)
+	}
+	if limit <= 0 || total <= limit {
+		return limited
+	}
+	slog.Warn("メッセージが長すぎるため一部を省略しました", "notifier", notifier, "limit", limit)
+
+	for i := range limited {
+		if total <= limit {
+			return limited
+		}
+		if footer := limited[i].Footer; footer != nil {
+			total -= utf8.RuneCountInString(footer.Text)
+			limited[i].Footer = nil
+		}
+	}
+	for priority := 2; priority >= 0; priority-- {
+		for i := range limited {
+			fields := limited[i].Fields
+			for j := len(fields) - 1; j >= 0 && total > limit; j-- {
+				if fieldPriority(config, &fields[j]) == priority {
+					total -= utf8.RuneCountInString(fields[j].Name) + utf8.RuneCountInString(fields[j].Value)
+					fields = slices.Delete(fields, j, j+1)
+				}
+			}
+			limited[i].Fields = fields
+		}
+	}
+	for i := range limited {
+		if total <= limit {
+			break
+		}
+		length := utf8.RuneCountInString(limited[i].Title)
+		limited[i].Title = notify.Truncate(limited[i].Title, length-(total-limit))
+		total -= length - utf8.RuneCountInString(limited[i].Title)
+	}
+	return limited
+}
+
+// limitLines は title と lines を改行でつないだ本文が max_message_length に収まるよう、limitEmbeds と同じく
+// 優先度の低い行から省き、それでも長ければタイトルを切り詰める。lines は変更しない。
+func limitLines(config *Config, notifier, title string, lines []string) (string, []string) {
+	limit := config.MaxMessageLength[notifier]
+	length := func() int {
+		return utf8.RuneCountInString(title + "\n" + strings.Join(lines, "\n"))
+	}
+	if limit <= 0 || length() <= limit {
+		return title, lines
+	}
+	slog.Warn("メッセージが長すぎるため一部を省略しました", "notifier", notifier, "limit", limit)
+
+	lines = slices.Clone(lines)
+	for priority := 2; priority >= 0; priority-- {
+		for i := len(lines) - 1; i >= 0 && length() > limit; i-- {
+			name, _, _ := strings.Cut(lines[i], ": ")
+			if fieldPriority(config, &notify.EmbedField{Name: name}) == priority {
+				lines = slices.Delete(lines, i, i+1)
+			}
+		}
+	}
+	if over := length() - limit; over > 0 {
+		title = notify.Truncate(title, utf8.RuneCountInString(title)-over)
+	}
+	return title, lines
+}
+
+func limitText(config *Config, notifier, text string) string {
+	limit := config.MaxMessageLength[notifier]
+	if limit <= 0 || utf8.RuneCountInString(text) <= limit {
+		return text
+	}
+	slog.Warn("メッセージが長すぎるため一部を省略しました", "notifier", notifier, "limit", limit)
+	return notify.Truncate(text, limit)
+}
@@ -0,0 +1,121 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rakku1234/linux-traffic-checker/notify"
+)
+
+func TestLimitEmbedsCountsWholeMessage(t *testing.T) {
+	config := &Config{}
+	interfaceEmbed := func(name string) notify.Embed {
+		return notify.Embed{
+			Title: name,
+			Fields: []notify.EmbedField{
+				{Name: fieldLabel(config, "rx"), Value: "1.00 GiB"},
+				{Name: fieldLabel(config, "tx"), Value: "2.00 GiB"},
+				{Name: fieldLabel(config, "total"), Value: "3.00 GiB"},
+				{Name: "上位", Value: strings.Repeat("x", 40)},
+			},
+			Footer: &notify.EmbedFooter{Text: strings.Repeat("f", 20)},
+		}
+	}
+	embeds := []notify.Embed{interfaceEmbed("eth0"), interfaceEmbed("eth1")}
+	single := embedLength(&embeds[0])
+
+	// 1つずつなら収まる長さでも、2つ合わせると超える。
+	limited := limitEmbeds(config, "discord", single+10, embeds)
+	total := 0
+	for i := range limited {
+		total += embedLength(&limited[i])
+		found := false
+		for _, field := range limited[i].Fields {
+			found = found || field.Name == fieldLabel(config, "total")
+		}
+		if !found {
+			t.Errorf("embed %d lost the total: %+v", i, limited[i].Fields)
+		}
+	}
+	if total > single+10 {
+		t.Errorf("the message is %d long, want at most %d", total, single+10)
+	}
+	if len(embeds[0].Fields) != 4 || embeds[0].Footer == nil {
+		t.Error("limitEmbeds changed the embeds it was given")
+	}
+}
+
+func TestLimitDiscordEmbeds(t *testing.T) {
+	long := notify.Embed{Title: strings.Repeat("t", 300)}
+	fields := make([]notify.EmbedField, 10)
+	for i := range fields {
+		fields[i] = notify.EmbedField{Name: "項目", Value: strings.Repeat("v", 1000)}
+	}
+	large := []notify.Embed{{Title: "a", Fields: fields}, {Title: "b", Fields: fields}}
+
+	tests := []struct {
+		name   string
+		config Config
+		embeds []notify.Embed
+		want   int
+	}{
+		{"タイトルの上限", Config{}, []notify.Embed{long}, discordTitleLimit},
+		{"メッセージの上限", Config{}, large, discordMessageLimit},
+		// 設定した長さが Discord の上限より短ければそちらを使う。
+		{"max_message_length", Config{MaxMessageLength: map[string]int{"discord": 2500}}, large, 2500},
+	}
+	for _, tt := range tests {
+		total := 0
+		for _, embed := range limitDiscordEmbeds(&tt.config, tt.embeds) {
+			total += embedLength(&embed)
+		}
+		if total > tt.want {
+			t.Errorf("%s: %d long, want at most %d", tt.name, total, tt.want)
+		}
+	}
+}
+
+func TestLimitLines(t *testing.T) {
+	config := &Config{MaxMessageLength: map[string]int{"telegram": 40}}
+	lines := []string{
+		fieldLabel(config, "rx") + ": 1.00 GiB",
+		fieldLabel(config, "tx") + ": 2.00 GiB",
+		fieldLabel(config, "total") + ": 3.00 GiB",
+		"eth0: 2.50 GiB",
+		"eth1: 0.50 GiB",
+	}
+	title, limited := limitLines(config, "telegram", "eth0 の通信量（2026年10月）", lines)
+
+	// 末尾を切るのではなく、内訳、受信・送信の順に省いて合計を残す。
+	if len(limited) == 0 || limited[len(limited)-1] != lines[2] {
+		t.Errorf("limited lines %q do not keep the total", limited)
+	}
+	if length := len([]rune(title + "\n" + strings.Join(limited, "\n"))); length > 40 {
+		t.Errorf("the text is %d long, want at most 40", length)
+	}
+	if len(lines) != 5 {
+		t.Error("limitLines changed the lines it was given")
+	}
+
+	// Slack はブロックにする前の行で省略するので、表示されるブロックにも合計が残る。
+	var payload struct {
+		Blocks []struct {
+			Fields []struct{ Text string }
+		}
+	}
+	slack := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&payload)
+	}))
+	t.Cleanup(slack.Close)
+	notifier := &SlackNotifier{config: &Config{SlackWebhookURL: slack.URL, MaxMessageLength: map[string]int{"slack": 40}}}
+	if err := notifier.post(title, lines, MessageStyle{}); err != nil {
+		t.Fatal(err)
+	}
+	fields := payload.Blocks[len(payload.Blocks)-1].Fields
+	if len(fields) == 0 || !strings.Contains(fields[len(fields)-1].Text, "3.00 GiB") {
+		t.Errorf("the Slack blocks %+v do not keep the total", payload.Blocks)
+	}
+}
@@ -0,0 +1,64 @@
+package app
+
+import (
+	"log/slog"
+
+	"github.com/rakku1234/linux-traffic-checker/notify"
+)
+
+// queueUnsent は送信できなかった期間の開始の埋め込みと締めた期間の記録を残し、失わずに後の実行で resendUnsent が
+// 送れるようにする。PendingDigest と同じく、複数のインターフェースを監視する場合は記録にインターフェース名が入る。
+func queueUnsent(config *Config, stats *Stats, starts []notify.Embed, records []PeriodRecord) {
+	if len(starts) == 0 && len(records) == 0 {
+		return
+	}
+	stats.UnsentStarts = append(stats.UnsentStarts, starts...)
+	stats.UnsentReports = append(stats.UnsentReports, records...)
+	err := config.store.Save(stats)
+	if err != nil {
+		slog.Error("統計ファイルの保存エラー", "error", err)
+		return
+	}
+	slog.Warn("送信できなかったレポートを次回の実行時に送り直します", "starts", len(stats.UnsentStarts), "reports", len(stats.UnsentReports))
+}
+
+// resendUnsent は残しておいた期間の開始の通知を、次に残しておいたレポートを古い順に送り、送れたものから空にする。
+// まだ失敗するものは次の実行まで残し、そのエラーを返す。
+func resendUnsent(config *Config, stats *Stats, budget *RetryBudget) error {
+	if len(stats.UnsentStarts) > 0 {
+		err := notifyAll(config, budget, func(notifier Notifier) error {
+			return notifier.SendEmbeds(notify.KindReport, stats.UnsentStarts)
+		})
+		if err != nil {
+			slog.Warn("未送信の開始の通知を送り直せませんでした", "starts", len(stats.UnsentStarts), "error", err)
+			return err
+		}
+		slog.Info("未送信だった開始の通知を送信しました", "starts", len(stats.UnsentStarts))
+		stats.UnsentStarts = nil
+	}
+	if len(stats.UnsentReports) == 0 {
+		return nil
+	}
+
+	embeds := make([]notify.Embed, len(stats.UnsentReports))
+	for i, record := range stats.UnsentReports {
+		before := previousRecord(historyFor(stats, record.Interface), record.Month)
+		embeds[i] = recordEmbed(scopeConfig(config, &record), &record, before)
+	}
+	const maxEmbeds = 10
+	for start := 0; start < len(embeds); start += maxEmbeds {
+		end := min(start+maxEmbeds, len(embeds))
+		err := notifyAll(config, budget, func(notifier Notifier) error {
+			return notifier.SendEmbeds(notify.KindReport, embeds[start:end])
+		})
+		if err != nil {
+			stats.UnsentReports = stats.UnsentReports[start:]
+			slog.Warn("未送信のレポートを送り直せませんでした", "reports", len(stats.UnsentReports), "error", err)
+			return err
+		}
+	}
+
+	slog.Info("未送信だったレポートを送信しました", "reports", len(stats.UnsentReports))
+	stats.UnsentReports = nil
+	return nil
+}
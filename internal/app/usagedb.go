@@ -0,0 +1,204 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rakku1234/linux-traffic-checker/collector"
+	"github.com/rakku1234/linux-traffic-checker/notify"
+	bolt "go.etcd.io/bbolt"
+)
+
+// 通信量のデータベースはインターフェースごとにバケットを持ち、その中の "daily" と "monthly" のバケットに
+// periodKey をキーにして Counter の値を入れる。統計ファイルと違い、期間が変わっても消さない。
+var (
+	dailyBucket   = []byte("daily")
+	monthlyBucket = []byte("monthly")
+)
+
+const usageDBTimeout = 5 * time.Second
+
+// recordUsage は前回から今回の読み取りまでの通信量を history_db に加える。読み取りの間が何日も空いた場合は、
+// それ以上正確には分からないので、間の日に時間に比例して振り分ける。
+func recordUsage(config *Config, lastRX, lastTX, currentRX, currentTX *big.Int, lastReadAt, now time.Time, aggregate bool) {
+	if config.HistoryDB == "" || config.historyReadOnly {
+		return
+	}
+	deltaRX, _ := readDelta(lastRX, currentRX, aggregate)
+	deltaTX, _ := readDelta(lastTX, currentTX, aggregate)
+	if deltaRX.Sign() == 0 && deltaTX.Sign() == 0 {
+		return
+	}
+	name := interfaceDisplayName(config)
+	if dryRun {
+		slog.Info("[dry-run] history_db への書き込みをスキップします", "interface", name, "rx", deltaRX.String(), "tx", deltaTX.String())
+		return
+	}
+
+	err := addUsage(config.HistoryDB, name, splitByDay(deltaRX, deltaTX, lastReadAt.In(now.Location()), now))
+	if err != nil {
+		slog.Error("history_db への書き込みエラー", "path", config.HistoryDB, "error", err)
+	}
+}
+
+// dayUsage は増分のうち1日に振り分けた分。
+type dayUsage struct {
+	day time.Time
+	collector.InterfaceStats
+}
+
+// splitByDay は rx と tx を `from` から `to` までの日に振り分ける。合計がぴったり合うよう、余りは最後の日に入れる。
+func splitByDay(rx, tx *big.Int, from, to time.Time) []dayUsage {
+	if !from.Before(to) {
+		var part dayUsage
+		part.day = to
+		part.RX.Set(rx)
+		part.TX.Set(tx)
+		return []dayUsage{part}
+	}
+
+	total := big.NewInt(int64(to.Sub(from)))
+	restRX, restTX := new(big.Int).Set(rx), new(big.Int).Set(tx)
+	var parts []dayUsage
+	for start := from; start.Before(to); {
+		dayStart, _, _ := periodBounds(periodKey("daily", start), to.Location())
+		end := dayStart.AddDate(0, 0, 1)
+		part := dayUsage{day: start}
+		if !end.Before(to) {
+			part.RX.Set(restRX)
+			part.TX.Set(restTX)
+			return append(parts, part)
+		}
+		share := big.NewInt(int64(end.Sub(start)))
+		part.RX.Quo(new(big.Int).Mul(rx, share), total)
+		part.TX.Quo(new(big.Int).Mul(tx, share), total)
+		restRX.Sub(restRX, &part.RX)
+		restTX.Sub(restTX, &part.TX)
+		parts = append(parts, part)
+		start = end
+	}
+	return parts
+}
+
+func addUsage(path, interfaceName string, parts []dayUsage) error {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: usageDBTimeout})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.Update(func(tx *bolt.Tx) error {
+		root, err := tx.CreateBucketIfNotExists([]byte(interfaceName))
+		if err != nil {
+			return err
+		}
+		for _, part := range parts {
+			for _, entry := range []struct {
+				bucket []byte
+				key    string
+			}{
+				{dailyBucket, periodKey("daily", part.day)},
+				{monthlyBucket, periodKey("monthly", part.day)},
+			} {
+				bucket, err := root.CreateBucketIfNotExists(entry.bucket)
+				if err != nil {
+					return err
+				}
+				var counter collector.InterfaceStats
+				if data := bucket.Get([]byte(entry.key)); data != nil {
+					if err := json.Unmarshal(data, &counter); err != nil {
+						return fmt.Errorf("%s の %s を解析できません: %w", interfaceName, entry.key, err)
+					}
+				}
+				counter.RX.Add(&counter.RX, &part.RX)
+				counter.TX.Add(&counter.TX, &part.TX)
+				data, err := json.Marshal(&counter)
+				if err != nil {
+					return err
+				}
+				if err := bucket.Put([]byte(entry.key), data); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// usageEntry は history_db から読み戻した1日または1か月。
+type usageEntry struct {
+	Key string
+	collector.InterfaceStats
+}
+
+// loadUsage はインターフェースの bucket から key までの項目を、古い順に最大 limit 件返す。データベースがなければ何も返さない。
+func loadUsage(path, interfaceName string, bucket []byte, key string, limit int) ([]usageEntry, error) {
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: usageDBTimeout, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var entries []usageEntry
+	err = db.View(func(tx *bolt.Tx) error {
+		root := tx.Bucket([]byte(interfaceName))
+		if root == nil {
+			return nil
+		}
+		b := root.Bucket(bucket)
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			if string(k) > key {
+				return nil
+			}
+			entry := usageEntry{Key: string(k)}
+			if err := json.Unmarshal(v, &entry.InterfaceStats); err != nil {
+				return fmt.Errorf("%s の %s を解析できません: %w", interfaceName, k, err)
+			}
+			entries = append(entries, entry)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	// キーは日付の順に並ぶので、ForEach は既に古い順に返している。
+	if len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+	return entries, nil
+}
+
+// usageHistoryField は history_db から、レポートする期間が始まる月までの月ごとの合計を並べる。
+func usageHistoryField(config *Config, record *PeriodRecord) (notify.EmbedField, bool) {
+	start, _, err := periodBounds(record.Month, config.location)
+	if err != nil {
+		return notify.EmbedField{}, false
+	}
+	entries, err := loadUsage(config.HistoryDB, interfaceDisplayName(config), monthlyBucket, periodKey("monthly", start), config.HistoryMonths)
+	if err != nil {
+		slog.Warn("history_db の読み込みエラー", "path", config.HistoryDB, "error", err)
+		return notify.EmbedField{}, false
+	}
+	if len(entries) == 0 {
+		return notify.EmbedField{}, false
+	}
+
+	lines := make([]string, len(entries))
+	for i, entry := range entries {
+		lines[i] = fmt.Sprintf("%s: %s", periodLabel(entry.Key), formatBytes(countedTotal(config, &entry.RX, &entry.TX)))
+	}
+	return notify.EmbedField{Name: fmt.Sprintf(tr("過去%dか月の通信量"), config.HistoryMonths), Value: strings.Join(lines, "\n"), Inline: false}, true
+}
@@ -0,0 +1,163 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	neturl "net/url"
+	"strconv"
+	"time"
+
+	"github.com/rakku1234/linux-traffic-checker/notify"
+	"github.com/robfig/cron/v3"
+)
+
+// validationCheck は -validate の結果の1行。hint は失敗した確認の下に表示する。
+type validationCheck struct {
+	name string
+	err  error
+	note string
+	hint string
+}
+
+type validationTarget struct {
+	name string
+	url  string
+}
+
+// validateTargets は設定で送信することになる通知先を返す。
+func validateTargets(config *Config) []validationTarget {
+	var targets []validationTarget
+	for _, name := range config.Notifiers {
+		switch name {
+		case "slack":
+			targets = append(targets, validationTarget{"slack_webhook_url", config.SlackWebhookURL})
+		case "telegram":
+			targets = append(targets, validationTarget{"telegram", notify.TelegramAPIURL})
+		case "webhook":
+			targets = append(targets, validationTarget{"generic_webhook_url", config.GenericWebhookURL})
+		case "collector":
+			targets = append(targets, validationTarget{"collector_url", config.CollectorURL})
+		case "ntfy":
+			targets = append(targets, validationTarget{"ntfy_url", config.NtfyURL})
+		case "gotify":
+			targets = append(targets, validationTarget{"gotify_url", config.GotifyURL})
+		case "email":
+			target := validationTarget{name: "smtp_host"}
+			if config.SMTPHost != "" {
+				target.url = "smtp://" + net.JoinHostPort(config.SMTPHost, strconv.Itoa(config.SMTPPort))
+			}
+			targets = append(targets, target)
+		default:
+			webhookURLs := config.webhookURLs()
+			if len(webhookURLs) == 0 && len(config.DiscordWebhooks) == 0 {
+				targets = append(targets, validationTarget{"discord_webhook_url", ""})
+			}
+			for i, webhookURL := range webhookURLs {
+				name := "discord_webhook_url"
+				if len(webhookURLs) > 1 {
+					name = fmt.Sprintf("Discord Webhook %d", i+1)
+				}
+				targets = append(targets, validationTarget{name, webhookURL})
+			}
+			for i, webhook := range config.DiscordWebhooks {
+				targets = append(targets, validationTarget{fmt.Sprintf("discord_webhooks[%d]", i), webhook.URL})
+			}
+			if config.AlertWebhookURL != "" {
+				targets = append(targets, validationTarget{"alert_webhook_url", config.AlertWebhookURL})
+			}
+		}
+	}
+	if config.PagerDutyRoutingKey != "" {
+		targets = append(targets, validationTarget{"pagerduty", notify.PagerDutyEventsURL})
+	}
+	return targets
+}
+
+// checkReachable は target のホストに HEAD リクエストを送る。HTTPの応答があれば届くものとし、
+// DNS・接続・TLS の失敗だけを報告する。smtp:// は接続だけ確かめる。
+func checkReachable(target string) error {
+	u, err := neturl.Parse(target)
+	if err != nil {
+		return err
+	}
+	if u.Scheme == "smtp" {
+		conn, err := net.DialTimeout("tcp", u.Host, 10*time.Second)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("http(s) のURLではありません")
+	}
+
+	client := &http.Client{Transport: httpClient.Transport, Timeout: 10 * time.Second}
+	resp, err := client.Head(u.Scheme + "://" + u.Host + "/")
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// runValidation は通知を送らず統計ファイルにも書き込まずに設定をこのホストで確かめ、すべての確認に通ったかどうかを返す。
+func runValidation(w io.Writer, config *Config) bool {
+	return printChecks(w, validationChecks(config))
+}
+
+func validationChecks(config *Config) []validationCheck {
+	checks := []validationCheck{
+		{name: "設定ファイル", note: "読み込みと検証に成功"},
+		{name: "timezone", note: config.location.String()},
+	}
+
+	for _, report := range config.reports {
+		_, err := cron.ParseStandard(report.Schedule)
+		checks = append(checks, validationCheck{name: "schedule（" + report.Period + "）", err: err, note: report.Schedule})
+	}
+	if config.DigestSchedule != "" {
+		_, err := cron.ParseStandard(config.DigestSchedule)
+		checks = append(checks, validationCheck{name: "digest_schedule", err: err, note: config.DigestSchedule})
+	}
+
+	scopes := interfaceScopes(config, &Stats{})
+	for _, scope := range scopes {
+		rx, tx, _, err := readCountersOnce(scope.config)
+		check := validationCheck{name: "インターフェース " + interfaceDisplayName(scope.config), err: err, hint: "ip link でインターフェース名を確認してください"}
+		if err == nil {
+			check.note = fmt.Sprintf("受信 %s / 送信 %s", formatBytes(&rx), formatBytes(&tx))
+		}
+		checks = append(checks, check)
+	}
+
+	for _, target := range validateTargets(config) {
+		check := validationCheck{name: "通知先 " + target.name, hint: "URLと、プロキシやファイアウォールの設定を確認してください"}
+		if target.url == "" {
+			check.err = fmt.Errorf("未設定です")
+		} else {
+			check.err = checkReachable(target.url)
+			check.note = "接続できました"
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// printChecks は確認ごとに1行書き、すべて通ったかどうかを返す。
+func printChecks(w io.Writer, checks []validationCheck) bool {
+	passed := true
+	for _, check := range checks {
+		if check.err != nil {
+			passed = false
+			fmt.Fprintf(w, "NG  %s: %v\n", check.name, check.err)
+			if check.hint != "" {
+				fmt.Fprintf(w, "    → %s\n", check.hint)
+			}
+			continue
+		}
+		fmt.Fprintf(w, "OK  %s: %s\n", check.name, check.note)
+	}
+	return passed
+}
@@ -0,0 +1,339 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"math/big"
+	"os"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// import と export サブコマンドの -from-vnstat と -format のフラグ。
+var (
+	vnstatInput  string
+	exportFormat string
+)
+
+// vnstatData はバイトで数えるバージョン2の形式の `vnstat --json` の出力。日ごとと月ごとの通信量だけを読み書きする。
+type vnstatData struct {
+	VnstatVersion string            `json:"vnstatversion"`
+	JSONVersion   string            `json:"jsonversion"`
+	Interfaces    []vnstatInterface `json:"interfaces"`
+}
+
+type vnstatDate struct {
+	Year  int `json:"year"`
+	Month int `json:"month"`
+	Day   int `json:"day,omitempty"`
+}
+
+type vnstatTime struct {
+	Hour   int `json:"hour"`
+	Minute int `json:"minute"`
+}
+
+type vnstatEntry struct {
+	ID        int        `json:"id"`
+	Date      vnstatDate `json:"date"`
+	Timestamp int64      `json:"timestamp"`
+	RX        *big.Int   `json:"rx"`
+	TX        *big.Int   `json:"tx"`
+}
+
+type vnstatInterface struct {
+	Name    string `json:"name"`
+	Alias   string `json:"alias"`
+	Created struct {
+		Date      vnstatDate `json:"date"`
+		Timestamp int64      `json:"timestamp"`
+	} `json:"created"`
+	Updated struct {
+		Date      vnstatDate `json:"date"`
+		Time      vnstatTime `json:"time"`
+		Timestamp int64      `json:"timestamp"`
+	} `json:"updated"`
+	Traffic struct {
+		Total struct {
+			RX *big.Int `json:"rx"`
+			TX *big.Int `json:"tx"`
+		} `json:"total"`
+		Day   []vnstatEntry `json:"day"`
+		Month []vnstatEntry `json:"month"`
+	} `json:"traffic"`
+}
+
+// monitoredNames は監視するインターフェースごとの通信量を history_db に入れる名前。
+func monitoredNames(config *Config) []string {
+	var names []string
+	for _, scope := range interfaceScopes(config, &Stats{}) {
+		names = append(names, interfaceDisplayName(scope.config))
+	}
+	return names
+}
+
+// vnstatUsage は vnstat のインターフェースの日と月を history_db の項目にする。vnstat は暦の月で数えるので、
+// billing_day が1以外なら月は日から合計する。その場合は vnstat が日を残している分までしか遡れない。
+func vnstatUsage(config *Config, iface *vnstatInterface) (daily, monthly []usageEntry) {
+	sums := map[string]*usageEntry{}
+	var order []string
+	for _, day := range iface.Traffic.Day {
+		if day.RX == nil || day.TX == nil {
+			continue
+		}
+		date := time.Date(day.Date.Year, time.Month(day.Date.Month), day.Date.Day, 0, 0, 0, 0, config.location)
+		entry := usageEntry{Key: periodKey("daily", date)}
+		entry.RX.Set(day.RX)
+		entry.TX.Set(day.TX)
+		daily = append(daily, entry)
+
+		key := periodKey("monthly", date)
+		sum, ok := sums[key]
+		if !ok {
+			sum = &usageEntry{Key: key}
+			sums[key] = sum
+			order = append(order, key)
+		}
+		sum.RX.Add(&sum.RX, day.RX)
+		sum.TX.Add(&sum.TX, day.TX)
+	}
+	if billingDay != 1 {
+		for _, key := range order {
+			monthly = append(monthly, *sums[key])
+		}
+		return daily, monthly
+	}
+	for _, month := range iface.Traffic.Month {
+		if month.RX == nil || month.TX == nil {
+			continue
+		}
+		entry := usageEntry{Key: fmt.Sprintf("%04d-%02d", month.Date.Year, month.Date.Month)}
+		entry.RX.Set(month.RX)
+		entry.TX.Set(month.TX)
+		monthly = append(monthly, entry)
+	}
+	return daily, monthly
+}
+
+// putUsage は history_db にまだない項目を保存し、保存した数を返す。自分で数えた日と月はそのまま残す。
+func putUsage(path, interfaceName string, bucketName []byte, entries []usageEntry) (int, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: usageDBTimeout})
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	stored := 0
+	err = db.Update(func(tx *bolt.Tx) error {
+		root, err := tx.CreateBucketIfNotExists([]byte(interfaceName))
+		if err != nil {
+			return err
+		}
+		bucket, err := root.CreateBucketIfNotExists(bucketName)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if bucket.Get([]byte(entry.Key)) != nil {
+				continue
+			}
+			data, err := json.Marshal(&entry.InterfaceStats)
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put([]byte(entry.Key), data); err != nil {
+				return err
+			}
+			stored++
+		}
+		return nil
+	})
+	return stored, err
+}
+
+func readVnstat(path string) (*vnstatData, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var vnstat vnstatData
+	if err := json.Unmarshal(data, &vnstat); err != nil {
+		return nil, fmt.Errorf("vnstat の出力を解析できません: %w", err)
+	}
+	if vnstat.JSONVersion != "2" {
+		return nil, fmt.Errorf("vnstat のJSONのバージョン %q には対応していません（vnstat 2.x の --json を使ってください）", vnstat.JSONVersion)
+	}
+	return &vnstat, nil
+}
+
+// runImport は `vnstat --json` から監視するインターフェースの日と月を history_db に取り込む。
+func runImport(config *Config) int {
+	if vnstatInput == "" {
+		slog.Error("-from-vnstat に vnstat --json の出力ファイル（標準入力なら -）を指定してください")
+		return 1
+	}
+	if config.HistoryDB == "" {
+		slog.Error("取り込み先の history_db が設定されていません")
+		return 1
+	}
+	vnstat, err := readVnstat(vnstatInput)
+	if err != nil {
+		slog.Error("vnstat の読み込みエラー", "path", vnstatInput, "error", err)
+		return 1
+	}
+
+	imported := 0
+	for _, name := range monitoredNames(config) {
+		var iface *vnstatInterface
+		for i := range vnstat.Interfaces {
+			if vnstat.Interfaces[i].Name == name {
+				iface = &vnstat.Interfaces[i]
+			}
+		}
+		if iface == nil {
+			slog.Warn("vnstat のデータにインターフェースがありません", "interface", name)
+			continue
+		}
+		daily, monthly := vnstatUsage(config, iface)
+		days, err := putUsage(config.HistoryDB, name, dailyBucket, daily)
+		if err == nil {
+			var months int
+			months, err = putUsage(config.HistoryDB, name, monthlyBucket, monthly)
+			slog.Info("vnstat の履歴を取り込みました", "interface", name, "days", days, "months", months,
+				"skipped", len(daily)+len(monthly)-days-months)
+		}
+		if err != nil {
+			slog.Error("history_db への書き込みエラー", "path", config.HistoryDB, "error", err)
+			return 1
+		}
+		imported++
+	}
+	if imported == 0 {
+		slog.Error("取り込めるインターフェースがありません")
+		return 1
+	}
+	return exitOK
+}
+
+func vnstatEntries(entries []usageEntry, layout string, loc *time.Location) []vnstatEntry {
+	out := make([]vnstatEntry, 0, len(entries))
+	for i, entry := range entries {
+		date, err := time.ParseInLocation(layout, entry.Key, loc)
+		if err != nil {
+			continue
+		}
+		item := vnstatEntry{
+			ID:        i,
+			Date:      vnstatDate{Year: date.Year(), Month: int(date.Month())},
+			Timestamp: date.Unix(),
+			RX:        new(big.Int).Set(&entry.RX),
+			TX:        new(big.Int).Set(&entry.TX),
+		}
+		if layout == "2006-01-02" {
+			item.Date.Day = date.Day()
+		}
+		out = append(out, item)
+	}
+	return out
+}
+
+// exportVnstat は vnstat のデータを読むツールでも読めるよう、history_db を `vnstat --json` の形式で書き出す。
+func exportVnstat(w io.Writer, config *Config) error {
+	now := config.now()
+	data := vnstatData{VnstatVersion: "2.9", JSONVersion: "2"}
+	for _, name := range monitoredNames(config) {
+		daily, err := loadUsage(config.HistoryDB, name, dailyBucket, "9999", math.MaxInt)
+		if err != nil {
+			return err
+		}
+		monthly, err := loadUsage(config.HistoryDB, name, monthlyBucket, "9999", math.MaxInt)
+		if err != nil {
+			return err
+		}
+
+		iface := vnstatInterface{Name: name}
+		iface.Traffic.Day = vnstatEntries(daily, "2006-01-02", now.Location())
+		iface.Traffic.Month = vnstatEntries(monthly, "2006-01", now.Location())
+		iface.Traffic.Total.RX, iface.Traffic.Total.TX = new(big.Int), new(big.Int)
+		for _, month := range iface.Traffic.Month {
+			iface.Traffic.Total.RX.Add(iface.Traffic.Total.RX, month.RX)
+			iface.Traffic.Total.TX.Add(iface.Traffic.Total.TX, month.TX)
+		}
+		created := now
+		if len(iface.Traffic.Day) > 0 {
+			created = time.Unix(iface.Traffic.Day[0].Timestamp, 0).In(now.Location())
+		}
+		if len(iface.Traffic.Month) > 0 {
+			created = time.Unix(iface.Traffic.Month[0].Timestamp, 0).In(now.Location())
+		}
+		iface.Created.Date = vnstatDate{Year: created.Year(), Month: int(created.Month()), Day: created.Day()}
+		iface.Created.Timestamp = created.Unix()
+		iface.Updated.Date = vnstatDate{Year: now.Year(), Month: int(now.Month()), Day: now.Day()}
+		iface.Updated.Time = vnstatTime{Hour: now.Hour(), Minute: now.Minute()}
+		iface.Updated.Timestamp = now.Unix()
+		data.Interfaces = append(data.Interfaces, iface)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(data)
+}
+
+// runExport は履歴を標準出力か -output に書き出す。統計ファイルの締めた期間か history_db の日と月を json か csv で、
+// または history_db を vnstat-json で書き出す。
+func runExport(config *Config) int {
+	if err := validateExportRange(); err != nil {
+		slog.Error(err.Error())
+		return 1
+	}
+	w := io.Writer(os.Stdout)
+	var file *os.File
+	if exportOutput != "" && exportOutput != "-" {
+		var err error
+		file, err = os.Create(exportOutput)
+		if err != nil {
+			slog.Error("出力ファイルを作成できません", "path", exportOutput, "error", err)
+			return 1
+		}
+		w = file
+	}
+
+	var err error
+	switch exportFormat {
+	case "vnstat-json":
+		if config.HistoryDB == "" {
+			err = errors.New("vnstat-json の出力には history_db が必要です")
+			break
+		}
+		err = exportVnstat(w, config)
+	case "json", "csv":
+		var rows []exportRow
+		rows, err = exportRows(config)
+		if err == nil {
+			err = exportRecords(w, exportFormat, rows)
+		}
+	default:
+		err = errors.New("-format には json, csv, vnstat-json のいずれかを指定してください")
+	}
+	if file != nil {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		slog.Error("エクスポートのエラー", "format", exportFormat, "error", err)
+		return 1
+	}
+	return exitOK
+}
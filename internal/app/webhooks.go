@@ -0,0 +1,83 @@
+package app
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+
+	"github.com/rakku1234/linux-traffic-checker/notify"
+	"github.com/rakku1234/linux-traffic-checker/report"
+)
+
+// DiscordWebhook は discord_webhooks の1つで、自分のボット名を持ち、指定した種類のメッセージと
+// インターフェースだけを受け取る Webhook。
+type DiscordWebhook struct {
+	URL string `json:"url"`
+	// 空なら bot_name を使う。
+	BotName string `json:"bot_name"`
+	// 送るメッセージの種類。"report"（定期レポート）と "alert"（アラート）から選ぶ。空なら全て。
+	Kinds []string `json:"kinds"`
+	// 送るインターフェース。名前のほか interface_pattern と同じパターンも使える。空なら全て。
+	Interfaces []string `json:"interfaces"`
+}
+
+// discordDestinations は Discord のメッセージを送りうる Webhook で、すべてを受け取る discord_webhook_url と
+// discord_webhook_urls、その後に discord_webhooks が続く。
+func (config *Config) discordDestinations() []DiscordWebhook {
+	var destinations []DiscordWebhook
+	for _, webhookURL := range config.webhookURLs() {
+		destinations = append(destinations, DiscordWebhook{URL: webhookURL})
+	}
+	return append(destinations, config.DiscordWebhooks...)
+}
+
+// embedInterface は config で組み立てた埋め込みが対象とするインターフェースを返す。
+// 複数のインターフェースのレポートの合計など、複数のインターフェースをまとめた埋め込みなら "" を返す。
+func embedInterface(config *Config) string {
+	if config.multiInterface() {
+		return ""
+	}
+	return interfaceDisplayName(config)
+}
+
+// filterEmbeds は kind のメッセージの埋め込みのうち webhook が受け取るものを返す。インターフェースのない埋め込みは
+// 設定全体についてのものなので、複数のインターフェースを監視する場合もすべての Webhook に送る。
+func (webhook *DiscordWebhook) filterEmbeds(config *Config, kind notify.Kind, embeds []notify.Embed) []notify.Embed {
+	if len(webhook.Kinds) > 0 && !slices.Contains(webhook.Kinds, string(kind)) {
+		return nil
+	}
+	if len(webhook.Interfaces) == 0 {
+		return embeds
+	}
+	var filtered []notify.Embed
+	for _, embed := range embeds {
+		name := embed.Interface
+		if name == "" {
+			name = embedInterface(config)
+		}
+		if name == "" || slices.ContainsFunc(webhook.Interfaces, func(pattern string) bool { return report.MatchInterface(pattern, name) }) {
+			filtered = append(filtered, embed)
+		}
+	}
+	return filtered
+}
+
+func validateDiscordWebhooks(config *Config) error {
+	for i, webhook := range config.DiscordWebhooks {
+		u, err := url.Parse(webhook.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("discord_webhooks[%d] の url が不正です: %q", i, webhook.URL)
+		}
+		for _, kind := range webhook.Kinds {
+			if kind != string(notify.KindReport) && kind != string(notify.KindAlert) {
+				return fmt.Errorf("discord_webhooks[%d] の kinds は report, alert のいずれかを指定してください: %q", i, kind)
+			}
+		}
+		for _, pattern := range webhook.Interfaces {
+			if err := report.ValidateInterfacePattern(pattern); err != nil {
+				return fmt.Errorf("discord_webhooks[%d]: %w", i, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package app
+
+import (
+	"log/slog"
+	"math/big"
+
+	"github.com/rakku1234/linux-traffic-checker/collector"
+	"github.com/rakku1234/linux-traffic-checker/report"
+)
+
+var (
+	counterWrap   = new(big.Int).Lsh(big.NewInt(1), 32)
+	counterHalf   = new(big.Int).Lsh(big.NewInt(1), 31)
+	counterWrap64 = new(big.Int).Lsh(big.NewInt(1), 64)
+	counterHalf64 = new(big.Int).Lsh(big.NewInt(1), 63)
+)
+
+// counterBits は桁あふれを検出するカウンタの幅。32ビットとして扱うのは counter_bits で指定した場合と、
+// SNMP で32ビットのカウンタしか読めなかった場合だけ。64ビットのカウンタが 2〜4 GiB からリセットされたのを、
+// 2^32 の桁あふれと取り違えないようにする。
+func counterBits(config *Config) int {
+	if config.CounterBits != 0 {
+		return config.CounterBits
+	}
+	if config.SNMPHost != "" {
+		snmpCounter32Mu.Lock()
+		defer snmpCounter32Mu.Unlock()
+		if snmpCounter32[config.SNMPHost] {
+			return 32
+		}
+	}
+	return 64
+}
+
+// wrapSize は last から current への変化が、リセットではなく bits 幅のカウンタの1回の桁あふれに見える場合に
+// 2^bits を返す。前回の値がその幅に収まって範囲の上半分にあり、新しい値が下半分にある場合がそれに当たる。
+// それ以外は nil を返す。
+func wrapSize(last, current *big.Int, bits int) *big.Int {
+	wrap, half := counterWrap64, counterHalf64
+	if bits == 32 {
+		wrap, half = counterWrap, counterHalf
+	}
+	if current.Cmp(last) >= 0 || last.Cmp(wrap) >= 0 || last.Cmp(half) < 0 || current.Cmp(half) >= 0 {
+		return nil
+	}
+	return wrap
+}
+
+// adjustWrap は前回の読み取りからカウンタが桁あふれした方向ごとに、ベースラインと前回の読み取りをカウンタの幅だけ
+// 下げ、桁あふれをまたいでも通信量と読み取りの間の増分を数え続けられるようにする。再起動後はカウンタが0から
+// 数え直すので減少は常にリセットで、それ以外の減少も同じくリセットの検出に任せる。
+func adjustWrap(config *Config, stats *Stats, lastRX, lastTX, currentRX, currentTX *big.Int) {
+	if stats.LastReadAt.IsZero() || rebootedSince(config, stats.LastReadAt) {
+		return
+	}
+	for _, counter := range []struct {
+		name          string
+		baseline      *big.Int
+		last, current *big.Int
+	}{
+		{"rx", &stats.RX, lastRX, currentRX},
+		{"tx", &stats.TX, lastTX, currentTX},
+	} {
+		wrap := wrapSize(counter.last, counter.current, counterBits(config))
+		if wrap == nil {
+			continue
+		}
+		slog.Warn("カウンタの桁あふれを検出したため補正しました", "interface", config.Interface, "direction", counter.name,
+			"bits", wrap.BitLen()-1, "last", counter.last.String(), "current", counter.current.String())
+		counter.baseline.Sub(counter.baseline, wrap)
+		counter.last.Sub(counter.last, wrap)
+	}
+}
+
+// adjustInterfaceWraps はインターフェースの合計に対して adjustWrap と同じことを行い、各インターフェースを
+// LastInterfaces の自身の前回の読み取りと比べる。桁あふれしたカウンタの幅だけ前回の合計を下げ、そのインターフェースが
+// 今期に数えられていれば、そのベースラインと合計のベースラインも下げる。
+func adjustInterfaceWraps(config *Config, stats *Stats, current map[string]*collector.InterfaceStats) {
+	if stats.LastReadAt.IsZero() || rebootedSince(config, stats.LastReadAt) {
+		return
+	}
+	for _, name := range report.SortedInterfaceNames(current) {
+		last, ok := stats.LastInterfaces[name]
+		if !ok {
+			continue
+		}
+		baseline := stats.Interfaces[name]
+		for _, direction := range []string{"rx", "tx"} {
+			lastValue, currentValue, lastTotal, total := &last.RX, &current[name].RX, &stats.LastRX, &stats.RX
+			var interfaceBaseline *big.Int
+			if baseline != nil {
+				interfaceBaseline = &baseline.RX
+			}
+			if direction == "tx" {
+				lastValue, currentValue, lastTotal, total = &last.TX, &current[name].TX, &stats.LastTX, &stats.TX
+				if baseline != nil {
+					interfaceBaseline = &baseline.TX
+				}
+			}
+
+			wrap := wrapSize(lastValue, currentValue, counterBits(config))
+			if wrap == nil {
+				continue
+			}
+			slog.Warn("カウンタの桁あふれを検出したため補正しました", "interface", name, "direction", direction,
+				"bits", wrap.BitLen()-1, "last", lastValue.String(), "current", currentValue.String())
+			lastTotal.Sub(lastTotal, wrap)
+			lastValue.Sub(lastValue, wrap)
+			if interfaceBaseline != nil {
+				interfaceBaseline.Sub(interfaceBaseline, wrap)
+				total.Sub(total, wrap)
+			}
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package app
+
+import (
+	"math/big"
+	"testing"
+	"time"
+)
+
+func bigPow2(bits uint, offset int64) *big.Int {
+	n := new(big.Int).Lsh(big.NewInt(1), bits)
+	return n.Add(n, big.NewInt(offset))
+}
+
+func TestWrapSize(t *testing.T) {
+	tests := []struct {
+		name          string
+		last, current *big.Int
+		bits          int
+		want          *big.Int
+	}{
+		{"32ビットの桁あふれ", bigPow2(32, -100), big.NewInt(50), 32, counterWrap},
+		{"32ビットの上限ちょうど", bigPow2(32, -1), big.NewInt(0), 32, counterWrap},
+		{"64ビットの桁あふれ", bigPow2(64, -1000), big.NewInt(10), 64, counterWrap64},
+		// 64ビットのカウンタが 3 GiB からリセットされても 2^32 の桁あふれとはみなさない。
+		{"64ビットの 3 GiB からのリセット", big.NewInt(3 << 30), big.NewInt(1000), 64, nil},
+		{"下半分からの減少はリセット", big.NewInt(1000), big.NewInt(10), 32, nil},
+		{"32ビットを超えた値の減少はリセット", bigPow2(40, 0), big.NewInt(5), 32, nil},
+		{"減少後も上半分ならリセット", bigPow2(32, -100), bigPow2(31, 5), 32, nil},
+		{"増加", big.NewInt(10), big.NewInt(20), 32, nil},
+		{"変化なし", big.NewInt(10), big.NewInt(10), 64, nil},
+	}
+	for _, tt := range tests {
+		got := wrapSize(tt.last, tt.current, tt.bits)
+		if (got == nil) != (tt.want == nil) || got != nil && got.Cmp(tt.want) != 0 {
+			t.Errorf("%s: wrapSize(%s, %s, %d) = %v, want %v", tt.name, tt.last, tt.current, tt.bits, got, tt.want)
+		}
+	}
+}
+
+func TestAdjustWrap(t *testing.T) {
+	recent := time.Now().Add(-time.Minute)
+	tests := []struct {
+		name       string
+		lastReadAt time.Time
+		bits       int
+		baselineRX *big.Int
+		lastRX     *big.Int
+		currentRX  *big.Int
+		// wantUsed は補正後の current - baseline。負なら補正せず、リセットの検出に任せている。
+		wantUsed *big.Int
+	}{
+		// ベースライン 2^32-1000 から 2^32-100 を経て 50 に戻ったのは1回の桁あふれ。
+		{"桁あふれ", recent, 32, bigPow2(32, -1000), bigPow2(32, -100), big.NewInt(50), big.NewInt(1050)},
+		{"64ビットの桁あふれ", recent, 0, bigPow2(64, -1000), bigPow2(64, -100), big.NewInt(50), big.NewInt(1050)},
+		// 幅を指定しなければ64ビットのカウンタとして扱い、3 GiB からの減少はリセットとして残す。
+		{"64ビットの 3 GiB からのリセット", recent, 0, big.NewInt(2 << 30), big.NewInt(3 << 30), big.NewInt(50), new(big.Int).Sub(big.NewInt(50), big.NewInt(2<<30))},
+		// 前回の読み取りの後に再起動していれば、減少はリセットとして残す。
+		{"再起動後", time.Unix(1, 0), 32, bigPow2(32, -1000), bigPow2(32, -100), big.NewInt(50), new(big.Int).Sub(big.NewInt(50), bigPow2(32, -1000))},
+		{"下半分からの減少", recent, 32, big.NewInt(1000), big.NewInt(5000), big.NewInt(50), big.NewInt(-950)},
+	}
+	for _, tt := range tests {
+		stats := &Stats{LastReadAt: tt.lastReadAt}
+		stats.RX.Set(tt.baselineRX)
+		stats.TX.SetInt64(100)
+		lastRX, lastTX := new(big.Int).Set(tt.lastRX), big.NewInt(200)
+		currentTX := big.NewInt(300)
+
+		adjustWrap(&Config{Interface: "eth0", CounterBits: tt.bits}, stats, lastRX, lastTX, tt.currentRX, currentTX)
+
+		if used := new(big.Int).Sub(tt.currentRX, &stats.RX); used.Cmp(tt.wantUsed) != 0 {
+			t.Errorf("%s: RX usage = %s, want %s", tt.name, used, tt.wantUsed)
+		}
+		if stats.TX.Int64() != 100 || lastTX.Int64() != 200 {
+			t.Errorf("%s: TX changed to baseline %s, last %s", tt.name, &stats.TX, lastTX)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+)
+
+// DiscordPayload は Discord の Webhook に送るメッセージの本文。
+type DiscordPayload struct {
+	Username string  `json:"username"`
+	Content  string  `json:"content,omitempty"`
+	Embeds   []Embed `json:"embeds"`
+}
+
+// Attachment はグラフなど、メッセージに添付するファイル。
+type Attachment struct {
+	Name string
+	Data []byte
+}
+
+// Encode は Webhook のメッセージを、添付ファイルがあればマルチパートのフォームデータとして符号化し、
+// その Content-Type とともに返す。
+func (payload *DiscordPayload) Encode(files []Attachment) (string, []byte, error) {
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(files) == 0 {
+		return "application/json", jsonData, nil
+	}
+
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	if err := writer.WriteField("payload_json", string(jsonData)); err != nil {
+		return "", nil, err
+	}
+	for i, file := range files {
+		part, err := writer.CreateFormFile(fmt.Sprintf("files[%d]", i), file.Name)
+		if err != nil {
+			return "", nil, err
+		}
+		if _, err := part.Write(file.Data); err != nil {
+			return "", nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return "", nil, err
+	}
+	return writer.FormDataContentType(), buf.Bytes(), nil
+}
+
+// PostDiscord は Encode した Webhook のメッセージを1回送る。
+func PostDiscord(client *http.Client, webhookURL, contentType string, body []byte) error {
+	resp, err := client.Post(webhookURL, contentType, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return NewHTTPStatusError("discord", resp, body)
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html/template"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// smtpTimeout は接続から QUIT までの SMTP のセッション全体の時間の上限。
+const smtpTimeout = 30 * time.Second
+
+// Email はチャットサービスが使えないネットワーク向けに、メッセージをHTMLの表にして SMTP サーバーから送る。
+// TLS が "tls" なら最初から TLS で、"starttls" なら STARTTLS で接続し、"none" なら暗号化しない。
+// Username が空なら認証しない。
+type Email struct {
+	Host     string
+	Port     int
+	TLS      string
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+// EmailTable はメールの表の1つで、見出しと行からなり、最初の行が列の見出し。
+// Color は見出しの色（例 "#ff0000"）で、空なら既定の色。
+type EmailTable struct {
+	Title string
+	Color string
+	Rows  [][]string
+}
+
+var emailTemplate = template.Must(template.New("email").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"></head>
+<body style="font-family: sans-serif">
+{{- range .}}
+<h2{{with .Color}} style="color: {{.}}"{{end}}>{{.Title}}</h2>
+<table style="border-collapse: collapse">
+{{- range $i, $row := .Rows}}
+<tr>{{range $row}}{{if eq $i 0}}<th style="border: 1px solid #ccc; padding: 4px 8px; text-align: left">{{.}}</th>{{else}}<td style="border: 1px solid #ccc; padding: 4px 8px">{{.}}</td>{{end}}{{end}}</tr>
+{{- end}}
+</table>
+{{- end}}
+</body></html>
+`))
+
+// Send は tables を並べたメールを、date を送信日時として1回の SMTP のセッションで送る。
+func (e *Email) Send(subject string, date time.Time, tables []EmailTable) error {
+	var body bytes.Buffer
+	if err := emailTemplate.Execute(&body, tables); err != nil {
+		return err
+	}
+	return e.sendMail(e.buildMail(subject, date, body.Bytes()))
+}
+
+// buildMail はメールのヘッダーとHTMLの本文を書く。長い行や ASCII 以外の文字がどのサーバーも通るよう、
+// 本文は base64 にする。
+func (e *Email) buildMail(subject string, date time.Time, html []byte) []byte {
+	var msg bytes.Buffer
+	header := func(name, value string) { fmt.Fprintf(&msg, "%s: %s\r\n", name, value) }
+	header("From", e.From)
+	header("To", strings.Join(e.To, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", subject))
+	header("Date", date.Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+	header("Content-Type", `text/html; charset="utf-8"`)
+	header("Content-Transfer-Encoding", "base64")
+	msg.WriteString("\r\n")
+
+	encoded := base64.StdEncoding.EncodeToString(html)
+	for len(encoded) > 76 {
+		msg.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	msg.WriteString(encoded + "\r\n")
+	return msg.Bytes()
+}
+
+func (e *Email) sendMail(msg []byte) error {
+	addr := net.JoinHostPort(e.Host, strconv.Itoa(e.Port))
+	tlsConfig := &tls.Config{ServerName: e.Host}
+
+	var conn net.Conn
+	var err error
+	dialer := &net.Dialer{Timeout: smtpTimeout}
+	if e.TLS == "tls" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("SMTPサーバーに接続できません: %w", err)
+	}
+	if err := conn.SetDeadline(time.Now().Add(smtpTimeout)); err != nil {
+		conn.Close()
+		return err
+	}
+	client, err := smtp.NewClient(conn, e.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if e.TLS == "starttls" {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return errors.New("SMTPサーバーが STARTTLS に対応していません")
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	if e.Username != "" {
+		auth := smtp.PlainAuth("", e.Username, e.Password, e.Host)
+		if err := client.Auth(auth); err != nil {
+			return fmt.Errorf("SMTPの認証に失敗しました: %w", err)
+		}
+	}
+
+	from, err := mail.ParseAddress(e.From)
+	if err != nil {
+		return err
+	}
+	if err := client.Mail(from.Address); err != nil {
+		return err
+	}
+	for _, to := range e.To {
+		address, err := mail.ParseAddress(to)
+		if err != nil {
+			return err
+		}
+		if err := client.Rcpt(address.Address); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
@@ -0,0 +1,47 @@
+package notify
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEmailBody(t *testing.T) {
+	email := &Email{From: "traffic@example.com", To: []string{"ops@example.com", "dev@example.com"}}
+	tables := []EmailTable{
+		{Title: "eth0 の通信量", Color: "#ff0000", Rows: [][]string{{"項目", "値"}, {"合計", "3.00 GiB"}}},
+		{Title: "eth1 の通信量", Rows: [][]string{{"項目", "値"}}},
+	}
+	var body strings.Builder
+	if err := emailTemplate.Execute(&body, tables); err != nil {
+		t.Fatal(err)
+	}
+	html := body.String()
+	for _, want := range []string{`<h2 style="color: #ff0000">eth0 の通信量</h2>`, "<h2>eth1 の通信量</h2>", "<th", "<td"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("no %q in\n%s", want, html)
+		}
+	}
+
+	date := time.Date(2026, time.October, 1, 9, 0, 0, 0, time.UTC)
+	msg := string(email.buildMail("通信量（2026年9月）", date, []byte(html)))
+	header, encoded, ok := strings.Cut(msg, "\r\n\r\n")
+	if !ok {
+		t.Fatalf("no header in %q", msg)
+	}
+	for _, want := range []string{"To: ops@example.com, dev@example.com\r\n", "Date: Thu, 01 Oct 2026 09:00:00 +0000\r\n", "Subject: =?utf-8?q?"} {
+		if !strings.Contains(header, want) {
+			t.Errorf("no %q in the header\n%s", want, header)
+		}
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(encoded, "\r\n"), "\r\n") {
+		if len(line) > 76 {
+			t.Errorf("body line is %d long, want at most 76", len(line))
+		}
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(encoded, "\r\n", ""))
+	if err != nil || string(decoded) != html {
+		t.Errorf("the body does not decode to the HTML: %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"strconv"
+	"time"
+)
+
+// HTTPStatusError は Webhook が想定外のステータスを返した場合のエラー。
+type HTTPStatusError struct {
+	Service    string
+	StatusCode int
+	Status     string
+	Body       string
+	// RetryAfter はサーバーが Retry-After で求めた待ち時間。なければ0。
+	RetryAfter time.Duration
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("%s API エラー: %s - %s", e.Service, e.Status, e.Body)
+}
+
+// Retryable は送り直せば成功するかもしれないかどうかを返す。レート制限とサーバーのエラーは該当し、
+// その他のクライアントのエラーは該当しない。
+func (e *HTTPStatusError) Retryable() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+func NewHTTPStatusError(service string, resp *http.Response, body []byte) *HTTPStatusError {
+	err := &HTTPStatusError{Service: service, StatusCode: resp.StatusCode, Status: resp.Status, Body: string(body)}
+	if seconds, parseErr := strconv.ParseFloat(resp.Header.Get("Retry-After"), 64); parseErr == nil && seconds > 0 {
+		err.RetryAfter = time.Duration(seconds * float64(time.Second))
+	}
+	return err
+}
+
+// PostJSON は payload をJSONにして、アクセストークンなどの header（nil でもよい）と共に url へ送る。
+// 2xx 以外の応答は HTTPStatusError を返す。
+func PostJSON(client *http.Client, service, url string, header http.Header, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	if header != nil {
+		req.Header = header
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		// URL にはボットのトークンが含まれうるので、エラーには含めない。
+		var urlErr *neturl.Error
+		if errors.As(err, &urlErr) {
+			return fmt.Errorf("%s への送信エラー: %w", service, urlErr.Err)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(resp.Body)
+		return NewHTTPStatusError(service, resp, body)
+	}
+	return nil
+}
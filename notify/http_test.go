@@ -0,0 +1,31 @@
+package notify
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestNewHTTPStatusErrorRetryAfter(t *testing.T) {
+	tests := []struct {
+		header string
+		want   time.Duration
+	}{
+		{"", 0},
+		{"2", 2 * time.Second},
+		{"0.5", 500 * time.Millisecond},
+		{"0", 0},
+		{"-1", 0},
+		// HTTP 日付の形式は解釈せず、既定の待ち時間に任せる。
+		{"Wed, 21 Oct 2026 07:28:00 GMT", 0},
+	}
+	for _, tt := range tests {
+		resp := &http.Response{StatusCode: http.StatusTooManyRequests, Status: "429 Too Many Requests", Header: http.Header{}}
+		if tt.header != "" {
+			resp.Header.Set("Retry-After", tt.header)
+		}
+		if got := NewHTTPStatusError("discord", resp, nil).RetryAfter; got != tt.want {
+			t.Errorf("Retry-After %q = %s, want %s", tt.header, got, tt.want)
+		}
+	}
+}
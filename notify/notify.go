@@ -0,0 +1,46 @@
+// Package notify はチャットサービスに送るメッセージと、通知先の実装が満たすインターフェース、
+// Discord・Slack・Telegram・Webhook・メール・ntfy・Gotify・PagerDuty への送信を提供する。
+// メッセージは Discord の埋め込みとして組み立てる。各サービスへの送信は表示の決まった題名と行を受け取り、
+// 設定の解釈や文言の翻訳、長さの上限に合わせた省略は呼び出す側が行う。
+package notify
+
+// Kind は定期レポートとアラートを区別し、通知先ごとに表示を変えられるようにする。
+type Kind string
+
+const (
+	KindReport Kind = "report"
+	KindAlert  Kind = "alert"
+)
+
+// Notifier は1つのチャットサービスにメッセージを届ける。
+type Notifier interface {
+	SendEmbeds(kind Kind, embeds []Embed) error
+}
+
+type Embed struct {
+	Title     string       `json:"title"`
+	URL       string       `json:"url,omitempty"`
+	Color     int          `json:"color"`
+	Fields    []EmbedField `json:"fields"`
+	Footer    *EmbedFooter `json:"footer,omitempty"`
+	Image     *EmbedImage  `json:"image,omitempty"`
+	Timestamp string       `json:"timestamp"`
+
+	// Interface は埋め込みが対象とするインターフェースで、インターフェースごとの振り分けに使う。
+	// 複数のインターフェースをまとめたものなら空。
+	Interface string `json:"-"`
+}
+
+type EmbedImage struct {
+	URL string `json:"url"`
+}
+
+type EmbedFooter struct {
+	Text string `json:"text"`
+}
+
+type EmbedField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
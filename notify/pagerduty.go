@@ -0,0 +1,68 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+)
+
+// PagerDutyEventsURL は PagerDuty の Events API v2 のURL。
+const PagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// PagerDuty はルーティングキー RoutingKey のサービスにインシデントを起こす。
+type PagerDuty struct {
+	RoutingKey string
+	Client     *http.Client
+}
+
+type pagerDutyEvent struct {
+	RoutingKey  string           `json:"routing_key"`
+	EventAction string           `json:"event_action"`
+	DedupKey    string           `json:"dedup_key"`
+	Payload     pagerDutyPayload `json:"payload"`
+}
+
+type pagerDutyPayload struct {
+	Summary  string `json:"summary"`
+	Source   string `json:"source"`
+	Severity string `json:"severity"`
+}
+
+// Trigger は critical のイベントを送る。同じ dedupKey のイベントは PagerDuty が1つのインシデントにまとめる。
+func (p *PagerDuty) Trigger(dedupKey, summary string) error {
+	source, err := os.Hostname()
+	if err != nil {
+		source = "linux-traffic-checker"
+	}
+
+	event := pagerDutyEvent{
+		RoutingKey:  p.RoutingKey,
+		EventAction: "trigger",
+		DedupKey:    dedupKey,
+		Payload: pagerDutyPayload{
+			Summary:  summary,
+			Source:   source,
+			Severity: "critical",
+		},
+	}
+
+	jsonData, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	resp, err := p.Client.Post(PagerDutyEventsURL, "application/json", bytes.NewReader(jsonData))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		body, _ := io.ReadAll(resp.Body)
+		return NewHTTPStatusError("PagerDuty", resp, body)
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package notify
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Ntfy は ntfy のトピックにメッセージを送り、ntfy のアプリがプッシュ通知で表示する。
+// Priority と AlertPriority は1から5の優先度で、アラートには警告のタグも付ける。
+type Ntfy struct {
+	URL           string
+	Topic         string
+	Token         string
+	Priority      int
+	AlertPriority int
+	Client        *http.Client
+}
+
+type ntfyMessage struct {
+	Topic    string   `json:"topic"`
+	Title    string   `json:"title"`
+	Message  string   `json:"message"`
+	Priority int      `json:"priority"`
+	Tags     []string `json:"tags,omitempty"`
+}
+
+// Post は kind に応じた優先度で message を送る。
+func (n *Ntfy) Post(kind Kind, title, message string) error {
+	payload := ntfyMessage{Topic: n.Topic, Title: title, Message: message, Priority: n.KindPriority(kind)}
+	if kind == KindAlert {
+		payload.Tags = []string{"warning"}
+	}
+	header := http.Header{}
+	if n.Token != "" {
+		header.Set("Authorization", "Bearer "+n.Token)
+	}
+	// ntfy はJSONのメッセージをルートのURLで受け取り、トピックは本文に書く。
+	return PostJSON(n.Client, "ntfy", strings.TrimSuffix(n.URL, "/")+"/", header, payload)
+}
+
+// KindPriority は kind のメッセージを送る優先度を返す。
+func (n *Ntfy) KindPriority(kind Kind) int {
+	if kind == KindAlert {
+		return n.AlertPriority
+	}
+	return n.Priority
+}
+
+// Gotify はトークンが Token の Gotify のアプリケーションのメッセージとして送る。
+// Priority と AlertPriority は1から10の優先度。
+type Gotify struct {
+	URL           string
+	Token         string
+	Priority      int
+	AlertPriority int
+	Client        *http.Client
+}
+
+type gotifyMessage struct {
+	Title    string `json:"title"`
+	Message  string `json:"message"`
+	Priority int    `json:"priority"`
+}
+
+// Post は kind に応じた優先度で message を送る。
+func (g *Gotify) Post(kind Kind, title, message string) error {
+	header := http.Header{}
+	header.Set("X-Gotify-Key", g.Token)
+	payload := gotifyMessage{Title: title, Message: message, Priority: g.KindPriority(kind)}
+	return PostJSON(g.Client, "gotify", strings.TrimSuffix(g.URL, "/")+"/message", header, payload)
+}
+
+// KindPriority は kind のメッセージを送る優先度を返す。
+func (g *Gotify) KindPriority(kind Kind) int {
+	if kind == KindAlert {
+		return g.AlertPriority
+	}
+	return g.Priority
+}
@@ -0,0 +1,59 @@
+package notify
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Slack は Incoming Webhook で Slack にメッセージを送る。
+type Slack struct {
+	WebhookURL string
+	Client     *http.Client
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type slackBlock struct {
+	Type   string      `json:"type"`
+	Text   *slackText  `json:"text,omitempty"`
+	Fields []slackText `json:"fields,omitempty"`
+}
+
+type slackPayload struct {
+	Text   string       `json:"text"`
+	Blocks []slackBlock `json:"blocks"`
+}
+
+// slackHeaderLimit は Slack のヘッダーブロックの文字数の上限。
+const slackHeaderLimit = 150
+
+// slackMessage は title をヘッダーに、"名前: 値" の lines をセクションのフィールドにする。
+// mention は通知の文に付け、ブロックには表示しない。
+func slackMessage(title string, lines []string, mention string) slackPayload {
+	payload := slackPayload{
+		Text:   title,
+		Blocks: []slackBlock{{Type: "header", Text: &slackText{Type: "plain_text", Text: Truncate(title, slackHeaderLimit)}}},
+	}
+	if mention != "" {
+		payload.Text = mention + " " + payload.Text
+	}
+	var fields []slackText
+	for _, line := range lines {
+		name, value, _ := strings.Cut(line, ": ")
+		fields = append(fields, slackText{Type: "mrkdwn", Text: fmt.Sprintf("*%s*\n%s", name, value)})
+	}
+	// Slack のセクションブロックのフィールドは10個まで。
+	for start := 0; start < len(fields); start += 10 {
+		payload.Blocks = append(payload.Blocks, slackBlock{Type: "section", Fields: fields[start:min(start+10, len(fields))]})
+	}
+	return payload
+}
+
+// Post は1つのメッセージを送る。長さの上限に合わせた省略は呼び出す側で済ませておく。
+func (s *Slack) Post(title string, lines []string, mention string) error {
+	return PostJSON(s.Client, "slack", s.WebhookURL, nil, slackMessage(title, lines, mention))
+}
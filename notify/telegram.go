@@ -0,0 +1,32 @@
+package notify
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// TelegramAPIURL は Telegram の Bot API のURL。
+const TelegramAPIURL = "https://api.telegram.org"
+
+// Telegram はボット BotToken から ChatID のチャットにメッセージを送る。
+type Telegram struct {
+	BotToken string
+	ChatID   string
+	Client   *http.Client
+}
+
+type telegramMessage struct {
+	ChatID string `json:"chat_id"`
+	Text   string `json:"text"`
+}
+
+// Post は title と lines を1行ずつ並べた文を送る。mention があれば先頭の行に置く。
+func (t *Telegram) Post(title string, lines []string, mention string) error {
+	text := title + "\n" + strings.Join(lines, "\n")
+	if mention != "" {
+		text = mention + "\n" + text
+	}
+	url := fmt.Sprintf("%s/bot%s/sendMessage", TelegramAPIURL, t.BotToken)
+	return PostJSON(t.Client, "telegram", url, nil, telegramMessage{ChatID: t.ChatID, Text: text})
+}
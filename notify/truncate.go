@@ -0,0 +1,17 @@
+package notify
+
+import "unicode/utf8"
+
+const ellipsis = "…"
+
+// Truncate は text が limit 文字を超える場合に、末尾を … にして limit 文字に収める。
+func Truncate(text string, limit int) string {
+	if utf8.RuneCountInString(text) <= limit {
+		return text
+	}
+	if limit <= 0 {
+		return ""
+	}
+	runes := []rune(text)
+	return string(runes[:limit-1]) + ellipsis
+}
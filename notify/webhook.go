@@ -0,0 +1,27 @@
+package notify
+
+import "net/http"
+
+// Webhook は表示を自分で組み立てる受け手のために、メッセージをJSONで URL に送る。
+type Webhook struct {
+	URL    string
+	Client *http.Client
+}
+
+type WebhookField struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// WebhookMessage は Webhook が送るJSON。Report はレポートの数値で、アラートでは nil。
+type WebhookMessage struct {
+	Kind    Kind           `json:"kind"`
+	Title   string         `json:"title"`
+	Mention string         `json:"mention,omitempty"`
+	Fields  []WebhookField `json:"fields,omitempty"`
+	Report  any            `json:"report,omitempty"`
+}
+
+func (w *Webhook) Post(message WebhookMessage) error {
+	return PostJSON(w.Client, "webhook", w.URL, nil, message)
+}
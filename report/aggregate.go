@@ -0,0 +1,193 @@
+package report
+
+import (
+	"fmt"
+	"log/slog"
+	"math/big"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rakku1234/linux-traffic-checker/collector"
+)
+
+// ArchivedInterface は期間の途中で見つからなくなったインターフェースの、それまでの通信量。
+type ArchivedInterface struct {
+	Name      string                    `json:"name"`
+	Month     string                    `json:"month"`
+	Used      *collector.InterfaceStats `json:"used"`
+	RemovedAt time.Time                 `json:"removed_at"`
+}
+
+// MatchInterface は名前を "veth*" のようなグロブと照合する。パターンが "^" で始まる場合は正規表現として照合する。
+func MatchInterface(pattern, name string) bool {
+	if strings.HasPrefix(pattern, "^") {
+		matched, _ := regexp.MatchString(pattern, name)
+		return matched
+	}
+	matched, _ := path.Match(pattern, name)
+	return matched
+}
+
+// IsInterfacePattern はインターフェース名がパターンとして書かれているかどうかを返す。
+func IsInterfacePattern(name string) bool {
+	return strings.HasPrefix(name, "^") || strings.ContainsAny(name, "*?[")
+}
+
+func ValidateInterfacePattern(pattern string) error {
+	var err error
+	if strings.HasPrefix(pattern, "^") {
+		_, err = regexp.Compile(pattern)
+	} else {
+		_, err = path.Match(pattern, "")
+	}
+	if err != nil {
+		return fmt.Errorf("インターフェースのパターンが不正です（%q）: %w", pattern, err)
+	}
+	return nil
+}
+
+// Excluded は name が patterns のいずれかに一致するかどうかを返す。
+func Excluded(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if MatchInterface(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func SumCounters(counters map[string]*collector.InterfaceStats) (big.Int, big.Int) {
+	var rx, tx big.Int
+	for _, counter := range counters {
+		rx.Add(&rx, &counter.RX)
+		tx.Add(&tx, &counter.TX)
+	}
+	return rx, tx
+}
+
+// InterfaceUsage はインターフェースごとの current と baseline の差を返す。カウンタが巻き戻ったインターフェースは除く。
+func InterfaceUsage(current, baseline map[string]*collector.InterfaceStats) map[string]*collector.InterfaceStats {
+	if current == nil {
+		return nil
+	}
+
+	usage := make(map[string]*collector.InterfaceStats, len(current))
+	for name, counter := range current {
+		base := baseline[name]
+		if base == nil {
+			base = &collector.InterfaceStats{}
+		}
+		used := &collector.InterfaceStats{}
+		used.RX.Sub(&counter.RX, &base.RX)
+		used.TX.Sub(&counter.TX, &base.TX)
+		if used.RX.Sign() < 0 || used.TX.Sign() < 0 {
+			continue
+		}
+		usage[name] = used
+	}
+	return usage
+}
+
+func SortedInterfaceNames(usage map[string]*collector.InterfaceStats) []string {
+	names := make([]string, 0, len(usage))
+	for name := range usage {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Table は rows をコードブロックの表にする。最初の列は左に、他の列は右に揃え、全角の文字は2文字分の幅で数える。
+func Table(rows [][]string) string {
+	widths := make([]int, len(rows[0]))
+	for _, row := range rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], displayWidth(cell))
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("```\n")
+	for _, row := range rows {
+		for i, cell := range row {
+			if i > 0 {
+				b.WriteString(" | ")
+			}
+			padding := strings.Repeat(" ", widths[i]-displayWidth(cell))
+			if i == 0 {
+				b.WriteString(cell + padding)
+			} else {
+				b.WriteString(padding + cell)
+			}
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("```")
+	return b.String()
+}
+
+func displayWidth(s string) int {
+	width := 0
+	for _, r := range s {
+		if r >= 0x1100 {
+			width += 2
+		} else {
+			width++
+		}
+	}
+	return width
+}
+
+// Baseline は期間の初めのインターフェースごとのカウンタと、その合計の RX と TX。
+// Last は最後に読み取ったカウンタで、見つからなくなったインターフェースの通信量を数えるのに使う。
+type Baseline struct {
+	RX, TX     *big.Int
+	Interfaces map[string]*collector.InterfaceStats
+	Last       map[string]*collector.InterfaceStats
+}
+
+// Reconcile は current にないインターフェースを基準から外し、その period の通信量を返す。
+// current に新しく現れたインターフェースは、今のカウンタを基準に加える。Interfaces が nil なら何もしない。
+func (b Baseline) Reconcile(current map[string]*collector.InterfaceStats, period string, now time.Time) []ArchivedInterface {
+	if b.Interfaces == nil {
+		return nil
+	}
+
+	var archived []ArchivedInterface
+	for _, name := range SortedInterfaceNames(b.Interfaces) {
+		if _, ok := current[name]; ok {
+			continue
+		}
+		base := b.Interfaces[name]
+		last := b.Last[name]
+		if last == nil {
+			last = base
+		}
+
+		used := &collector.InterfaceStats{}
+		used.RX.Sub(&last.RX, &base.RX)
+		used.TX.Sub(&last.TX, &base.TX)
+		if used.RX.Sign() >= 0 && used.TX.Sign() >= 0 {
+			archived = append(archived, ArchivedInterface{Name: name, Month: period, Used: used, RemovedAt: now})
+		}
+		b.RX.Sub(b.RX, &last.RX)
+		b.TX.Sub(b.TX, &last.TX)
+		delete(b.Interfaces, name)
+		slog.Info("インターフェースが見つからなくなったため、それまでの通信量を記録しました", "interface", name)
+	}
+
+	for _, name := range SortedInterfaceNames(current) {
+		if _, ok := b.Interfaces[name]; ok {
+			continue
+		}
+		counter := current[name]
+		b.Interfaces[name] = &collector.InterfaceStats{RX: *new(big.Int).Set(&counter.RX), TX: *new(big.Int).Set(&counter.TX)}
+		b.RX.Add(b.RX, &counter.RX)
+		b.TX.Add(b.TX, &counter.TX)
+		slog.Info("新しいインターフェースの記録を開始しました", "interface", name)
+	}
+	return archived
+}
@@ -0,0 +1,90 @@
+package report
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/rakku1234/linux-traffic-checker/collector"
+)
+
+func counters(values map[string][2]int64) map[string]*collector.InterfaceStats {
+	result := map[string]*collector.InterfaceStats{}
+	for name, value := range values {
+		counter := &collector.InterfaceStats{}
+		counter.RX.SetInt64(value[0])
+		counter.TX.SetInt64(value[1])
+		result[name] = counter
+	}
+	return result
+}
+
+func TestMatchInterface(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"eth0", "eth0", true},
+		{"eth0", "eth1", false},
+		{"veth*", "veth12ab", true},
+		{"veth*", "eth0", false},
+		{"^en[op]", "enp3s0", true},
+		{"^en[op]", "wlan0", false},
+	}
+	for _, tt := range tests {
+		if got := MatchInterface(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("MatchInterface(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestInterfaceUsage(t *testing.T) {
+	current := counters(map[string][2]int64{"eth0": {3000, 2000}, "eth1": {100, 100}, "wg0": {50, 60}})
+	baseline := counters(map[string][2]int64{"eth0": {1000, 500}, "eth1": {200, 50}})
+
+	usage := InterfaceUsage(current, baseline)
+	if used := usage["eth0"]; used == nil || used.RX.Int64() != 2000 || used.TX.Int64() != 1500 {
+		t.Errorf("eth0 = %+v, want 2000/1500", used)
+	}
+	// カウンタが巻き戻ったインターフェースは数えない。
+	if used, ok := usage["eth1"]; ok {
+		t.Errorf("eth1 = %+v, want no usage after the counter went back", used)
+	}
+	// 基準のないインターフェースは0から数える。
+	if used := usage["wg0"]; used == nil || used.RX.Int64() != 50 || used.TX.Int64() != 60 {
+		t.Errorf("wg0 = %+v, want 50/60", used)
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	rx, tx := big.NewInt(1100), big.NewInt(600)
+	baseline := Baseline{
+		RX:         rx,
+		TX:         tx,
+		Interfaces: counters(map[string][2]int64{"eth0": {1000, 500}, "veth1": {100, 100}}),
+		Last:       counters(map[string][2]int64{"eth0": {1500, 900}, "veth1": {400, 250}}),
+	}
+	now := time.Date(2026, time.October, 14, 12, 0, 0, 0, time.UTC)
+
+	// veth1 が消え、wg0 が現れた。
+	current := counters(map[string][2]int64{"eth0": {1600, 950}, "wg0": {70, 30}})
+	archived := baseline.Reconcile(current, "2026-10", now)
+
+	if len(archived) != 1 || archived[0].Name != "veth1" || archived[0].Used.RX.Int64() != 300 || archived[0].Used.TX.Int64() != 150 {
+		t.Fatalf("archived = %+v, want veth1 with 300/150", archived)
+	}
+	if archived[0].Month != "2026-10" || !archived[0].RemovedAt.Equal(now) {
+		t.Errorf("archived month %s at %s", archived[0].Month, archived[0].RemovedAt)
+	}
+	if _, ok := baseline.Interfaces["veth1"]; ok {
+		t.Error("veth1 is still in the baseline")
+	}
+	if base := baseline.Interfaces["wg0"]; base == nil || base.RX.Int64() != 70 {
+		t.Errorf("wg0 baseline = %+v, want the current counter", base)
+	}
+	// 合計から消えたインターフェースの最後の値を引き、現れたインターフェースの今の値を足す。
+	if rx.Int64() != 1100-400+70 || tx.Int64() != 600-250+30 {
+		t.Errorf("baseline total %s/%s, want %d/%d", rx, tx, 1100-400+70, 600-250+30)
+	}
+}
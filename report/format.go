@@ -0,0 +1,141 @@
+// Package report はインターフェースごとのカウンタを通信量に集計し、設定で選んだ単位と丸め方でバイト数や通信速度として表示する。
+package report
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"unicode"
+)
+
+// ByteFormat はバイト数の表示方法。
+type ByteFormat struct {
+	// Threshold は次の単位で表示するのに必要な、その単位での値。例えば 1 なら 1024 バイトを 1.00 KiB と表示する。
+	Threshold     float64
+	DecimalPlaces int
+	// RoundMode は "nearest"・"up"・"down" のいずれか。
+	RoundMode string
+	// UnitMode は "binary"（KiB・MiB …）か "decimal"（KB・MB …）。
+	UnitMode string
+	// Unit を指定すると、値が届く最大の単位ではなく、すべての値を "GB" や "GiB" などのその単位で表示する。
+	Unit string
+}
+
+// DefaultByteFormat は何も設定しない場合の表示方法。
+var DefaultByteFormat = ByteFormat{Threshold: 1, DecimalPlaces: 2, RoundMode: "nearest", UnitMode: "binary"}
+
+// byteUnits は unit_mode に応じた桁の基数と単位名を返す。binary は 1024 倍ごとの KiB / MiB …、
+// decimal は 1000 倍ごとの KB / MB …。
+func byteUnits(mode string) (float64, []string) {
+	if mode == "decimal" {
+		return 1000, []string{"B", "KB", "MB", "GB", "TB", "PB"}
+	}
+	return 1024, []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB"}
+}
+
+func (f ByteFormat) formatValue(val float64, unit string) string {
+	scale := math.Pow10(f.DecimalPlaces)
+	switch f.RoundMode {
+	case "up":
+		val = math.Ceil(val*scale) / scale
+	case "down":
+		val = math.Floor(val*scale) / scale
+	default:
+		val = math.Round(val*scale) / scale
+	}
+	return fmt.Sprintf("%.*f %s", f.DecimalPlaces, val, unit)
+}
+
+// unitExponents は大文字の単位名ごとの 1000 または 1024 の指数。IEC の単位は常に 1024 倍ごと。
+var unitExponents = map[string]int{"": 0, "B": 0, "KB": 1, "MB": 2, "GB": 3, "TB": 4, "PB": 5, "KIB": 1, "MIB": 2, "GIB": 3, "TIB": 4, "PIB": 5}
+
+// ParseUnit は "gib" のような単位名の正式な表記と、1024 倍ごとに数える IEC の単位かどうかを返す。
+func ParseUnit(name string) (string, bool, error) {
+	upper := strings.ToUpper(strings.TrimSpace(name))
+	power, ok := unitExponents[upper]
+	if !ok || upper == "" {
+		return "", false, fmt.Errorf("不明な単位です: %q", name)
+	}
+	if upper == "B" {
+		return "B", false, nil
+	}
+	if strings.HasSuffix(upper, "IB") {
+		_, units := byteUnits("binary")
+		return units[power], true, nil
+	}
+	_, units := byteUnits("decimal")
+	return units[power], false, nil
+}
+
+// Bytes はバイト数を Unit で、指定がなければ値が届く最大の単位で表示する。
+func (f ByteFormat) Bytes(Bytes *big.Int) string {
+	base, units := byteUnits(f.UnitMode)
+	fSize := new(big.Float).SetInt(Bytes)
+	if f.Unit != "" {
+		if strings.HasSuffix(f.Unit, "iB") {
+			base = 1024
+		} else {
+			base = 1000
+		}
+		divisor := new(big.Int).Exp(big.NewInt(int64(base)), big.NewInt(int64(unitExponents[strings.ToUpper(f.Unit)])), nil)
+		fSize.Quo(fSize, new(big.Float).SetInt(divisor))
+		val, _ := fSize.Float64()
+		return f.formatValue(val, f.Unit)
+	}
+	k := big.NewFloat(base)
+	crossover := new(big.Float).Mul(k, big.NewFloat(f.Threshold))
+	for _, unit := range units[:len(units)-1] {
+		cmp := fSize.Cmp(crossover)
+		if cmp == -1 {
+			val, _ := fSize.Float64()
+			return f.formatValue(val, unit)
+		}
+		fSize.Quo(fSize, k)
+	}
+	val, _ := fSize.Float64()
+	return f.formatValue(val, units[len(units)-1])
+}
+
+// Rate はバイト毎秒の値をビット毎秒に換算し、1000倍ごとに単位を上げて表示する。
+func (f ByteFormat) Rate(bytesPerSecond *big.Float) string {
+	units := []string{"bps", "Kbps", "Mbps", "Gbps"}
+	bits := new(big.Float).Mul(bytesPerSecond, big.NewFloat(8))
+	k := big.NewFloat(1000.0)
+	for _, unit := range units {
+		if bits.Cmp(k) == -1 {
+			val, _ := bits.Float64()
+			return f.formatValue(val, unit)
+		}
+		bits.Quo(bits, k)
+	}
+	val, _ := bits.Float64()
+	return f.formatValue(val, "Tbps")
+}
+
+// ParseBytes は "900GB" や "1.5 TiB" のような表記をバイト数に変換する（大文字小文字は区別しない）。
+// KiB / MiB / GiB / TiB は常に 1024 倍ごと、KB / MB / GB / TB は unitMode が decimal なら 1000 倍ごと、
+// binary なら従来どおり 1024 倍ごととして扱う。
+func ParseBytes(text, unitMode string) (*big.Int, error) {
+	text = strings.TrimSpace(text)
+	number := strings.TrimRightFunc(text, unicode.IsLetter)
+	unit := strings.ToUpper(strings.TrimSpace(text[len(number):]))
+
+	power, ok := unitExponents[unit]
+	if !ok {
+		return nil, fmt.Errorf("不明な単位です: %q", text)
+	}
+	value, ok := new(big.Float).SetString(strings.TrimSpace(number))
+	if !ok || value.Sign() < 0 {
+		return nil, fmt.Errorf("バイト数を解析できません: %q", text)
+	}
+
+	base := int64(1024)
+	if unitMode == "decimal" && !strings.HasSuffix(unit, "IB") {
+		base = 1000
+	}
+	multiplier := new(big.Int).Exp(big.NewInt(base), big.NewInt(int64(power)), nil)
+	value.Mul(value, new(big.Float).SetInt(multiplier))
+	bytes, _ := value.Int(nil)
+	return bytes, nil
+}
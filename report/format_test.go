@@ -0,0 +1,156 @@
+package report
+
+import (
+	"math/big"
+	"testing"
+)
+
+// tebi は n TiB のバイト数を返す。
+func tebi(n int64) *big.Int {
+	return new(big.Int).Lsh(big.NewInt(n), 40)
+}
+
+func TestBytes(t *testing.T) {
+	binary := DefaultByteFormat
+	decimal := DefaultByteFormat
+	decimal.UnitMode = "decimal"
+
+	tests := []struct {
+		name   string
+		format ByteFormat
+		bytes  *big.Int
+		want   string
+	}{
+		{"0", binary, big.NewInt(0), "0.00 B"},
+		{"1023", binary, big.NewInt(1023), "1023.00 B"},
+		{"1024", binary, big.NewInt(1024), "1.00 KiB"},
+		{"1536", binary, big.NewInt(1536), "1.50 KiB"},
+		{"1 MiB", binary, big.NewInt(1 << 20), "1.00 MiB"},
+		{"5 TiB", binary, tebi(5), "5.00 TiB"},
+		{"1 PiB", binary, tebi(1024), "1.00 PiB"},
+		// PiB より上の単位はないので PiB のまま大きくなる。
+		{"1024 PiB", binary, tebi(1 << 20), "1024.00 PiB"},
+
+		{"decimal 999", decimal, big.NewInt(999), "999.00 B"},
+		{"decimal 1000", decimal, big.NewInt(1000), "1.00 KB"},
+		{"decimal 1023", decimal, big.NewInt(1023), "1.02 KB"},
+		{"decimal 1024", decimal, big.NewInt(1024), "1.02 KB"},
+		{"decimal 5 TB", decimal, big.NewInt(5_000_000_000_000), "5.00 TB"},
+		{"decimal 12.5 TB", decimal, big.NewInt(12_500_000_000_000), "12.50 TB"},
+		// 同じ 5 TiB でも decimal では 1000 倍ごとに数える。
+		{"decimal 5 TiB", decimal, tebi(5), "5.50 TB"},
+	}
+	for _, tt := range tests {
+		if got := tt.format.Bytes(tt.bytes); got != tt.want {
+			t.Errorf("%s: Bytes(%s) = %q, want %q", tt.name, tt.bytes, got, tt.want)
+		}
+	}
+}
+
+func TestBytesFixedUnit(t *testing.T) {
+	tests := []struct {
+		unit  string
+		bytes *big.Int
+		want  string
+	}{
+		{"GB", big.NewInt(1_500_000_000), "1.50 GB"},
+		{"GiB", big.NewInt(1 << 30), "1.00 GiB"},
+		// 単位を固定すると、しきい値に届かなくてもその単位で表示する。
+		{"GiB", big.NewInt(1 << 20), "0.00 GiB"},
+		{"TB", tebi(5), "5.50 TB"},
+		{"B", big.NewInt(1 << 20), "1048576.00 B"},
+	}
+	for _, tt := range tests {
+		format := DefaultByteFormat
+		format.Unit = tt.unit
+		if got := format.Bytes(tt.bytes); got != tt.want {
+			t.Errorf("Bytes(%s) in %s = %q, want %q", tt.bytes, tt.unit, got, tt.want)
+		}
+	}
+}
+
+func TestRate(t *testing.T) {
+	tests := []struct {
+		name           string
+		format         ByteFormat
+		bytesPerSecond float64
+		want           string
+	}{
+		{"0", DefaultByteFormat, 0, "0.00 bps"},
+		// 124.875 B/s = 999 bps、125 B/s = 1000 bps。
+		{"999 bps", DefaultByteFormat, 124.875, "999.00 bps"},
+		{"1 Kbps", DefaultByteFormat, 125, "1.00 Kbps"},
+		{"999.99 Kbps", DefaultByteFormat, 124_998.75, "999.99 Kbps"},
+		{"1 Mbps", DefaultByteFormat, 125_000, "1.00 Mbps"},
+		{"1 Gbps", DefaultByteFormat, 125_000_000, "1.00 Gbps"},
+		{"1 Tbps", DefaultByteFormat, 125_000_000_000, "1.00 Tbps"},
+		// Tbps より上の単位はないので Tbps のまま大きくなる。
+		{"1000 Tbps", DefaultByteFormat, 125_000_000_000_000, "1000.00 Tbps"},
+		// バイトの unit_mode に関わらず、ビット毎秒は 1000 倍ごとに数える。
+		{"binary でも 1000 倍ごと", ByteFormat{DecimalPlaces: 2, UnitMode: "binary"}, 128, "1.02 Kbps"},
+		{"小数なし", ByteFormat{DecimalPlaces: 0}, 187_500, "2 Mbps"},
+		{"切り上げ", ByteFormat{DecimalPlaces: 1, RoundMode: "up"}, 125_001, "1.1 Mbps"},
+		{"切り捨て", ByteFormat{DecimalPlaces: 1, RoundMode: "down"}, 249_999, "1.9 Mbps"},
+	}
+	for _, tt := range tests {
+		if got := tt.format.Rate(big.NewFloat(tt.bytesPerSecond)); got != tt.want {
+			t.Errorf("%s: Rate(%v) = %q, want %q", tt.name, tt.bytesPerSecond, got, tt.want)
+		}
+	}
+}
+
+func TestRateConvertsBytesToBits(t *testing.T) {
+	format := ByteFormat{DecimalPlaces: 3}
+	tests := []struct {
+		bytesPerSecond float64
+		want           string
+	}{
+		{1, "8.000 bps"},
+		{0.125, "1.000 bps"},
+		{100, "800.000 bps"},
+		{1000, "8.000 Kbps"},
+		{1 << 20, "8.389 Mbps"},
+		{1.25e9, "10.000 Gbps"},
+	}
+	for _, tt := range tests {
+		if got := format.Rate(big.NewFloat(tt.bytesPerSecond)); got != tt.want {
+			t.Errorf("Rate(%v B/s) = %q, want %q", tt.bytesPerSecond, got, tt.want)
+		}
+	}
+
+	// 渡した値は書き換えない。
+	value := big.NewFloat(1000)
+	format.Rate(value)
+	if value.Cmp(big.NewFloat(1000)) != 0 {
+		t.Errorf("Rate changed its argument to %s", value)
+	}
+}
+
+func TestBytesThreshold(t *testing.T) {
+	tests := []struct {
+		name      string
+		threshold float64
+		unitMode  string
+		bytes     *big.Int
+		want      string
+	}{
+		{"1 で 1023", 1, "binary", big.NewInt(1023), "1023.00 B"},
+		{"1 で 1024", 1, "binary", big.NewInt(1024), "1.00 KiB"},
+		// 1000 なら 1000 KiB に届くまで KiB に上げない。
+		{"1000 で 1024", 1000, "binary", big.NewInt(1024), "1024.00 B"},
+		{"1000 で 1023999", 1000, "binary", big.NewInt(1000*1024 - 1), "1023999.00 B"},
+		{"1000 で 1000 KiB", 1000, "binary", big.NewInt(1000 * 1024), "1000.00 KiB"},
+		{"1000 で 1000 MiB 未満", 1000, "binary", big.NewInt(1000*1024*1024 - 1024), "1023999.00 KiB"},
+		{"1000 で 1000 MiB", 1000, "binary", big.NewInt(1000 * 1024 * 1024), "1000.00 MiB"},
+		{"0.9 で 922", 0.9, "binary", big.NewInt(922), "0.90 KiB"},
+		{"0.9 で 921", 0.9, "binary", big.NewInt(921), "921.00 B"},
+		{"decimal 1000 で 999999", 1000, "decimal", big.NewInt(999_999), "999999.00 B"},
+		{"decimal 1000 で 1000000", 1000, "decimal", big.NewInt(1_000_000), "1000.00 KB"},
+	}
+	for _, tt := range tests {
+		format := ByteFormat{Threshold: tt.threshold, DecimalPlaces: 2, UnitMode: tt.unitMode}
+		if got := format.Bytes(tt.bytes); got != tt.want {
+			t.Errorf("%s: Bytes(%s) = %q, want %q", tt.name, tt.bytes, got, tt.want)
+		}
+	}
+}
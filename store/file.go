@@ -0,0 +1,100 @@
+// Package store は JSON の状態を、異常終了しても壊れないようにファイルへ保存するか、Redis で複数のインスタンスと共有する。
+// ファイルは書き込みのたびにアトミックに置き換え、前の内容を読めない場合に戻すためのバックアップとして残す。
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// BackupPath は Save が置き換える前の内容を残す場所。
+func BackupPath(path string) string {
+	return path + ".bak"
+}
+
+// Load は path の JSON ファイルを読み込み、まだ何も保存されていないかどうかを返す。
+// 壊れたファイルは Save が残したバックアップから復元する。
+func Load[T any](path string) (*T, bool, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return new(T), true, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var value T
+	err = json.Unmarshal(data, &value)
+	if err != nil {
+		backup, backupErr := loadBackup[T](path)
+		if backupErr != nil {
+			return nil, false, fmt.Errorf("%s: %w（バックアップからも復元できません: %v）", path, err, backupErr)
+		}
+		slog.Warn("ファイルが壊れているため、バックアップから復元しました", "path", path, "backup", BackupPath(path), "error", err)
+		return backup, false, nil
+	}
+	return &value, false, nil
+}
+
+func loadBackup[T any](path string) (*T, error) {
+	data, err := os.ReadFile(BackupPath(path))
+	if err != nil {
+		return nil, err
+	}
+	var value T
+	err = json.Unmarshal(data, &value)
+	if err != nil {
+		return nil, err
+	}
+	return &value, nil
+}
+
+// Save は data を path に書き込む。置き換える内容はバックアップに残すが、壊れたファイルで
+// 正常なバックアップを上書きしないよう、読み戻せる場合に限る。
+func Save(path string, data []byte) error {
+	if old, err := os.ReadFile(path); err == nil && json.Valid(old) {
+		if err := WriteFileAtomic(BackupPath(path), old, 0644); err != nil {
+			slog.Warn("ファイルのバックアップに失敗しました", "path", BackupPath(path), "error", err)
+		}
+	}
+	return WriteFileAtomic(path, data, 0644)
+}
+
+// WriteFileAtomic は data を path と同じディレクトリの一時ファイルに書き込んでから名前を変えて置き換える。
+// 読み取る側にも異常終了後にも、書きかけのファイルが見えることはない。
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	// 名前の変更自体も異常終了で失われないよう、ディレクトリも同期する。
+	dir, err := os.Open(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
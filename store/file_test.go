@@ -0,0 +1,121 @@
+package store
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type state struct {
+	Month string `json:"month"`
+	RX    int64  `json:"rx"`
+}
+
+// onlyFiles は dir に names 以外のファイル（書きかけの一時ファイルなど）がないことを確かめる。
+func onlyFiles(t *testing.T, dir string, names ...string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]bool{}
+	for _, name := range names {
+		want[name] = true
+	}
+	for _, entry := range entries {
+		if !want[entry.Name()] {
+			t.Errorf("unexpected file %s in %s", entry.Name(), dir)
+		}
+	}
+}
+
+func TestLoadMissing(t *testing.T) {
+	value, isFirstRun, err := Load[state](filepath.Join(t.TempDir(), "stats.json"))
+	if err != nil || !isFirstRun || *value != (state{}) {
+		t.Errorf("Load = %+v, %v, %v; want an empty value on the first run", value, isFirstRun, err)
+	}
+}
+
+func TestSaveOverCorruptedFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "stats.json")
+	// 書き込みの途中で止まったファイル。
+	if err := os.WriteFile(path, []byte(`{"month": "2026-1`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := json.Marshal(state{Month: "2026-10", RX: 1024})
+	if err := Save(path, data); err != nil {
+		t.Fatal(err)
+	}
+
+	value, isFirstRun, err := Load[state](path)
+	if err != nil || isFirstRun {
+		t.Fatalf("Load = %v, %v", isFirstRun, err)
+	}
+	if *value != (state{Month: "2026-10", RX: 1024}) {
+		t.Errorf("Load = %+v", value)
+	}
+	// 壊れた内容はバックアップにしない。
+	onlyFiles(t, dir, "stats.json")
+}
+
+func TestLoadRestoresBackup(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "stats.json")
+	for _, value := range []state{{Month: "2026-09", RX: 1}, {Month: "2026-10", RX: 2}} {
+		data, _ := json.Marshal(value)
+		if err := Save(path, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	onlyFiles(t, dir, "stats.json", "stats.json.bak")
+
+	if err := os.WriteFile(path, []byte(`{"month": `), 0644); err != nil {
+		t.Fatal(err)
+	}
+	value, _, err := Load[state](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *value != (state{Month: "2026-09", RX: 1}) {
+		t.Errorf("Load = %+v, want the backup", value)
+	}
+}
+
+func TestLoadCorruptedWithoutBackup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stats.json")
+	if err := os.WriteFile(path, []byte("\x00\x00\x00"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := Load[state](path); err == nil {
+		t.Error("Load of a corrupted file without a backup succeeded")
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "report.json")
+	if err := os.WriteFile(path, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteFileAtomic(path, []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "new" {
+		t.Errorf("contents = %q, %v", data, err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0644 {
+		t.Errorf("mode = %v, %v; want 0644", info.Mode().Perm(), err)
+	}
+	onlyFiles(t, dir, "report.json")
+
+	// 書き込めない場合も一時ファイルを残さない。
+	if err := WriteFileAtomic(filepath.Join(dir, "missing", "report.json"), []byte("new"), 0644); err == nil {
+		t.Error("WriteFileAtomic into a missing directory succeeded")
+	}
+	onlyFiles(t, dir, "report.json")
+}
@@ -0,0 +1,151 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Store は実行の間の状態を保存する。ファイルのほか、Redis なら短時間で終わる複数のインスタンスで状態を共有できる。
+type Store[T any] interface {
+	// Load は保存された状態と、まだ何も保存されていないかどうかを返す。
+	Load() (*T, bool, error)
+	Save(value *T) error
+	// Writable は今状態を保存できない理由を返す。保存できれば nil を返す。
+	Writable() error
+}
+
+const redisTimeout = 5 * time.Second
+
+// RedisStore は状態をJSONの値として1つのキーに保存する。一時的な障害でベースラインがリセットされないよう、
+// Redis に接続できない場合はメモリに持っている最後の状態を使う。
+type RedisStore[T any] struct {
+	client *redis.Client
+	key    string
+}
+
+var (
+	redisClientsMu sync.Mutex
+	redisClients   = map[string]*redis.Client{}
+
+	// memoryValues は接続できない場合のために、Redis のキーごとに最後の状態を持つ。
+	memoryValuesMu sync.Mutex
+	memoryValues   = map[string][]byte{}
+)
+
+// NewRedisStore は url の Redis の key に保存する RedisStore を返す。同じ url の RedisStore は接続を共有する。
+func NewRedisStore[T any](url, key string) (*RedisStore[T], error) {
+	redisClientsMu.Lock()
+	defer redisClientsMu.Unlock()
+
+	client, ok := redisClients[url]
+	if !ok {
+		options, err := redis.ParseURL(url)
+		if err != nil {
+			return nil, fmt.Errorf("redis_url の解析に失敗しました: %w", err)
+		}
+		client = redis.NewClient(options)
+		redisClients[url] = client
+	}
+	return &RedisStore[T]{client: client, key: key}, nil
+}
+
+func (s *RedisStore[T]) Key() string {
+	return s.key
+}
+
+func (s *RedisStore[T]) Load() (*T, bool, error) {
+	data, err := s.Stored()
+	if err != nil {
+		// 初回として扱うとベースラインがリセットされ、復旧後に Redis の状態を上書きしてしまう。
+		return nil, false, err
+	}
+	if data == nil {
+		return new(T), true, nil
+	}
+
+	var value T
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, false, err
+	}
+	return &value, false, nil
+}
+
+// Stored は保存されている状態のJSONを返し、まだ何も保存されていなければ nil を返す。
+// Redis に接続できない場合はメモリ上の状態を返し、それもなければエラーを返す。
+func (s *RedisStore[T]) Stored() ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	data, err := s.client.Get(ctx, s.key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		memoryValuesMu.Lock()
+		data = memoryValues[s.key]
+		memoryValuesMu.Unlock()
+		if data == nil {
+			return nil, fmt.Errorf("Redisから統計を読み込めません（%s）: %w", s.key, err)
+		}
+		slog.Warn("Redisに接続できないため、メモリ上の統計を使用します", "key", s.key, "error", err)
+	}
+	return data, nil
+}
+
+// Writable は Redis が応答するかを確かめる。応答しない間 Save は状態をメモリに持つので、終了すると失われる。
+func (s *RedisStore[T]) Writable() error {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	return s.client.Ping(ctx).Err()
+}
+
+func (s *RedisStore[T]) Save(value *T) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	memoryValuesMu.Lock()
+	memoryValues[s.key] = data
+	memoryValuesMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	err = s.client.Set(ctx, s.key, data, 0).Err()
+	if err != nil {
+		slog.Warn("Redisに保存できないため、統計をメモリ上に保持します", "key", s.key, "error", err)
+	}
+	return nil
+}
+
+// redisLockTTL は Redis のロックの期限。ロックを持ったまま終了したインスタンスが他を止め続けないようにする。
+const redisLockTTL = 10 * time.Minute
+
+// redisUnlock は自分が取ったロックだけを消す。期限が切れて他のインスタンスが取り直したロックは消さない。
+var redisUnlock = redis.NewScript(`if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) end return 0`)
+
+func (s *RedisStore[T]) LockKey() string {
+	return s.key + ":lock"
+}
+
+// TryLock は同じキーを使うインスタンスの間のロックを SET NX で待たずに取得する。token は Unlock に渡す自分の印。
+func (s *RedisStore[T]) TryLock(token string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	return s.client.SetNX(ctx, s.LockKey(), token, redisLockTTL).Result()
+}
+
+func (s *RedisStore[T]) Unlock(token string) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	if err := redisUnlock.Run(ctx, s.client, []string{s.LockKey()}, token).Err(); err != nil {
+		slog.Warn("Redisのロックの解放に失敗しました", "key", s.LockKey(), "error", err)
+	}
+}
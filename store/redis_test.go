@@ -0,0 +1,31 @@
+package store
+
+import "testing"
+
+type counter struct {
+	N int `json:"n"`
+}
+
+func TestRedisStoreUnreachable(t *testing.T) {
+	// 何も待ち受けていないポートなので、すべての操作が接続に失敗する。
+	s, err := NewRedisStore[counter]("redis://127.0.0.1:1/0", "test:unreachable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Writable() == nil {
+		t.Error("Writable succeeded without a server")
+	}
+
+	// 初回として扱うとベースラインがリセットされるので、メモリ上の値がなければエラーにする。
+	if _, isFirstRun, err := s.Load(); err == nil || isFirstRun {
+		t.Fatalf("Load = first run %v, error %v, want an error", isFirstRun, err)
+	}
+
+	if err := s.Save(&counter{N: 3}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	value, isFirstRun, err := s.Load()
+	if err != nil || isFirstRun || value.N != 3 {
+		t.Errorf("Load after Save = %+v, first run %v, error %v, want the value kept in memory", value, isFirstRun, err)
+	}
+}